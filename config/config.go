@@ -4,65 +4,432 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"time"
+
+	"github.com/evdnx/gonotify/format"
+	"github.com/evdnx/gonotify/templates"
 )
 
 // ConfigFile represents the structure of the notification configuration file
 type ConfigFile struct {
-	Element  *ElementConfig  `json:"element,omitempty"`
-	Telegram *TelegramConfig `json:"telegram,omitempty"`
-	Events   EventConfig     `json:"events"`
+	Element        *ElementConfig                `json:"element,omitempty"`
+	Telegram       *TelegramConfig               `json:"telegram,omitempty"`
+	Slack          *SlackConfig                  `json:"slack,omitempty"`
+	Discord        *DiscordConfig                `json:"discord,omitempty"`
+	Email          *EmailConfig                  `json:"email,omitempty"`
+	Webhook        *WebhookConfig                `json:"webhook,omitempty"`
+	Ntfy           *NtfyConfig                   `json:"ntfy,omitempty"`
+	Gotify         *GotifyConfig                 `json:"gotify,omitempty"`
+	PagerDuty      *PagerDutyConfig              `json:"pagerduty,omitempty"`
+	Opsgenie       *OpsgenieConfig               `json:"opsgenie,omitempty"`
+	Delivery       *DeliveryConfig               `json:"delivery,omitempty"`
+	Retry          *RetryConfig                  `json:"retry,omitempty"`
+	Outbox         *OutboxConfig                 `json:"outbox,omitempty"`
+	DeadLetters    *DeadLetterConfig             `json:"dead_letters,omitempty"`
+	CircuitBreaker *CircuitBreakerConfig         `json:"circuit_breaker,omitempty"`
+	Failover       map[string][]string           `json:"failover,omitempty"`
+	Routes         []RouteConfig                 `json:"routes,omitempty"`
+	Messengers     []MessengerConfig             `json:"messengers,omitempty"`
+	Templates      map[string]templates.Template `json:"templates,omitempty"`
+	Formatting     *FormattingConfig             `json:"formatting,omitempty"`
+	Timestamps     *TimestampConfig              `json:"timestamps,omitempty"`
+	Events         EventConfig                   `json:"events"`
 }
 
-// ElementConfig contains Element messenger configuration
+// ElementConfig contains Element messenger configuration. The client logs
+// in with User and Password when no AccessToken is set, and RoomID may be a
+// room alias. MsgType is "m.text" or "m.notice", and Mentions are the user
+// IDs pinged by notifications of MentionSeverity or above.
 type ElementConfig struct {
-	HomeserverURL string `json:"homeserver_url"`
-	AccessToken   string `json:"access_token"`
-	RoomID        string `json:"room_id"`
-	Enabled       bool   `json:"enabled"`
+	HomeserverURL   string   `json:"homeserver_url"`
+	AccessToken     string   `json:"access_token,omitempty"`
+	RefreshToken    string   `json:"refresh_token,omitempty"`
+	User            string   `json:"user,omitempty"`
+	Password        string   `json:"password,omitempty"`
+	DeviceID        string   `json:"device_id,omitempty"`
+	RoomID          string   `json:"room_id"`
+	AutoJoin        bool     `json:"auto_join,omitempty"`
+	MsgType         string   `json:"msgtype,omitempty"`
+	Mentions        []string `json:"mentions,omitempty"`
+	MentionSeverity string   `json:"mention_severity,omitempty"`
+	Enabled         bool     `json:"enabled"`
 }
 
-// TelegramConfig contains Telegram messenger configuration
+// TelegramConfig contains Telegram messenger configuration. ParseMode is
+// "MarkdownV2", "HTML" or empty for plain text, and OrderURL links order
+// notifications to the exchange with {symbol} and {id} placeholders.
 type TelegramConfig struct {
-	BotToken string `json:"bot_token"`
-	ChatID   string `json:"chat_id"`
-	Enabled  bool   `json:"enabled"`
+	BotToken              string `json:"bot_token"`
+	ChatID                string `json:"chat_id"`
+	ParseMode             string `json:"parse_mode,omitempty"`
+	DisableWebPagePreview bool   `json:"disable_web_page_preview,omitempty"`
+	DisableNotification   bool   `json:"disable_notification,omitempty"`
+	OrderURL              string `json:"order_url,omitempty"`
+	Enabled               bool   `json:"enabled"`
+}
+
+// SlackConfig contains Slack messenger configuration. Either WebhookURL or
+// BotToken and Channel must be set.
+type SlackConfig struct {
+	WebhookURL string `json:"webhook_url,omitempty"`
+	BotToken   string `json:"bot_token,omitempty"`
+	Channel    string `json:"channel,omitempty"`
+	Enabled    bool   `json:"enabled"`
+}
+
+// DiscordConfig contains Discord messenger configuration
+type DiscordConfig struct {
+	WebhookURL string `json:"webhook_url"`
+	Username   string `json:"username,omitempty"`
+	Enabled    bool   `json:"enabled"`
+}
+
+// EmailConfig contains SMTP email messenger configuration
+type EmailConfig struct {
+	Host          string   `json:"host"`
+	Port          int      `json:"port"`
+	Username      string   `json:"username,omitempty"`
+	Password      string   `json:"password,omitempty"`
+	From          string   `json:"from"`
+	To            []string `json:"to"`
+	Security      string   `json:"security,omitempty"` // "starttls", "tls" or "none"
+	Auth          string   `json:"auth,omitempty"`     // "plain" or "login"
+	SubjectPrefix string   `json:"subject_prefix,omitempty"`
+	Enabled       bool     `json:"enabled"`
+}
+
+// WebhookConfig contains generic HTTP webhook messenger configuration
+type WebhookConfig struct {
+	URL                string            `json:"url"`
+	Method             string            `json:"method,omitempty"`
+	Secret             string            `json:"secret,omitempty"`
+	SignatureHeader    string            `json:"signature_header,omitempty"`
+	Headers            map[string]string `json:"headers,omitempty"`
+	TimeoutSeconds     int               `json:"timeout_seconds,omitempty"`
+	SuccessStatusCodes []int             `json:"success_status_codes,omitempty"`
+	Enabled            bool              `json:"enabled"`
+}
+
+// NtfyConfig contains ntfy push messenger configuration
+type NtfyConfig struct {
+	ServerURL string `json:"server_url"`
+	Topic     string `json:"topic"`
+	Token     string `json:"token,omitempty"`
+	Enabled   bool   `json:"enabled"`
+}
+
+// GotifyConfig contains Gotify push messenger configuration
+type GotifyConfig struct {
+	ServerURL string `json:"server_url"`
+	AppToken  string `json:"app_token"`
+	Enabled   bool   `json:"enabled"`
+}
+
+// PagerDutyConfig contains PagerDuty Events API v2 configuration. Events
+// lists the event types that page; system and strategy errors page when it
+// is empty.
+type PagerDutyConfig struct {
+	RoutingKey string   `json:"routing_key"`
+	Source     string   `json:"source,omitempty"`
+	Events     []string `json:"events,omitempty"`
+	Enabled    bool     `json:"enabled"`
+}
+
+// OpsgenieConfig contains Opsgenie Alerts API configuration. Events lists
+// the event types that page; system and strategy errors page when it is
+// empty.
+type OpsgenieConfig struct {
+	APIKey  string   `json:"api_key"`
+	APIURL  string   `json:"api_url,omitempty"`
+	Events  []string `json:"events,omitempty"`
+	Enabled bool     `json:"enabled"`
+}
+
+// MessengerConfig is a named messenger instance, for running several
+// messengers of the same type. Exactly one messenger section is set, and its
+// Enabled flag turns the instance on or off. Routes and failover chains
+// refer to the instance by Name, which defaults to the messenger's own name.
+type MessengerConfig struct {
+	Name      string           `json:"name,omitempty"`
+	Element   *ElementConfig   `json:"element,omitempty"`
+	Telegram  *TelegramConfig  `json:"telegram,omitempty"`
+	Slack     *SlackConfig     `json:"slack,omitempty"`
+	Discord   *DiscordConfig   `json:"discord,omitempty"`
+	Email     *EmailConfig     `json:"email,omitempty"`
+	Webhook   *WebhookConfig   `json:"webhook,omitempty"`
+	Ntfy      *NtfyConfig      `json:"ntfy,omitempty"`
+	Gotify    *GotifyConfig    `json:"gotify,omitempty"`
+	PagerDuty *PagerDutyConfig `json:"pagerduty,omitempty"`
+	Opsgenie  *OpsgenieConfig  `json:"opsgenie,omitempty"`
+}
+
+// DeliveryConfig contains settings shared by all messenger deliveries. Each
+// messenger gets its own queue of QueueSize notifications drained by Workers
+// workers; Overflow is "block", "drop_oldest" or "drop_newest".
+type DeliveryConfig struct {
+	TimeoutSeconds int    `json:"timeout_seconds,omitempty"`
+	QueueSize      int    `json:"queue_size,omitempty"`
+	Workers        int    `json:"workers,omitempty"`
+	Overflow       string `json:"overflow,omitempty"`
+}
+
+// RetryConfig contains the retry policy for failed deliveries. Each
+// messenger retries its own failures; MaxAttempts counts the first attempt
+// and Jitter is the fraction of each delay randomised either way. Zero
+// values take the defaults; a negative Jitter turns jitter off.
+type RetryConfig struct {
+	MaxAttempts int     `json:"max_attempts,omitempty"`
+	BaseDelayMs int     `json:"base_delay_ms,omitempty"`
+	MaxDelayMs  int     `json:"max_delay_ms,omitempty"`
+	Jitter      float64 `json:"jitter,omitempty"`
+}
+
+// OutboxConfig contains the durable outbox configuration. Notifications are
+// written to the file at Path before delivery and replayed on restart until
+// every messenger has handled them.
+type OutboxConfig struct {
+	Path    string `json:"path"`
+	Enabled bool   `json:"enabled"`
+}
+
+// DeadLetterConfig contains the dead-letter store configuration. When
+// enabled, notifications messengers gave up on are kept in the file at Path;
+// otherwise they are only kept in memory. Either way at most MaxLetters are
+// kept, discarding the oldest first.
+type DeadLetterConfig struct {
+	Path       string `json:"path"`
+	MaxLetters int    `json:"max_letters,omitempty"`
+	Enabled    bool   `json:"enabled"`
+}
+
+// CircuitBreakerConfig contains the circuit breaker wrapped around each
+// messenger. A breaker opens after FailureThreshold consecutive failed
+// deliveries, rejects deliveries for OpenTimeoutSeconds and then lets a probe
+// through; SuccessThreshold successful probes close it again.
+type CircuitBreakerConfig struct {
+	FailureThreshold   int  `json:"failure_threshold,omitempty"`
+	OpenTimeoutSeconds int  `json:"open_timeout_seconds,omitempty"`
+	SuccessThreshold   int  `json:"success_threshold,omitempty"`
+	Enabled            bool `json:"enabled"`
+}
+
+// RouteConfig sends the events it matches to the named messengers. Events
+// lists event types, and Symbols and Strategies narrow the route to events
+// about those symbols or strategies; empty lists match everything, so a
+// route without criteria is a catch-all. Routes are checked in order and an
+// event goes to the messengers of every route it matches, unless a matching
+// route sets Stop, which skips the routes after it. Events no route matches
+// are sent to every messenger.
+type RouteConfig struct {
+	Events     []string `json:"events,omitempty"`
+	Symbols    []string `json:"symbols,omitempty"`
+	Strategies []string `json:"strategies,omitempty"`
+	Messengers []string `json:"messengers"`
+	Stop       bool     `json:"stop,omitempty"`
+}
+
+// FormattingConfig contains how prices, quantities and amounts are
+// formatted. Symbols sets the precision of trading symbols by their tick and
+// step sizes; CurrencySymbols replaces asset names with a prefix such as "$".
+type FormattingConfig struct {
+	ThousandsSeparator string                  `json:"thousands_separator,omitempty"`
+	KeepTrailingZeros  bool                    `json:"keep_trailing_zeros,omitempty"`
+	CurrencySymbols    map[string]string       `json:"currency_symbols,omitempty"`
+	Symbols            map[string]SymbolConfig `json:"symbols,omitempty"`
+}
+
+// SymbolConfig contains the precision of a trading symbol. TickSize is the
+// price increment and StepSize the quantity increment, e.g. 0.01 and
+// 0.00001.
+type SymbolConfig struct {
+	TickSize   float64 `json:"tick_size,omitempty"`
+	StepSize   float64 `json:"step_size,omitempty"`
+	BaseAsset  string  `json:"base_asset,omitempty"`
+	QuoteAsset string  `json:"quote_asset,omitempty"`
+}
+
+// TimestampConfig contains how notification timestamps are shown. Timezone
+// is an IANA zone name such as "Europe/Berlin", Format a Go reference layout
+// and Source "event", "exchange" or "both".
+type TimestampConfig struct {
+	Timezone string `json:"timezone,omitempty"`
+	Format   string `json:"format,omitempty"`
+	Source   string `json:"source,omitempty"`
 }
 
 // EventConfig contains event notification configuration
 type EventConfig struct {
-	TradeExecution  bool    `json:"trade_execution"`
-	OrderFilled     bool    `json:"order_filled"`
-	PositionChange  bool    `json:"position_change"`
-	PnLUpdate       bool    `json:"pnl_update"`
-	StopLoss        bool    `json:"stop_loss"`
-	TakeProfit      bool    `json:"take_profit"`
-	SystemErrors    bool    `json:"system_errors"`
-	StrategyErrors  bool    `json:"strategy_errors"`
-	ProfitThreshold float64 `json:"profit_threshold"`
+	TradeExecution     bool    `json:"trade_execution"`
+	OrderFilled        bool    `json:"order_filled"`
+	PositionChange     bool    `json:"position_change"`
+	PnLUpdate          bool    `json:"pnl_update"`
+	StopLoss           bool    `json:"stop_loss"`
+	TakeProfit         bool    `json:"take_profit"`
+	SystemErrors       bool    `json:"system_errors"`
+	StrategyErrors     bool    `json:"strategy_errors"`
+	ServiceStop        bool    `json:"service_stop"`
+	BreakerStateChange bool    `json:"breaker_state_change"`
+	ProfitThreshold    float64 `json:"profit_threshold"`
 }
 
 // NotificationConfig contains configuration for the notification service
 type NotificationConfig struct {
 	// Element messenger configuration
-	ElementHomeserverURL string
-	ElementAccessToken   string
-	ElementRoomID        string
-	ElementEnabled       bool
+	ElementHomeserverURL   string
+	ElementAccessToken     string
+	ElementRefreshToken    string
+	ElementUser            string
+	ElementPassword        string
+	ElementDeviceID        string
+	ElementAutoJoin        bool
+	ElementRoomID          string
+	ElementMsgType         string
+	ElementMentions        []string
+	ElementMentionSeverity string
+	ElementEnabled         bool
 
 	// Telegram messenger configuration
-	TelegramBotToken string
-	TelegramChatID   string
-	TelegramEnabled  bool
+	TelegramBotToken              string
+	TelegramChatID                string
+	TelegramParseMode             string
+	TelegramDisableWebPagePreview bool
+	TelegramDisableNotification   bool
+	TelegramOrderURL              string
+	TelegramEnabled               bool
+
+	// Slack messenger configuration
+	SlackWebhookURL string
+	SlackBotToken   string
+	SlackChannel    string
+	SlackEnabled    bool
+
+	// Discord messenger configuration
+	DiscordWebhookURL string
+	DiscordUsername   string
+	DiscordEnabled    bool
+
+	// Email messenger configuration
+	EmailHost          string
+	EmailPort          int
+	EmailUsername      string
+	EmailPassword      string
+	EmailFrom          string
+	EmailTo            []string
+	EmailSecurity      string
+	EmailAuth          string
+	EmailSubjectPrefix string
+	EmailEnabled       bool
+
+	// Generic webhook messenger configuration
+	WebhookURL                string
+	WebhookMethod             string
+	WebhookSecret             string
+	WebhookSignatureHeader    string
+	WebhookHeaders            map[string]string
+	WebhookTimeout            time.Duration
+	WebhookSuccessStatusCodes []int
+	WebhookEnabled            bool
+
+	// ntfy messenger configuration
+	NtfyServerURL string
+	NtfyTopic     string
+	NtfyToken     string
+	NtfyEnabled   bool
+
+	// Gotify messenger configuration
+	GotifyServerURL string
+	GotifyAppToken  string
+	GotifyEnabled   bool
+
+	// PagerDuty alerting configuration
+	PagerDutyRoutingKey string
+	PagerDutySource     string
+	PagerDutyEvents     []string
+	PagerDutyEnabled    bool
+
+	// Opsgenie alerting configuration
+	OpsgenieAPIKey  string
+	OpsgenieAPIURL  string
+	OpsgenieEvents  []string
+	OpsgenieEnabled bool
+
+	// Delivery settings: the maximum time a single delivery to one messenger
+	// may take, and the size, worker count and overflow policy of each
+	// messenger's queue
+	DeliveryTimeout   time.Duration
+	DeliveryQueueSize int
+	DeliveryWorkers   int
+	DeliveryOverflow  string
+
+	// Retry policy for failed deliveries
+	RetryMaxAttempts int
+	RetryBaseDelay   time.Duration
+	RetryMaxDelay    time.Duration
+	RetryJitter      float64
+
+	// Durable outbox configuration
+	OutboxPath    string
+	OutboxEnabled bool
+
+	// Dead-letter store configuration
+	DeadLetterPath       string
+	DeadLetterMaxLetters int
+	DeadLetterEnabled    bool
+
+	// Circuit breaker settings applied to every messenger
+	BreakerFailureThreshold int
+	BreakerOpenTimeout      time.Duration
+	BreakerSuccessThreshold int
+	BreakerEnabled          bool
+
+	// Failover chains: the messengers to deliver to, by name, while a
+	// messenger's circuit breaker is open
+	Failover map[string][]string
+
+	// Routing table; every notification goes to every messenger when empty
+	Routes []RouteConfig
+
+	// Named messenger instances, in addition to the messengers above
+	Messengers []MessengerConfig
+
+	// Message templates by event type, overriding the default wording
+	Templates map[string]templates.Template
+
+	// Number formatting: thousands grouping, trailing zeros, currency
+	// symbols by asset and the precision of trading symbols
+	ThousandsSeparator string
+	KeepTrailingZeros  bool
+	CurrencySymbols    map[string]string
+	Symbols            map[string]SymbolConfig
+
+	// Timestamps: the zone and layout they are shown in, and whether to show
+	// when the event was published, when the exchange says it happened, or
+	// both with the delay between them
+	Timezone        string
+	TimestampFormat string
+	TimestampSource string
+
+	// PrecisionProvider looks up the precision of symbols missing from
+	// Symbols, e.g. from an exchange's symbol information. It is not saved.
+	PrecisionProvider format.PrecisionProvider
+
+	// OnElementRefreshToken is called with the instance name, empty for the
+	// single Element messenger, and the new refresh token whenever an Element
+	// homeserver rotates it, e.g. to store it with SaveElementRefreshToken.
+	// It is not saved.
+	OnElementRefreshToken func(name, refreshToken string)
 
 	// Event types to notify about
-	NotifyTradeExecution bool
-	NotifyOrderFilled    bool
-	NotifyPositionChange bool
-	NotifyPnLUpdate      bool
-	NotifyStopLoss       bool
-	NotifyTakeProfit     bool
-	NotifySystemErrors   bool
-	NotifyStrategyErrors bool
+	NotifyTradeExecution     bool
+	NotifyOrderFilled        bool
+	NotifyPositionChange     bool
+	NotifyPnLUpdate          bool
+	NotifyStopLoss           bool
+	NotifyTakeProfit         bool
+	NotifySystemErrors       bool
+	NotifyStrategyErrors     bool
+	NotifyServiceStop        bool
+	NotifyBreakerStateChange bool
 
 	// Minimum profit threshold for PnL notifications (as a percentage)
 	ProfitThreshold float64
@@ -77,14 +444,66 @@ func DefaultNotificationConfig() *NotificationConfig {
 
 		TelegramEnabled: false,
 
-		NotifyTradeExecution: true,
-		NotifyOrderFilled:    true,
-		NotifyPositionChange: true,
-		NotifyPnLUpdate:      true,
-		NotifyStopLoss:       true,
-		NotifyTakeProfit:     true,
-		NotifySystemErrors:   true,
-		NotifyStrategyErrors: true,
+		SlackEnabled: false,
+
+		DiscordEnabled: false,
+
+		EmailPort:          587,
+		EmailSecurity:      "starttls",
+		EmailAuth:          "plain",
+		EmailSubjectPrefix: "[GoNotify]",
+		EmailEnabled:       false,
+
+		WebhookMethod:  "POST",
+		WebhookTimeout: 10 * time.Second,
+		WebhookEnabled: false,
+
+		NtfyServerURL: "https://ntfy.sh",
+		NtfyEnabled:   false,
+
+		GotifyEnabled: false,
+
+		PagerDutyEvents:  []string{"system_error", "strategy_error"},
+		PagerDutyEnabled: false,
+
+		OpsgenieAPIURL:  "https://api.opsgenie.com",
+		OpsgenieEvents:  []string{"system_error", "strategy_error"},
+		OpsgenieEnabled: false,
+
+		DeliveryTimeout:   10 * time.Second,
+		DeliveryQueueSize: 100,
+		DeliveryWorkers:   1,
+		DeliveryOverflow:  "drop_oldest",
+
+		RetryMaxAttempts: 3,
+		RetryBaseDelay:   time.Second,
+		RetryMaxDelay:    30 * time.Second,
+		RetryJitter:      0.2,
+
+		OutboxPath:    "data/notification_outbox.jsonl",
+		OutboxEnabled: false,
+
+		DeadLetterPath:    "data/notification_dead_letters.jsonl",
+		DeadLetterEnabled: false,
+
+		BreakerFailureThreshold: 5,
+		BreakerOpenTimeout:      30 * time.Second,
+		BreakerSuccessThreshold: 1,
+		BreakerEnabled:          false,
+
+		NotifyTradeExecution:     true,
+		NotifyOrderFilled:        true,
+		NotifyPositionChange:     true,
+		NotifyPnLUpdate:          true,
+		NotifyStopLoss:           true,
+		NotifyTakeProfit:         true,
+		NotifySystemErrors:       true,
+		NotifyStrategyErrors:     true,
+		NotifyServiceStop:        true,
+		NotifyBreakerStateChange: true,
+
+		TimestampFormat: "2006-01-02 15:04:05",
+		TimestampSource: "event",
 
 		ProfitThreshold: 1.0, // 1% profit threshold
 	}
@@ -111,22 +530,32 @@ func LoadConfig(filePath string) (*NotificationConfig, error) {
 
 	// Convert to NotificationConfig
 	config := &NotificationConfig{
-		NotifyTradeExecution: configFile.Events.TradeExecution,
-		NotifyOrderFilled:    configFile.Events.OrderFilled,
-		NotifyPositionChange: configFile.Events.PositionChange,
-		NotifyPnLUpdate:      configFile.Events.PnLUpdate,
-		NotifyStopLoss:       configFile.Events.StopLoss,
-		NotifyTakeProfit:     configFile.Events.TakeProfit,
-		NotifySystemErrors:   configFile.Events.SystemErrors,
-		NotifyStrategyErrors: configFile.Events.StrategyErrors,
-		ProfitThreshold:      configFile.Events.ProfitThreshold,
+		NotifyTradeExecution:     configFile.Events.TradeExecution,
+		NotifyOrderFilled:        configFile.Events.OrderFilled,
+		NotifyPositionChange:     configFile.Events.PositionChange,
+		NotifyPnLUpdate:          configFile.Events.PnLUpdate,
+		NotifyStopLoss:           configFile.Events.StopLoss,
+		NotifyTakeProfit:         configFile.Events.TakeProfit,
+		NotifySystemErrors:       configFile.Events.SystemErrors,
+		NotifyStrategyErrors:     configFile.Events.StrategyErrors,
+		NotifyServiceStop:        configFile.Events.ServiceStop,
+		NotifyBreakerStateChange: configFile.Events.BreakerStateChange,
+		ProfitThreshold:          configFile.Events.ProfitThreshold,
 	}
 
 	// Load Element config if present
 	if configFile.Element != nil {
 		config.ElementHomeserverURL = configFile.Element.HomeserverURL
 		config.ElementAccessToken = configFile.Element.AccessToken
+		config.ElementRefreshToken = configFile.Element.RefreshToken
+		config.ElementUser = configFile.Element.User
+		config.ElementPassword = configFile.Element.Password
+		config.ElementDeviceID = configFile.Element.DeviceID
+		config.ElementAutoJoin = configFile.Element.AutoJoin
 		config.ElementRoomID = configFile.Element.RoomID
+		config.ElementMsgType = configFile.Element.MsgType
+		config.ElementMentions = configFile.Element.Mentions
+		config.ElementMentionSeverity = configFile.Element.MentionSeverity
 		config.ElementEnabled = configFile.Element.Enabled
 	}
 
@@ -134,9 +563,153 @@ func LoadConfig(filePath string) (*NotificationConfig, error) {
 	if configFile.Telegram != nil {
 		config.TelegramBotToken = configFile.Telegram.BotToken
 		config.TelegramChatID = configFile.Telegram.ChatID
+		config.TelegramParseMode = configFile.Telegram.ParseMode
+		config.TelegramDisableWebPagePreview = configFile.Telegram.DisableWebPagePreview
+		config.TelegramDisableNotification = configFile.Telegram.DisableNotification
+		config.TelegramOrderURL = configFile.Telegram.OrderURL
 		config.TelegramEnabled = configFile.Telegram.Enabled
 	}
 
+	// Load Slack config if present
+	if configFile.Slack != nil {
+		config.SlackWebhookURL = configFile.Slack.WebhookURL
+		config.SlackBotToken = configFile.Slack.BotToken
+		config.SlackChannel = configFile.Slack.Channel
+		config.SlackEnabled = configFile.Slack.Enabled
+	}
+
+	// Load Discord config if present
+	if configFile.Discord != nil {
+		config.DiscordWebhookURL = configFile.Discord.WebhookURL
+		config.DiscordUsername = configFile.Discord.Username
+		config.DiscordEnabled = configFile.Discord.Enabled
+	}
+
+	// Load Email config if present
+	if configFile.Email != nil {
+		config.EmailHost = configFile.Email.Host
+		config.EmailPort = configFile.Email.Port
+		config.EmailUsername = configFile.Email.Username
+		config.EmailPassword = configFile.Email.Password
+		config.EmailFrom = configFile.Email.From
+		config.EmailTo = configFile.Email.To
+		config.EmailSecurity = configFile.Email.Security
+		config.EmailAuth = configFile.Email.Auth
+		config.EmailSubjectPrefix = configFile.Email.SubjectPrefix
+		config.EmailEnabled = configFile.Email.Enabled
+	}
+
+	// Load Webhook config if present
+	if configFile.Webhook != nil {
+		config.WebhookURL = configFile.Webhook.URL
+		config.WebhookMethod = configFile.Webhook.Method
+		config.WebhookSecret = configFile.Webhook.Secret
+		config.WebhookSignatureHeader = configFile.Webhook.SignatureHeader
+		config.WebhookHeaders = configFile.Webhook.Headers
+		config.WebhookTimeout = time.Duration(configFile.Webhook.TimeoutSeconds) * time.Second
+		config.WebhookSuccessStatusCodes = configFile.Webhook.SuccessStatusCodes
+		config.WebhookEnabled = configFile.Webhook.Enabled
+	}
+
+	// Load ntfy config if present
+	if configFile.Ntfy != nil {
+		config.NtfyServerURL = configFile.Ntfy.ServerURL
+		config.NtfyTopic = configFile.Ntfy.Topic
+		config.NtfyToken = configFile.Ntfy.Token
+		config.NtfyEnabled = configFile.Ntfy.Enabled
+	}
+
+	// Load Gotify config if present
+	if configFile.Gotify != nil {
+		config.GotifyServerURL = configFile.Gotify.ServerURL
+		config.GotifyAppToken = configFile.Gotify.AppToken
+		config.GotifyEnabled = configFile.Gotify.Enabled
+	}
+
+	// Load PagerDuty config if present
+	if configFile.PagerDuty != nil {
+		config.PagerDutyRoutingKey = configFile.PagerDuty.RoutingKey
+		config.PagerDutySource = configFile.PagerDuty.Source
+		config.PagerDutyEvents = configFile.PagerDuty.Events
+		config.PagerDutyEnabled = configFile.PagerDuty.Enabled
+	}
+
+	// Load Opsgenie config if present
+	if configFile.Opsgenie != nil {
+		config.OpsgenieAPIKey = configFile.Opsgenie.APIKey
+		config.OpsgenieAPIURL = configFile.Opsgenie.APIURL
+		config.OpsgenieEvents = configFile.Opsgenie.Events
+		config.OpsgenieEnabled = configFile.Opsgenie.Enabled
+	}
+
+	// Load delivery config if present
+	if configFile.Delivery != nil {
+		config.DeliveryTimeout = time.Duration(configFile.Delivery.TimeoutSeconds) * time.Second
+		config.DeliveryQueueSize = configFile.Delivery.QueueSize
+		config.DeliveryWorkers = configFile.Delivery.Workers
+		config.DeliveryOverflow = configFile.Delivery.Overflow
+	}
+
+	// Load retry config if present
+	if configFile.Retry != nil {
+		config.RetryMaxAttempts = configFile.Retry.MaxAttempts
+		config.RetryBaseDelay = time.Duration(configFile.Retry.BaseDelayMs) * time.Millisecond
+		config.RetryMaxDelay = time.Duration(configFile.Retry.MaxDelayMs) * time.Millisecond
+		config.RetryJitter = configFile.Retry.Jitter
+	}
+
+	// Load outbox config if present
+	if configFile.Outbox != nil {
+		config.OutboxPath = configFile.Outbox.Path
+		config.OutboxEnabled = configFile.Outbox.Enabled
+	}
+
+	// Load dead-letter config if present
+	if configFile.DeadLetters != nil {
+		config.DeadLetterPath = configFile.DeadLetters.Path
+		config.DeadLetterMaxLetters = configFile.DeadLetters.MaxLetters
+		config.DeadLetterEnabled = configFile.DeadLetters.Enabled
+	}
+
+	// Load circuit breaker config if present
+	if configFile.CircuitBreaker != nil {
+		config.BreakerFailureThreshold = configFile.CircuitBreaker.FailureThreshold
+		config.BreakerOpenTimeout = time.Duration(configFile.CircuitBreaker.OpenTimeoutSeconds) * time.Second
+		config.BreakerSuccessThreshold = configFile.CircuitBreaker.SuccessThreshold
+		config.BreakerEnabled = configFile.CircuitBreaker.Enabled
+	}
+
+	// Load failover chains and routing table
+	config.Failover = configFile.Failover
+	config.Routes = configFile.Routes
+
+	// Load named messenger instances
+	config.Messengers = configFile.Messengers
+
+	// Load formatting config if present
+	if configFile.Formatting != nil {
+		config.ThousandsSeparator = configFile.Formatting.ThousandsSeparator
+		config.KeepTrailingZeros = configFile.Formatting.KeepTrailingZeros
+		config.CurrencySymbols = configFile.Formatting.CurrencySymbols
+		config.Symbols = configFile.Formatting.Symbols
+	}
+
+	// Load timestamp config if present
+	if configFile.Timestamps != nil {
+		config.Timezone = configFile.Timestamps.Timezone
+		config.TimestampFormat = configFile.Timestamps.Format
+		config.TimestampSource = configFile.Timestamps.Source
+	}
+	if _, err := config.Formatter(); err != nil {
+		return nil, err
+	}
+
+	// Load and validate message templates
+	config.Templates = configFile.Templates
+	if _, err := templates.New(config.Templates, nil); err != nil {
+		return nil, fmt.Errorf("invalid notification template: %w", err)
+	}
+
 	return config, nil
 }
 
@@ -145,37 +718,144 @@ func SaveConfig(config *NotificationConfig, filePath string) error {
 	// Convert to ConfigFile
 	configFile := ConfigFile{
 		Events: EventConfig{
-			TradeExecution:  config.NotifyTradeExecution,
-			OrderFilled:     config.NotifyOrderFilled,
-			PositionChange:  config.NotifyPositionChange,
-			PnLUpdate:       config.NotifyPnLUpdate,
-			StopLoss:        config.NotifyStopLoss,
-			TakeProfit:      config.NotifyTakeProfit,
-			SystemErrors:    config.NotifySystemErrors,
-			StrategyErrors:  config.NotifyStrategyErrors,
-			ProfitThreshold: config.ProfitThreshold,
+			TradeExecution:     config.NotifyTradeExecution,
+			OrderFilled:        config.NotifyOrderFilled,
+			PositionChange:     config.NotifyPositionChange,
+			PnLUpdate:          config.NotifyPnLUpdate,
+			StopLoss:           config.NotifyStopLoss,
+			TakeProfit:         config.NotifyTakeProfit,
+			SystemErrors:       config.NotifySystemErrors,
+			StrategyErrors:     config.NotifyStrategyErrors,
+			ServiceStop:        config.NotifyServiceStop,
+			BreakerStateChange: config.NotifyBreakerStateChange,
+			ProfitThreshold:    config.ProfitThreshold,
 		},
 	}
 
-	// Add Element config if enabled
-	if config.ElementEnabled {
-		configFile.Element = &ElementConfig{
-			HomeserverURL: config.ElementHomeserverURL,
-			AccessToken:   config.ElementAccessToken,
-			RoomID:        config.ElementRoomID,
-			Enabled:       config.ElementEnabled,
+	// Add the single messengers of the flat settings
+	flat := config.flatMessengers()
+	configFile.Element = flat.Element
+	configFile.Telegram = flat.Telegram
+	configFile.Slack = flat.Slack
+	configFile.Discord = flat.Discord
+	configFile.Email = flat.Email
+	configFile.Webhook = flat.Webhook
+	configFile.Ntfy = flat.Ntfy
+	configFile.Gotify = flat.Gotify
+	configFile.PagerDuty = flat.PagerDuty
+	configFile.Opsgenie = flat.Opsgenie
+
+	// Add named messenger instances and message templates
+	configFile.Messengers = config.Messengers
+	configFile.Templates = config.Templates
+
+	// Add delivery config if set
+	if config.DeliveryTimeout > 0 || config.DeliveryQueueSize > 0 || config.DeliveryWorkers > 0 || config.DeliveryOverflow != "" {
+		configFile.Delivery = &DeliveryConfig{
+			TimeoutSeconds: int(config.DeliveryTimeout / time.Second),
+			QueueSize:      config.DeliveryQueueSize,
+			Workers:        config.DeliveryWorkers,
+			Overflow:       config.DeliveryOverflow,
 		}
 	}
 
-	// Add Telegram config if enabled
-	if config.TelegramEnabled {
-		configFile.Telegram = &TelegramConfig{
-			BotToken: config.TelegramBotToken,
-			ChatID:   config.TelegramChatID,
-			Enabled:  config.TelegramEnabled,
+	// Add retry config if set
+	if config.RetryMaxAttempts > 0 || config.RetryBaseDelay > 0 || config.RetryMaxDelay > 0 || config.RetryJitter != 0 {
+		configFile.Retry = &RetryConfig{
+			MaxAttempts: config.RetryMaxAttempts,
+			BaseDelayMs: int(config.RetryBaseDelay / time.Millisecond),
+			MaxDelayMs:  int(config.RetryMaxDelay / time.Millisecond),
+			Jitter:      config.RetryJitter,
+		}
+	}
+
+	// Add outbox config if enabled
+	if config.OutboxEnabled {
+		configFile.Outbox = &OutboxConfig{
+			Path:    config.OutboxPath,
+			Enabled: config.OutboxEnabled,
+		}
+	}
+
+	// Add dead-letter config if enabled or limited
+	if config.DeadLetterEnabled || config.DeadLetterMaxLetters > 0 {
+		configFile.DeadLetters = &DeadLetterConfig{
+			Path:       config.DeadLetterPath,
+			MaxLetters: config.DeadLetterMaxLetters,
+			Enabled:    config.DeadLetterEnabled,
+		}
+	}
+
+	// Add circuit breaker config if enabled
+	if config.BreakerEnabled {
+		configFile.CircuitBreaker = &CircuitBreakerConfig{
+			FailureThreshold:   config.BreakerFailureThreshold,
+			OpenTimeoutSeconds: int(config.BreakerOpenTimeout / time.Second),
+			SuccessThreshold:   config.BreakerSuccessThreshold,
+			Enabled:            config.BreakerEnabled,
+		}
+	}
+
+	// Add failover chains and routing table
+	configFile.Failover = config.Failover
+	configFile.Routes = config.Routes
+
+	// Add formatting config if set
+	if config.ThousandsSeparator != "" || config.KeepTrailingZeros || len(config.CurrencySymbols) > 0 || len(config.Symbols) > 0 {
+		configFile.Formatting = &FormattingConfig{
+			ThousandsSeparator: config.ThousandsSeparator,
+			KeepTrailingZeros:  config.KeepTrailingZeros,
+			CurrencySymbols:    config.CurrencySymbols,
+			Symbols:            config.Symbols,
+		}
+	}
+
+	// Add timestamp config if set
+	if config.Timezone != "" || config.TimestampFormat != "" || config.TimestampSource != "" {
+		configFile.Timestamps = &TimestampConfig{
+			Timezone: config.Timezone,
+			Format:   config.TimestampFormat,
+			Source:   config.TimestampSource,
+		}
+	}
+
+	return writeConfigFile(configFile, filePath)
+}
+
+// SaveElementRefreshToken stores the refresh token of the named Element
+// instance, or of the single Element messenger when name is empty, in the
+// configuration file. The rest of the file is kept as it is, including
+// disabled messengers.
+func SaveElementRefreshToken(filePath, name, refreshToken string) error {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read notification config file: %w", err)
+	}
+	var configFile ConfigFile
+	if err := json.Unmarshal(data, &configFile); err != nil {
+		return fmt.Errorf("failed to parse notification config file: %w", err)
+	}
+
+	element := configFile.Element
+	if name != "" {
+		element = nil
+		for _, instance := range configFile.Messengers {
+			if instance.Name == name && instance.Element != nil {
+				element = instance.Element
+				break
+			}
 		}
 	}
+	if element == nil {
+		return fmt.Errorf("no element messenger %q in %s", name, filePath)
+	}
+	element.RefreshToken = refreshToken
+
+	return writeConfigFile(configFile, filePath)
+}
 
+// writeConfigFile writes the configuration file as indented JSON
+func writeConfigFile(configFile ConfigFile, filePath string) error {
 	// Convert to JSON
 	data, err := json.MarshalIndent(configFile, "", "  ")
 	if err != nil {
@@ -190,9 +870,271 @@ func SaveConfig(config *NotificationConfig, filePath string) error {
 	return nil
 }
 
+// flatMessengers returns the messenger sections enabled in the flat settings
+func (c *NotificationConfig) flatMessengers() MessengerConfig {
+	var flat MessengerConfig
+
+	// Add Element config if enabled
+	if c.ElementEnabled {
+		flat.Element = &ElementConfig{
+			HomeserverURL:   c.ElementHomeserverURL,
+			AccessToken:     c.ElementAccessToken,
+			RefreshToken:    c.ElementRefreshToken,
+			User:            c.ElementUser,
+			Password:        c.ElementPassword,
+			DeviceID:        c.ElementDeviceID,
+			AutoJoin:        c.ElementAutoJoin,
+			RoomID:          c.ElementRoomID,
+			MsgType:         c.ElementMsgType,
+			Mentions:        c.ElementMentions,
+			MentionSeverity: c.ElementMentionSeverity,
+			Enabled:         c.ElementEnabled,
+		}
+	}
+
+	// Add Telegram config if enabled
+	if c.TelegramEnabled {
+		flat.Telegram = &TelegramConfig{
+			BotToken:              c.TelegramBotToken,
+			ChatID:                c.TelegramChatID,
+			ParseMode:             c.TelegramParseMode,
+			DisableWebPagePreview: c.TelegramDisableWebPagePreview,
+			DisableNotification:   c.TelegramDisableNotification,
+			OrderURL:              c.TelegramOrderURL,
+			Enabled:               c.TelegramEnabled,
+		}
+	}
+
+	// Add Slack config if enabled
+	if c.SlackEnabled {
+		flat.Slack = &SlackConfig{
+			WebhookURL: c.SlackWebhookURL,
+			BotToken:   c.SlackBotToken,
+			Channel:    c.SlackChannel,
+			Enabled:    c.SlackEnabled,
+		}
+	}
+
+	// Add Discord config if enabled
+	if c.DiscordEnabled {
+		flat.Discord = &DiscordConfig{
+			WebhookURL: c.DiscordWebhookURL,
+			Username:   c.DiscordUsername,
+			Enabled:    c.DiscordEnabled,
+		}
+	}
+
+	// Add Email config if enabled
+	if c.EmailEnabled {
+		flat.Email = &EmailConfig{
+			Host:          c.EmailHost,
+			Port:          c.EmailPort,
+			Username:      c.EmailUsername,
+			Password:      c.EmailPassword,
+			From:          c.EmailFrom,
+			To:            c.EmailTo,
+			Security:      c.EmailSecurity,
+			Auth:          c.EmailAuth,
+			SubjectPrefix: c.EmailSubjectPrefix,
+			Enabled:       c.EmailEnabled,
+		}
+	}
+
+	// Add Webhook config if enabled
+	if c.WebhookEnabled {
+		flat.Webhook = &WebhookConfig{
+			URL:                c.WebhookURL,
+			Method:             c.WebhookMethod,
+			Secret:             c.WebhookSecret,
+			SignatureHeader:    c.WebhookSignatureHeader,
+			Headers:            c.WebhookHeaders,
+			TimeoutSeconds:     int(c.WebhookTimeout / time.Second),
+			SuccessStatusCodes: c.WebhookSuccessStatusCodes,
+			Enabled:            c.WebhookEnabled,
+		}
+	}
+
+	// Add ntfy config if enabled
+	if c.NtfyEnabled {
+		flat.Ntfy = &NtfyConfig{
+			ServerURL: c.NtfyServerURL,
+			Topic:     c.NtfyTopic,
+			Token:     c.NtfyToken,
+			Enabled:   c.NtfyEnabled,
+		}
+	}
+
+	// Add Gotify config if enabled
+	if c.GotifyEnabled {
+		flat.Gotify = &GotifyConfig{
+			ServerURL: c.GotifyServerURL,
+			AppToken:  c.GotifyAppToken,
+			Enabled:   c.GotifyEnabled,
+		}
+	}
+
+	// Add PagerDuty config if enabled
+	if c.PagerDutyEnabled {
+		flat.PagerDuty = &PagerDutyConfig{
+			RoutingKey: c.PagerDutyRoutingKey,
+			Source:     c.PagerDutySource,
+			Events:     c.PagerDutyEvents,
+			Enabled:    c.PagerDutyEnabled,
+		}
+	}
+
+	// Add Opsgenie config if enabled
+	if c.OpsgenieEnabled {
+		flat.Opsgenie = &OpsgenieConfig{
+			APIKey:  c.OpsgenieAPIKey,
+			APIURL:  c.OpsgenieAPIURL,
+			Events:  c.OpsgenieEvents,
+			Enabled: c.OpsgenieEnabled,
+		}
+	}
+
+	return flat
+}
+
+// MessengerConfigs returns every configured messenger instance: one for each
+// messenger enabled in the flat settings, followed by the named instances
+func (c *NotificationConfig) MessengerConfigs() []MessengerConfig {
+	flat := c.flatMessengers()
+
+	var configs []MessengerConfig
+	if flat.Element != nil {
+		configs = append(configs, MessengerConfig{Element: flat.Element})
+	}
+	if flat.Telegram != nil {
+		configs = append(configs, MessengerConfig{Telegram: flat.Telegram})
+	}
+	if flat.Slack != nil {
+		configs = append(configs, MessengerConfig{Slack: flat.Slack})
+	}
+	if flat.Discord != nil {
+		configs = append(configs, MessengerConfig{Discord: flat.Discord})
+	}
+	if flat.Email != nil {
+		configs = append(configs, MessengerConfig{Email: flat.Email})
+	}
+	if flat.Webhook != nil {
+		configs = append(configs, MessengerConfig{Webhook: flat.Webhook})
+	}
+	if flat.Ntfy != nil {
+		configs = append(configs, MessengerConfig{Ntfy: flat.Ntfy})
+	}
+	if flat.Gotify != nil {
+		configs = append(configs, MessengerConfig{Gotify: flat.Gotify})
+	}
+	if flat.PagerDuty != nil {
+		configs = append(configs, MessengerConfig{PagerDuty: flat.PagerDuty})
+	}
+	if flat.Opsgenie != nil {
+		configs = append(configs, MessengerConfig{Opsgenie: flat.Opsgenie})
+	}
+	return append(configs, c.Messengers...)
+}
+
+// Type returns the messenger type of the instance, or an empty string
+// unless exactly one messenger section is set
+func (m MessengerConfig) Type() string {
+	var types []string
+	if m.Element != nil {
+		types = append(types, "element")
+	}
+	if m.Telegram != nil {
+		types = append(types, "telegram")
+	}
+	if m.Slack != nil {
+		types = append(types, "slack")
+	}
+	if m.Discord != nil {
+		types = append(types, "discord")
+	}
+	if m.Email != nil {
+		types = append(types, "email")
+	}
+	if m.Webhook != nil {
+		types = append(types, "webhook")
+	}
+	if m.Ntfy != nil {
+		types = append(types, "ntfy")
+	}
+	if m.Gotify != nil {
+		types = append(types, "gotify")
+	}
+	if m.PagerDuty != nil {
+		types = append(types, "pagerduty")
+	}
+	if m.Opsgenie != nil {
+		types = append(types, "opsgenie")
+	}
+	if len(types) != 1 {
+		return ""
+	}
+	return types[0]
+}
+
+// Enabled reports whether the instance's messenger section is enabled
+func (m MessengerConfig) Enabled() bool {
+	switch m.Type() {
+	case "element":
+		return m.Element.Enabled
+	case "telegram":
+		return m.Telegram.Enabled
+	case "slack":
+		return m.Slack.Enabled
+	case "discord":
+		return m.Discord.Enabled
+	case "email":
+		return m.Email.Enabled
+	case "webhook":
+		return m.Webhook.Enabled
+	case "ntfy":
+		return m.Ntfy.Enabled
+	case "gotify":
+		return m.Gotify.Enabled
+	case "pagerduty":
+		return m.PagerDuty.Enabled
+	case "opsgenie":
+		return m.Opsgenie.Enabled
+	}
+	return false
+}
+
+// Formatter returns the formatter of the formatting and timestamp settings.
+// Symbols takes precedence over PrecisionProvider.
+func (c *NotificationConfig) Formatter() (*format.Formatter, error) {
+	location := time.Local
+	if c.Timezone != "" {
+		var err error
+		if location, err = time.LoadLocation(c.Timezone); err != nil {
+			return nil, fmt.Errorf("unknown timezone %q: %w", c.Timezone, err)
+		}
+	}
+
+	symbols := make(format.Symbols, len(c.Symbols))
+	for name, symbol := range c.Symbols {
+		symbols[name] = format.Precision{
+			PriceDecimals:    format.Decimals(symbol.TickSize),
+			QuantityDecimals: format.Decimals(symbol.StepSize),
+			BaseAsset:        symbol.BaseAsset,
+			QuoteAsset:       symbol.QuoteAsset,
+		}
+	}
+
+	return format.New(format.Options{
+		ThousandsSeparator: c.ThousandsSeparator,
+		KeepTrailingZeros:  c.KeepTrailingZeros,
+		CurrencySymbols:    c.CurrencySymbols,
+		Precision:          format.Providers{symbols, c.PrecisionProvider},
+		Location:           location,
+		TimeLayout:         c.TimestampFormat,
+	}), nil
+}
+
 // CreateDefaultConfigFile creates a default notification configuration file
 func CreateDefaultConfigFile(filePath string) error {
 	config := DefaultNotificationConfig()
 	return SaveConfig(config, filePath)
 }
-
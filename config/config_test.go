@@ -1,8 +1,14 @@
 package config
 
 import (
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/evdnx/gonotify/format"
+	"github.com/evdnx/gonotify/templates"
 )
 
 func TestSaveAndLoadConfig(t *testing.T) {
@@ -10,22 +16,96 @@ func TestSaveAndLoadConfig(t *testing.T) {
 	path := filepath.Join(dir, "notification.json")
 
 	original := &NotificationConfig{
-		ElementHomeserverURL: "https://matrix.org",
-		ElementAccessToken:   "token",
-		ElementRoomID:        "!room:id",
-		ElementEnabled:       true,
-		TelegramBotToken:     "bot_token",
-		TelegramChatID:       "chat_id",
-		TelegramEnabled:      true,
-		NotifyTradeExecution: true,
-		NotifyOrderFilled:    true,
-		NotifyPositionChange: false,
-		NotifyPnLUpdate:      true,
-		NotifyStopLoss:       false,
-		NotifyTakeProfit:     true,
-		NotifySystemErrors:   true,
-		NotifyStrategyErrors: false,
-		ProfitThreshold:      2.5,
+		ElementHomeserverURL:        "https://matrix.org",
+		ElementAccessToken:          "token",
+		ElementRefreshToken:         "refresh",
+		ElementRoomID:               "!room:id",
+		ElementMsgType:              "m.notice",
+		ElementMentions:             []string{"@alice:matrix.org"},
+		ElementMentionSeverity:      "error",
+		ElementUser:                 "@bot:matrix.org",
+		ElementPassword:             "hunter2",
+		ElementDeviceID:             "GONOTIFY",
+		ElementAutoJoin:             true,
+		ElementEnabled:              true,
+		TelegramBotToken:            "bot_token",
+		TelegramChatID:              "chat_id",
+		TelegramParseMode:           "MarkdownV2",
+		TelegramDisableNotification: true,
+		TelegramOrderURL:            "https://example.com/order/{id}",
+		TelegramEnabled:             true,
+		SlackWebhookURL:             "https://hooks.slack.com/services/T/B/X",
+		SlackEnabled:                true,
+		DiscordWebhookURL:           "https://discord.com/api/webhooks/1/abc",
+		DiscordUsername:             "gonotify",
+		DiscordEnabled:              true,
+		EmailHost:                   "smtp.example.com",
+		EmailPort:                   465,
+		EmailFrom:                   "bot@example.com",
+		EmailTo:                     []string{"compliance@example.com"},
+		EmailSecurity:               "tls",
+		EmailEnabled:                true,
+		WebhookURL:                  "https://dashboard.example.com/hook",
+		WebhookSecret:               "s3cret",
+		WebhookHeaders:              map[string]string{"X-Source": "gonotify"},
+		WebhookTimeout:              5 * time.Second,
+		WebhookEnabled:              true,
+		NtfyServerURL:               "https://ntfy.example.com",
+		NtfyTopic:                   "trading",
+		NtfyEnabled:                 true,
+		GotifyServerURL:             "https://gotify.example.com",
+		GotifyAppToken:              "app_token",
+		GotifyEnabled:               true,
+		PagerDutyRoutingKey:         "routing_key",
+		PagerDutyEvents:             []string{"system_error"},
+		PagerDutyEnabled:            true,
+		OpsgenieAPIKey:              "api_key",
+		OpsgenieEnabled:             true,
+		DeliveryTimeout:             15 * time.Second,
+		DeliveryQueueSize:           50,
+		DeliveryWorkers:             2,
+		DeliveryOverflow:            "block",
+		RetryMaxAttempts:            5,
+		RetryBaseDelay:              500 * time.Millisecond,
+		RetryMaxDelay:               time.Minute,
+		RetryJitter:                 0.1,
+		OutboxPath:                  "data/outbox.jsonl",
+		OutboxEnabled:               true,
+		DeadLetterPath:              "data/dead_letters.jsonl",
+		DeadLetterEnabled:           true,
+		DeadLetterMaxLetters:        500,
+		BreakerFailureThreshold:     3,
+		BreakerOpenTimeout:          time.Minute,
+		BreakerSuccessThreshold:     2,
+		BreakerEnabled:              true,
+		Failover:                    map[string][]string{"Telegram": {"Element", "Email"}},
+		Messengers: []MessengerConfig{
+			{Name: "Account A", Telegram: &TelegramConfig{BotToken: "bot_token_a", ChatID: "chat_a", Enabled: true}},
+		},
+		Routes: []RouteConfig{
+			{Events: []string{"order_filled"}, Symbols: []string{"BTCUSDT"}, Messengers: []string{"Telegram"}},
+			{Events: []string{"strategy_error"}, Messengers: []string{"Element"}},
+		},
+		ThousandsSeparator: ",",
+		CurrencySymbols:    map[string]string{"USDT": "$"},
+		Timezone:           "Europe/Berlin",
+		TimestampFormat:    "15:04:05 MST",
+		TimestampSource:    "both",
+		Symbols:            map[string]SymbolConfig{"SHIBUSDT": {TickSize: 0.00000001, StepSize: 1}},
+		Templates: map[string]templates.Template{
+			"order_filled": {Title: "{{.Emoji}} {{.Data.Symbol}} filled"},
+		},
+		NotifyTradeExecution:     true,
+		NotifyOrderFilled:        true,
+		NotifyPositionChange:     false,
+		NotifyPnLUpdate:          true,
+		NotifyStopLoss:           false,
+		NotifyTakeProfit:         true,
+		NotifySystemErrors:       true,
+		NotifyStrategyErrors:     false,
+		NotifyServiceStop:        true,
+		NotifyBreakerStateChange: true,
+		ProfitThreshold:          2.5,
 	}
 
 	if err := SaveConfig(original, path); err != nil {
@@ -39,11 +119,74 @@ func TestSaveAndLoadConfig(t *testing.T) {
 
 	if loaded.ElementHomeserverURL != original.ElementHomeserverURL ||
 		loaded.ElementAccessToken != original.ElementAccessToken ||
+		loaded.ElementRefreshToken != original.ElementRefreshToken ||
 		loaded.ElementRoomID != original.ElementRoomID ||
+		loaded.ElementMsgType != original.ElementMsgType ||
+		len(loaded.ElementMentions) != 1 || loaded.ElementMentions[0] != "@alice:matrix.org" ||
+		loaded.ElementMentionSeverity != original.ElementMentionSeverity ||
+		loaded.ElementUser != original.ElementUser ||
+		loaded.ElementPassword != original.ElementPassword ||
+		loaded.ElementDeviceID != original.ElementDeviceID ||
+		loaded.ElementAutoJoin != original.ElementAutoJoin ||
 		loaded.ElementEnabled != original.ElementEnabled ||
 		loaded.TelegramBotToken != original.TelegramBotToken ||
 		loaded.TelegramChatID != original.TelegramChatID ||
+		loaded.TelegramParseMode != original.TelegramParseMode ||
+		loaded.TelegramDisableWebPagePreview != original.TelegramDisableWebPagePreview ||
+		loaded.TelegramDisableNotification != original.TelegramDisableNotification ||
+		loaded.TelegramOrderURL != original.TelegramOrderURL ||
 		loaded.TelegramEnabled != original.TelegramEnabled ||
+		loaded.SlackWebhookURL != original.SlackWebhookURL ||
+		loaded.SlackEnabled != original.SlackEnabled ||
+		loaded.DiscordWebhookURL != original.DiscordWebhookURL ||
+		loaded.DiscordUsername != original.DiscordUsername ||
+		loaded.DiscordEnabled != original.DiscordEnabled ||
+		loaded.EmailHost != original.EmailHost ||
+		loaded.EmailPort != original.EmailPort ||
+		loaded.EmailFrom != original.EmailFrom ||
+		len(loaded.EmailTo) != 1 || loaded.EmailTo[0] != original.EmailTo[0] ||
+		loaded.EmailSecurity != original.EmailSecurity ||
+		loaded.EmailEnabled != original.EmailEnabled ||
+		loaded.WebhookURL != original.WebhookURL ||
+		loaded.WebhookSecret != original.WebhookSecret ||
+		loaded.WebhookHeaders["X-Source"] != original.WebhookHeaders["X-Source"] ||
+		loaded.WebhookTimeout != original.WebhookTimeout ||
+		loaded.WebhookEnabled != original.WebhookEnabled ||
+		loaded.NtfyServerURL != original.NtfyServerURL ||
+		loaded.NtfyTopic != original.NtfyTopic ||
+		loaded.NtfyEnabled != original.NtfyEnabled ||
+		loaded.GotifyServerURL != original.GotifyServerURL ||
+		loaded.GotifyAppToken != original.GotifyAppToken ||
+		loaded.GotifyEnabled != original.GotifyEnabled ||
+		loaded.PagerDutyRoutingKey != original.PagerDutyRoutingKey ||
+		len(loaded.PagerDutyEvents) != 1 || loaded.PagerDutyEvents[0] != original.PagerDutyEvents[0] ||
+		loaded.PagerDutyEnabled != original.PagerDutyEnabled ||
+		loaded.OpsgenieAPIKey != original.OpsgenieAPIKey ||
+		loaded.OpsgenieEnabled != original.OpsgenieEnabled ||
+		loaded.DeliveryTimeout != original.DeliveryTimeout ||
+		loaded.DeliveryQueueSize != original.DeliveryQueueSize ||
+		loaded.DeliveryWorkers != original.DeliveryWorkers ||
+		loaded.DeliveryOverflow != original.DeliveryOverflow ||
+		loaded.RetryMaxAttempts != original.RetryMaxAttempts ||
+		loaded.RetryBaseDelay != original.RetryBaseDelay ||
+		loaded.RetryMaxDelay != original.RetryMaxDelay ||
+		loaded.RetryJitter != original.RetryJitter ||
+		loaded.OutboxPath != original.OutboxPath ||
+		loaded.OutboxEnabled != original.OutboxEnabled ||
+		loaded.DeadLetterPath != original.DeadLetterPath ||
+		loaded.DeadLetterMaxLetters != original.DeadLetterMaxLetters ||
+		loaded.DeadLetterEnabled != original.DeadLetterEnabled ||
+		loaded.BreakerFailureThreshold != original.BreakerFailureThreshold ||
+		loaded.BreakerOpenTimeout != original.BreakerOpenTimeout ||
+		loaded.BreakerSuccessThreshold != original.BreakerSuccessThreshold ||
+		loaded.BreakerEnabled != original.BreakerEnabled ||
+		len(loaded.Failover["Telegram"]) != 2 || loaded.Failover["Telegram"][1] != "Email" ||
+		len(loaded.Messengers) != 1 || loaded.Messengers[0].Name != "Account A" || loaded.Messengers[0].Type() != "telegram" || loaded.Messengers[0].Telegram.BotToken != "bot_token_a" ||
+		len(loaded.Routes) != 2 || loaded.Routes[0].Symbols[0] != "BTCUSDT" || loaded.Routes[1].Messengers[0] != "Element" ||
+		loaded.Templates["order_filled"].Title != original.Templates["order_filled"].Title ||
+		loaded.ThousandsSeparator != original.ThousandsSeparator ||
+		loaded.Timezone != original.Timezone || loaded.TimestampFormat != original.TimestampFormat || loaded.TimestampSource != original.TimestampSource ||
+		loaded.CurrencySymbols["USDT"] != "$" || loaded.Symbols["SHIBUSDT"].TickSize != 0.00000001 ||
 		loaded.NotifyTradeExecution != original.NotifyTradeExecution ||
 		loaded.NotifyOrderFilled != original.NotifyOrderFilled ||
 		loaded.NotifyPositionChange != original.NotifyPositionChange ||
@@ -52,8 +195,122 @@ func TestSaveAndLoadConfig(t *testing.T) {
 		loaded.NotifyTakeProfit != original.NotifyTakeProfit ||
 		loaded.NotifySystemErrors != original.NotifySystemErrors ||
 		loaded.NotifyStrategyErrors != original.NotifyStrategyErrors ||
+		loaded.NotifyServiceStop != original.NotifyServiceStop ||
+		loaded.NotifyBreakerStateChange != original.NotifyBreakerStateChange ||
 		loaded.ProfitThreshold != original.ProfitThreshold {
 		t.Fatal("loaded config does not match original")
 	}
 }
 
+func TestMessengerConfigs(t *testing.T) {
+	cfg := &NotificationConfig{
+		TelegramBotToken: "bot_token",
+		TelegramChatID:   "chat_id",
+		TelegramEnabled:  true,
+		NtfyTopic:        "trading",
+		Messengers: []MessengerConfig{
+			{Name: "Account A", Telegram: &TelegramConfig{BotToken: "bot_token_a", ChatID: "chat_a", Enabled: true}},
+			{Name: "Paused", Telegram: &TelegramConfig{BotToken: "bot_token_b", ChatID: "chat_b"}},
+		},
+	}
+
+	configs := cfg.MessengerConfigs()
+	if len(configs) != 3 {
+		t.Fatalf("expected 3 messenger configs, got %d", len(configs))
+	}
+	if configs[0].Name != "" || configs[0].Type() != "telegram" || configs[0].Telegram.ChatID != "chat_id" || !configs[0].Enabled() {
+		t.Fatalf("unexpected flat messenger config: %+v", configs[0])
+	}
+	if configs[1].Name != "Account A" || !configs[1].Enabled() {
+		t.Fatalf("unexpected named messenger config: %+v", configs[1])
+	}
+	if configs[2].Enabled() {
+		t.Fatal("expected disabled instance to report disabled")
+	}
+}
+
+func TestLoadConfigRejectsInvalidTemplate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notification.json")
+	data := `{"templates": {"trade_executed": {"body": "{{.Data.Sybmol}}"}}, "events": {}}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	_, err := LoadConfig(path)
+	if err == nil || !strings.Contains(err.Error(), "invalid body template for trade_executed") {
+		t.Fatalf("expected invalid template error, got %v", err)
+	}
+}
+
+func TestSaveElementRefreshToken(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notification.json")
+	data := `{
+		"element": {"homeserver_url": "https://matrix.org", "refresh_token": "old", "room_id": "!a:matrix.org", "enabled": true},
+		"telegram": {"bot_token": "bot_token", "chat_id": "chat_id", "enabled": false},
+		"messengers": [{"name": "Ops", "element": {"homeserver_url": "https://matrix.org", "refresh_token": "old_ops", "room_id": "!b:matrix.org", "enabled": true}}],
+		"events": {}
+	}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	if err := SaveElementRefreshToken(path, "", "new"); err != nil {
+		t.Fatalf("failed to save refresh token: %v", err)
+	}
+	if err := SaveElementRefreshToken(path, "Ops", "new_ops"); err != nil {
+		t.Fatalf("failed to save refresh token of named instance: %v", err)
+	}
+	if err := SaveElementRefreshToken(path, "Missing", "token"); err == nil {
+		t.Fatal("expected an error for an unknown instance")
+	}
+
+	loaded, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if loaded.ElementRefreshToken != "new" || loaded.Messengers[0].Element.RefreshToken != "new_ops" {
+		t.Fatalf("expected the new refresh tokens, got %q and %q", loaded.ElementRefreshToken, loaded.Messengers[0].Element.RefreshToken)
+	}
+	if loaded.TelegramBotToken != "bot_token" {
+		t.Fatal("expected the disabled telegram section to be kept")
+	}
+}
+
+type exchangeInfo map[string]format.Precision
+
+func (e exchangeInfo) Precision(symbol string) (format.Precision, bool) {
+	p, ok := e[symbol]
+	return p, ok
+}
+
+func TestFormatter(t *testing.T) {
+	cfg := &NotificationConfig{
+		ThousandsSeparator: ",",
+		Symbols:            map[string]SymbolConfig{"SHIBUSDT": {TickSize: 0.00000001, StepSize: 1, BaseAsset: "SHIB"}},
+		PrecisionProvider: exchangeInfo{
+			"SHIBUSDT": {PriceDecimals: 2},
+			"ETHUSDT":  {PriceDecimals: 1, QuantityDecimals: 3},
+		},
+	}
+	f, err := cfg.Formatter()
+	if err != nil {
+		t.Fatalf("failed to create formatter: %v", err)
+	}
+
+	if got := f.Price("SHIBUSDT", 0.00001234); got != "0.00001234" {
+		t.Errorf("expected configured tick size to win, got %q", got)
+	}
+	if got := f.Quantity("SHIBUSDT", 1500000.4); got != "1,500,000 SHIB" {
+		t.Errorf("unexpected quantity %q", got)
+	}
+	if got := f.Quantity("ETHUSDT", 1.23456); got != "1.235" {
+		t.Errorf("expected provider precision, got %q", got)
+	}
+}
+
+func TestFormatterRejectsUnknownTimezone(t *testing.T) {
+	cfg := &NotificationConfig{Timezone: "Europe/Atlantis"}
+	if _, err := cfg.Formatter(); err == nil || !strings.Contains(err.Error(), `unknown timezone "Europe/Atlantis"`) {
+		t.Fatalf("expected unknown timezone error, got %v", err)
+	}
+}
@@ -17,6 +17,14 @@ const (
 	EventPnLUpdate      EventType = "pnl_update"
 	EventSystemError    EventType = "system_error"
 	EventStrategyError  EventType = "strategy_error"
+
+	// Recovery events resolve incidents opened by the matching error event.
+	EventSystemRecovered   EventType = "system_recovered"
+	EventStrategyRecovered EventType = "strategy_recovered"
+
+	// EventBreakerStateChanged reports a messenger's circuit breaker opening,
+	// closing or probing for recovery.
+	EventBreakerStateChanged EventType = "breaker_state_changed"
 )
 
 // Event encapsulates a payload broadcast on the EventBus.
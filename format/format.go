@@ -0,0 +1,278 @@
+// Package format renders prices, quantities and amounts in notifications
+// with the precision of the symbol they belong to, and times in the zone and
+// layout readers expect.
+package format
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultTimeLayout is the layout of times when none is configured
+const DefaultTimeLayout = "2006-01-02 15:04:05"
+
+// Default decimals for symbols without a known precision. Values below 1
+// get more decimals, enough to show significantDigits significant digits.
+const (
+	defaultPriceDecimals    = 2
+	defaultQuantityDecimals = 6
+	defaultAmountDecimals   = 2
+	significantDigits       = 4
+	maxDecimals             = 12
+)
+
+// Precision describes how a trading symbol's prices and quantities are
+// formatted. Negative decimals fall back to the defaults, and empty assets
+// are left out of the formatted values.
+type Precision struct {
+	PriceDecimals    int
+	QuantityDecimals int
+	BaseAsset        string
+	QuoteAsset       string
+}
+
+// PrecisionProvider looks up the precision of a trading symbol, e.g. from an
+// exchange's symbol information
+type PrecisionProvider interface {
+	Precision(symbol string) (Precision, bool)
+}
+
+// Symbols is a PrecisionProvider backed by a fixed table. Symbols are
+// matched ignoring case.
+type Symbols map[string]Precision
+
+// Precision returns the precision of the symbol
+func (s Symbols) Precision(symbol string) (Precision, bool) {
+	if p, ok := s[symbol]; ok {
+		return p, true
+	}
+	for name, p := range s {
+		if strings.EqualFold(name, symbol) {
+			return p, true
+		}
+	}
+	return Precision{}, false
+}
+
+// Providers consults each provider in turn and returns the first precision
+// found
+type Providers []PrecisionProvider
+
+// Precision returns the precision of the symbol
+func (p Providers) Precision(symbol string) (Precision, bool) {
+	for _, provider := range p {
+		if provider == nil {
+			continue
+		}
+		if precision, ok := provider.Precision(symbol); ok {
+			return precision, true
+		}
+	}
+	return Precision{}, false
+}
+
+// Decimals returns the number of decimals of a tick or step size, such as 2
+// for 0.01, or -1 for sizes of zero or less
+func Decimals(size float64) int {
+	if size <= 0 {
+		return -1
+	}
+	s := strconv.FormatFloat(size, 'f', -1, 64)
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		return len(s) - i - 1
+	}
+	return 0
+}
+
+// Options configure a Formatter
+type Options struct {
+	// ThousandsSeparator groups the integer digits, e.g. "," for 68,000
+	ThousandsSeparator string
+
+	// KeepTrailingZeros keeps the zeros that pad values to their precision
+	KeepTrailingZeros bool
+
+	// CurrencySymbols replaces asset suffixes with a prefix, e.g. "$" for
+	// USDT turns "68000 USDT" into "$68000"
+	CurrencySymbols map[string]string
+
+	// Precision looks up the precision of each symbol; nil uses defaults
+	Precision PrecisionProvider
+
+	// Location is the zone times are shown in; nil uses the local zone
+	Location *time.Location
+
+	// TimeLayout is the Go reference layout of times, DefaultTimeLayout
+	// when empty
+	TimeLayout string
+}
+
+// Formatter formats the numbers of notifications. A nil Formatter formats
+// with the default options.
+type Formatter struct {
+	options Options
+}
+
+// New creates a Formatter with the given options
+func New(options Options) *Formatter {
+	return &Formatter{options: options}
+}
+
+// Price formats a price of the symbol. The asset defaults to the symbol's
+// quote asset.
+func (f *Formatter) Price(symbol string, value float64, asset ...string) string {
+	precision, ok := f.precision(symbol)
+	decimals := defaultDecimals(value, defaultPriceDecimals)
+	if ok && precision.PriceDecimals >= 0 {
+		decimals = precision.PriceDecimals
+	}
+	return f.withAsset(f.Number(value, decimals), firstAsset(asset, precision.QuoteAsset))
+}
+
+// Quantity formats a quantity of the symbol. The asset defaults to the
+// symbol's base asset.
+func (f *Formatter) Quantity(symbol string, value float64, asset ...string) string {
+	precision, ok := f.precision(symbol)
+	decimals := defaultDecimals(value, defaultQuantityDecimals)
+	if ok && precision.QuantityDecimals >= 0 {
+		decimals = precision.QuantityDecimals
+	}
+	return f.withAsset(f.Number(value, decimals), firstAsset(asset, precision.BaseAsset))
+}
+
+// Amount formats an amount that belongs to no symbol, such as a fee or a
+// profit, in the optional asset
+func (f *Formatter) Amount(value float64, asset ...string) string {
+	return f.withAsset(f.Number(value, defaultDecimals(value, defaultAmountDecimals)), firstAsset(asset, ""))
+}
+
+// Number formats a value with the given decimals, trimming trailing zeros
+// and grouping thousands as configured
+func (f *Formatter) Number(value float64, decimals int) string {
+	options := f.opts()
+
+	s := strconv.FormatFloat(value, 'f', decimals, 64)
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+
+	integer, fraction, _ := strings.Cut(s, ".")
+	if !options.KeepTrailingZeros {
+		fraction = strings.TrimRight(fraction, "0")
+	}
+	if strings.Trim(integer+fraction, "0") == "" {
+		sign = "" // no negative zero
+	}
+
+	if options.ThousandsSeparator != "" {
+		integer = group(integer, options.ThousandsSeparator)
+	}
+	if fraction != "" {
+		return sign + integer + "." + fraction
+	}
+	return sign + integer
+}
+
+// Time formats a time in the configured zone with the given layout, or the
+// configured one. Zero times format as an empty string.
+func (f *Formatter) Time(t time.Time, layout ...string) string {
+	if t.IsZero() {
+		return ""
+	}
+	options := f.opts()
+
+	format := options.TimeLayout
+	if len(layout) > 0 && layout[0] != "" {
+		format = layout[0]
+	}
+	if format == "" {
+		format = DefaultTimeLayout
+	}
+	if options.Location != nil {
+		t = t.In(options.Location)
+	} else {
+		t = t.Local()
+	}
+	return t.Format(format)
+}
+
+// withAsset adds the asset's currency symbol or name to a formatted value
+func (f *Formatter) withAsset(value, asset string) string {
+	if asset == "" {
+		return value
+	}
+	for name, symbol := range f.opts().CurrencySymbols {
+		if !strings.EqualFold(name, asset) {
+			continue
+		}
+		if strings.HasPrefix(value, "-") {
+			return "-" + symbol + value[1:]
+		}
+		return symbol + value
+	}
+	return value + " " + asset
+}
+
+// precision looks up the precision of a symbol
+func (f *Formatter) precision(symbol string) (Precision, bool) {
+	provider := f.opts().Precision
+	if provider == nil || symbol == "" {
+		return Precision{}, false
+	}
+	return provider.Precision(symbol)
+}
+
+// opts returns the formatter's options, which are zero for a nil Formatter
+func (f *Formatter) opts() Options {
+	if f == nil {
+		return Options{}
+	}
+	return f.options
+}
+
+// defaultDecimals returns the decimals of a value without a known precision:
+// base for values of at least 1, more for smaller values so their
+// significant digits show
+func defaultDecimals(value float64, base int) int {
+	value = math.Abs(value)
+	if value >= 1 || value == 0 || math.IsNaN(value) || math.IsInf(value, 0) {
+		return base
+	}
+	decimals := int(-math.Floor(math.Log10(value))) + significantDigits - 1
+	if decimals > maxDecimals {
+		decimals = maxDecimals
+	}
+	return max(decimals, base)
+}
+
+// firstAsset returns the first non-empty asset given, or fallback
+func firstAsset(assets []string, fallback string) string {
+	for _, asset := range assets {
+		if asset != "" {
+			return asset
+		}
+	}
+	return fallback
+}
+
+// group inserts sep between every three digits of an integer
+func group(integer, sep string) string {
+	if len(integer) <= 3 {
+		return integer
+	}
+	var b strings.Builder
+	head := len(integer) % 3
+	if head > 0 {
+		b.WriteString(integer[:head])
+	}
+	for i := head; i < len(integer); i += 3 {
+		if b.Len() > 0 {
+			b.WriteString(sep)
+		}
+		b.WriteString(integer[i : i+3])
+	}
+	return b.String()
+}
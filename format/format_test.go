@@ -0,0 +1,99 @@
+package format
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDefaults(t *testing.T) {
+	var f *Formatter
+
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"price", f.Price("BTCUSDT", 68000, "USDT"), "68000 USDT"},
+		{"price with cents", f.Price("BTCUSDT", 68000.5), "68000.5"},
+		{"small price", f.Price("SHIBUSDT", 0.00001234, "USDT"), "0.00001234 USDT"},
+		{"quantity", f.Quantity("BTCUSDT", 0.5, "BTC"), "0.5 BTC"},
+		{"tiny quantity", f.Quantity("BTCUSDT", 0.0000001234), "0.0000001234"},
+		{"amount", f.Amount(-12.345), "-12.35"},
+		{"rounded to zero", f.Number(-0.001, 2), "0"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, tt.got)
+		}
+	}
+}
+
+func TestSymbolPrecision(t *testing.T) {
+	f := New(Options{
+		ThousandsSeparator: ",",
+		CurrencySymbols:    map[string]string{"USDT": "$"},
+		Precision: Providers{
+			nil,
+			Symbols{"BTCUSDT": {PriceDecimals: Decimals(0.1), QuantityDecimals: Decimals(0.00001), BaseAsset: "BTC", QuoteAsset: "USDT"}},
+		},
+	})
+
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"price", f.Price("btcusdt", 1234567.89), "$1,234,567.9"},
+		{"negative price", f.Price("BTCUSDT", -950), "-$950"},
+		{"quantity", f.Quantity("BTCUSDT", 0.1234567), "0.12346 BTC"},
+		{"explicit asset", f.Quantity("BTCUSDT", 2, "XBT"), "2 XBT"},
+		{"unknown symbol", f.Price("ETHUSDT", 2000.5), "2,000.5"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, tt.got)
+		}
+	}
+}
+
+func TestKeepTrailingZeros(t *testing.T) {
+	f := New(Options{
+		KeepTrailingZeros: true,
+		Precision:         Symbols{"ETHUSDT": {PriceDecimals: 2, QuantityDecimals: Decimals(0)}},
+	})
+
+	if got := f.Price("ETHUSDT", 2000); got != "2000.00" {
+		t.Errorf("expected padded price, got %q", got)
+	}
+	if got := f.Quantity("ETHUSDT", 1.5); got != "1.500000" {
+		t.Errorf("expected default quantity decimals, got %q", got)
+	}
+}
+
+func TestDecimals(t *testing.T) {
+	sizes := map[float64]int{0.01: 2, 0.00000001: 8, 1: 0, 10: 0, 0.5: 1, 0: -1}
+	for size, want := range sizes {
+		if got := Decimals(size); got != want {
+			t.Errorf("Decimals(%v): expected %d, got %d", size, want, got)
+		}
+	}
+}
+
+func TestTime(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("time zone database unavailable: %v", err)
+	}
+	f := New(Options{Location: berlin, TimeLayout: "02.01.2006 15:04 MST"})
+	at := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+
+	if got := f.Time(at); got != "01.07.2024 14:00 CEST" {
+		t.Errorf("unexpected time %q", got)
+	}
+	if got := f.Time(at, "15:04:05"); got != "14:00:00" {
+		t.Errorf("expected explicit layout, got %q", got)
+	}
+	if got := f.Time(time.Time{}); got != "" {
+		t.Errorf("expected empty zero time, got %q", got)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"sync"
 
 	"github.com/evdnx/gonotify/config"
 	"github.com/evdnx/gonotify/eventbus"
@@ -73,6 +75,20 @@ func InitializeNotificationSystem(eventBus *eventbus.EventBus, configPath string
 		}
 	}
 
+	if token := os.Getenv("ELEMENT_REFRESH_TOKEN"); token != "" {
+		cfg.ElementRefreshToken = token
+		if cfg.ElementHomeserverURL != "" && cfg.ElementRoomID != "" {
+			cfg.ElementEnabled = true
+		}
+	}
+
+	if password := os.Getenv("ELEMENT_PASSWORD"); password != "" {
+		cfg.ElementPassword = password
+		if cfg.ElementHomeserverURL != "" && cfg.ElementUser != "" && cfg.ElementRoomID != "" {
+			cfg.ElementEnabled = true
+		}
+	}
+
 	if token := os.Getenv("TELEGRAM_BOT_TOKEN"); token != "" {
 		cfg.TelegramBotToken = token
 		if chatID := os.Getenv("TELEGRAM_CHAT_ID"); chatID != "" {
@@ -81,6 +97,51 @@ func InitializeNotificationSystem(eventBus *eventbus.EventBus, configPath string
 		}
 	}
 
+	if webhookURL := os.Getenv("SLACK_WEBHOOK_URL"); webhookURL != "" {
+		cfg.SlackWebhookURL = webhookURL
+		cfg.SlackEnabled = true
+	} else if token := os.Getenv("SLACK_BOT_TOKEN"); token != "" {
+		cfg.SlackBotToken = token
+		if channel := os.Getenv("SLACK_CHANNEL"); channel != "" {
+			cfg.SlackChannel = channel
+			cfg.SlackEnabled = true
+		}
+	}
+
+	if webhookURL := os.Getenv("DISCORD_WEBHOOK_URL"); webhookURL != "" {
+		cfg.DiscordWebhookURL = webhookURL
+		cfg.DiscordEnabled = true
+	}
+
+	if password := os.Getenv("EMAIL_SMTP_PASSWORD"); password != "" {
+		cfg.EmailPassword = password
+	}
+
+	if secret := os.Getenv("WEBHOOK_SECRET"); secret != "" {
+		cfg.WebhookSecret = secret
+	}
+
+	if token := os.Getenv("NTFY_TOKEN"); token != "" {
+		cfg.NtfyToken = token
+	}
+
+	if token := os.Getenv("GOTIFY_APP_TOKEN"); token != "" {
+		cfg.GotifyAppToken = token
+		if cfg.GotifyServerURL != "" {
+			cfg.GotifyEnabled = true
+		}
+	}
+
+	if key := os.Getenv("PAGERDUTY_ROUTING_KEY"); key != "" {
+		cfg.PagerDutyRoutingKey = key
+		cfg.PagerDutyEnabled = true
+	}
+
+	if key := os.Getenv("OPSGENIE_API_KEY"); key != "" {
+		cfg.OpsgenieAPIKey = key
+		cfg.OpsgenieEnabled = true
+	}
+
 	// If telegram is not already enabled/configured, use function parameters if provided
 	if !cfg.TelegramEnabled && telegramBotToken != "" && telegramChatID != "" {
 		cfg.TelegramBotToken = telegramBotToken
@@ -88,15 +149,18 @@ func InitializeNotificationSystem(eventBus *eventbus.EventBus, configPath string
 		cfg.TelegramEnabled = true
 	}
 
-	// Validate at least one messenger is configured
-	if !cfg.ElementEnabled && !cfg.TelegramEnabled {
+	// Validate at least one messenger is configured, counting the named
+	// instances as well as the single ones
+	if !slices.ContainsFunc(cfg.MessengerConfigs(), config.MessengerConfig.Enabled) {
 		return nil, fmt.Errorf("at least one messenger must be enabled. Please update %s or set environment variables", configPath)
 	}
 
 	// Validate Element config if enabled
 	if cfg.ElementEnabled {
-		if cfg.ElementAccessToken == "" || cfg.ElementAccessToken == "YOUR_ELEMENT_ACCESS_TOKEN" {
-			return nil, fmt.Errorf("Element access token not provided. Please update %s or set ELEMENT_ACCESS_TOKEN environment variable", configPath)
+		hasToken := (cfg.ElementAccessToken != "" && cfg.ElementAccessToken != "YOUR_ELEMENT_ACCESS_TOKEN") || cfg.ElementRefreshToken != ""
+		hasPassword := cfg.ElementUser != "" && cfg.ElementPassword != ""
+		if !hasToken && !hasPassword {
+			return nil, fmt.Errorf("Element credentials not provided. Please set a user and password, access token or refresh token in %s, or set ELEMENT_PASSWORD, ELEMENT_ACCESS_TOKEN or ELEMENT_REFRESH_TOKEN environment variable", configPath)
 		}
 		if cfg.ElementHomeserverURL == "" {
 			return nil, fmt.Errorf("Element homeserver URL not provided in %s", configPath)
@@ -116,6 +180,77 @@ func InitializeNotificationSystem(eventBus *eventbus.EventBus, configPath string
 		}
 	}
 
+	// Validate Slack config if enabled
+	if cfg.SlackEnabled && cfg.SlackWebhookURL == "" {
+		if cfg.SlackBotToken == "" {
+			return nil, fmt.Errorf("Slack webhook URL or bot token not provided. Please update %s or set SLACK_WEBHOOK_URL environment variable", configPath)
+		}
+		if cfg.SlackChannel == "" {
+			return nil, fmt.Errorf("Slack channel not provided. Please update %s or set SLACK_CHANNEL environment variable", configPath)
+		}
+	}
+
+	// Validate Discord config if enabled
+	if cfg.DiscordEnabled && cfg.DiscordWebhookURL == "" {
+		return nil, fmt.Errorf("Discord webhook URL not provided. Please update %s or set DISCORD_WEBHOOK_URL environment variable", configPath)
+	}
+
+	// Validate Email config if enabled
+	if cfg.EmailEnabled {
+		if cfg.EmailHost == "" {
+			return nil, fmt.Errorf("Email SMTP host not provided in %s", configPath)
+		}
+		if cfg.EmailFrom == "" || len(cfg.EmailTo) == 0 {
+			return nil, fmt.Errorf("Email sender and recipients must be provided in %s", configPath)
+		}
+	}
+
+	// Validate Webhook config if enabled
+	if cfg.WebhookEnabled && cfg.WebhookURL == "" {
+		return nil, fmt.Errorf("Webhook URL not provided in %s", configPath)
+	}
+
+	// Validate ntfy config if enabled
+	if cfg.NtfyEnabled && cfg.NtfyTopic == "" {
+		return nil, fmt.Errorf("ntfy topic not provided in %s", configPath)
+	}
+
+	// Validate Gotify config if enabled
+	if cfg.GotifyEnabled {
+		if cfg.GotifyServerURL == "" {
+			return nil, fmt.Errorf("Gotify server URL not provided in %s", configPath)
+		}
+		if cfg.GotifyAppToken == "" {
+			return nil, fmt.Errorf("Gotify app token not provided. Please update %s or set GOTIFY_APP_TOKEN environment variable", configPath)
+		}
+	}
+
+	// Validate PagerDuty config if enabled
+	if cfg.PagerDutyEnabled && cfg.PagerDutyRoutingKey == "" {
+		return nil, fmt.Errorf("PagerDuty routing key not provided. Please update %s or set PAGERDUTY_ROUTING_KEY environment variable", configPath)
+	}
+
+	// Validate Opsgenie config if enabled
+	if cfg.OpsgenieEnabled && cfg.OpsgenieAPIKey == "" {
+		return nil, fmt.Errorf("Opsgenie API key not provided. Please update %s or set OPSGENIE_API_KEY environment variable", configPath)
+	}
+
+	// Store refresh tokens rotated by Element homeservers, so the next start
+	// doesn't present a used one. A token from the environment is not stored,
+	// since the environment would override it again.
+	var saveTokens sync.Mutex
+	envRefreshToken := os.Getenv("ELEMENT_REFRESH_TOKEN") != ""
+	cfg.OnElementRefreshToken = func(name, refreshToken string) {
+		if name == "" && envRefreshToken {
+			return
+		}
+		saveTokens.Lock()
+		defer saveTokens.Unlock()
+		if err := config.SaveElementRefreshToken(configPath, name, refreshToken); err != nil {
+			fmt.Printf("Failed to store Element refresh token: %v\n", err)
+		}
+	}
+
 	// Create notification service
 	notificationService, err := service.NewNotificationService(cfg, eventBus)
 	if err != nil {
@@ -0,0 +1,70 @@
+package gonotify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/evdnx/gonotify/eventbus"
+)
+
+func TestInitializeNotificationSystemWithNamedMessengersOnly(t *testing.T) {
+	received := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- string(body)
+	}))
+	defer server.Close()
+
+	configFile := map[string]interface{}{
+		"messengers": []map[string]interface{}{
+			{"name": "Ops Hook", "webhook": map[string]interface{}{"url": server.URL, "enabled": true}},
+		},
+	}
+	data, err := json.Marshal(configFile)
+	if err != nil {
+		t.Fatalf("failed to marshal config: %v", err)
+	}
+	configPath := filepath.Join(t.TempDir(), "notification.json")
+	if err := os.WriteFile(configPath, data, 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	notificationService, err := InitializeNotificationSystem(eventbus.NewEventBus(), configPath, "", "")
+	if err != nil {
+		t.Fatalf("failed to initialize notification system: %v", err)
+	}
+	defer notificationService.Stop(context.Background())
+
+	body := <-received
+	if !strings.Contains(body, "Notification service started") {
+		t.Fatalf("unexpected startup notification: %s", body)
+	}
+}
+
+func TestInitializeNotificationSystemRequiresEnabledMessenger(t *testing.T) {
+	configFile := map[string]interface{}{
+		"messengers": []map[string]interface{}{
+			{"name": "Ops Hook", "webhook": map[string]interface{}{"url": "http://localhost", "enabled": false}},
+		},
+	}
+	data, err := json.Marshal(configFile)
+	if err != nil {
+		t.Fatalf("failed to marshal config: %v", err)
+	}
+	configPath := filepath.Join(t.TempDir(), "notification.json")
+	if err := os.WriteFile(configPath, data, 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	_, err = InitializeNotificationSystem(eventbus.NewEventBus(), configPath, "", "")
+	if err == nil || !strings.Contains(err.Error(), "at least one messenger must be enabled") {
+		t.Fatalf("expected no enabled messenger error, got %v", err)
+	}
+}
@@ -0,0 +1,56 @@
+package messenger
+
+import (
+	"github.com/evdnx/gonotify/eventbus"
+	"github.com/evdnx/gonotify/types"
+)
+
+// Alert describes how an event maps onto an incident in an alerting backend
+// such as PagerDuty or Opsgenie.
+type Alert struct {
+	// Key is the stable deduplication key; repeated events with the same key
+	// update a single incident.
+	Key string
+	// Trigger is the event type that opens the incident. For recovery events
+	// it is the error event type being recovered from.
+	Trigger eventbus.EventType
+	// Resolve is true when the event closes the incident.
+	Resolve bool
+}
+
+// AlertFor returns the alert an event maps to. System errors share one
+// incident, strategy errors get one incident per strategy and other events
+// are keyed by the ID of their payload.
+func AlertFor(event eventbus.Event) Alert {
+	switch event.Type {
+	case eventbus.EventSystemError:
+		return Alert{Key: "system_error", Trigger: eventbus.EventSystemError}
+	case eventbus.EventSystemRecovered:
+		return Alert{Key: "system_error", Trigger: eventbus.EventSystemError, Resolve: true}
+	case eventbus.EventStrategyError:
+		alert := Alert{Key: "strategy_error", Trigger: eventbus.EventStrategyError}
+		if se, ok := event.Data.(types.StrategyError); ok && se.Strategy != "" {
+			alert.Key += ":" + se.Strategy
+		}
+		return alert
+	case eventbus.EventStrategyRecovered:
+		alert := Alert{Key: "strategy_error", Trigger: eventbus.EventStrategyError, Resolve: true}
+		if sr, ok := event.Data.(types.StrategyRecovery); ok && sr.Strategy != "" {
+			alert.Key += ":" + sr.Strategy
+		}
+		return alert
+	}
+
+	alert := Alert{Key: string(event.Type), Trigger: event.Type}
+	switch d := event.Data.(type) {
+	case types.Trade:
+		alert.Key += ":" + d.ID
+	case types.Order:
+		alert.Key += ":" + d.ID
+	case types.Position:
+		alert.Key += ":" + d.ID
+	case types.PnLUpdate:
+		alert.Key += ":" + d.Symbol
+	}
+	return alert
+}
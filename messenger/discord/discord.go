@@ -0,0 +1,262 @@
+package discord
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/evdnx/gonotify/eventbus"
+	"github.com/evdnx/gonotify/messenger"
+	"github.com/evdnx/gonotify/types"
+)
+
+// Embed colors used to highlight the direction of a trade or PnL and the
+// severity of other notifications.
+const (
+	ColorPositive = 0x2ECC71
+	ColorNegative = 0xE74C3C
+	ColorWarning  = 0xF1C40F
+	ColorNeutral  = 0x5865F2
+)
+
+// Discord's limits on embeds, in characters. The title, description and
+// fields together may not exceed MaxEmbedLength.
+const (
+	MaxTitleLength       = 256
+	MaxDescriptionLength = 4096
+	MaxFieldNameLength   = 256
+	MaxFieldValueLength  = 1024
+	MaxFields            = 25
+	MaxEmbedLength       = 6000
+)
+
+// ellipsis marks text cut to fit Discord's limits
+const ellipsis = "…"
+
+// defaultTimeout bounds SendMessage and SendEmbed, which take no context
+const defaultTimeout = 10 * time.Second
+
+// Client is a client for sending messages to a Discord channel webhook
+type Client struct {
+	webhookURL string
+	username   string
+	httpClient *http.Client
+}
+
+// Message represents a webhook message to be sent to Discord
+type Message struct {
+	Content  string  `json:"content,omitempty"`
+	Username string  `json:"username,omitempty"`
+	Embeds   []Embed `json:"embeds,omitempty"`
+}
+
+// Embed represents a Discord rich embed
+type Embed struct {
+	Title       string       `json:"title,omitempty"`
+	Description string       `json:"description,omitempty"`
+	Color       int          `json:"color,omitempty"`
+	Fields      []EmbedField `json:"fields,omitempty"`
+	Timestamp   string       `json:"timestamp,omitempty"`
+}
+
+// EmbedField represents a single name/value field of an embed
+type EmbedField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline,omitempty"`
+}
+
+// Response represents an error response from the Discord API
+type Response struct {
+	Message string `json:"message"`
+	Code    int    `json:"code"`
+}
+
+// NewClient creates a new Discord webhook client. The username overrides the
+// webhook's default name when not empty.
+func NewClient(webhookURL, username string) *Client {
+	return &Client{
+		webhookURL: webhookURL,
+		username:   username,
+		httpClient: &http.Client{},
+	}
+}
+
+// SendMessage sends a message to the Discord channel as a plain embed
+func (c *Client) SendMessage(message string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+	return c.sendEmbed(ctx, Embed{
+		Description: message,
+		Color:       ColorNeutral,
+	})
+}
+
+// SendNotification renders the notification as an embed and sends it
+func (c *Client) SendNotification(ctx context.Context, notification messenger.Notification) error {
+	return c.sendEmbed(ctx, RenderEmbed(notification))
+}
+
+// SendEmbed sends a single embed to the Discord channel
+func (c *Client) SendEmbed(embed Embed) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+	return c.sendEmbed(ctx, embed)
+}
+
+// sendEmbed sends a single embed to the Discord channel, aborting when ctx
+// is done
+func (c *Client) sendEmbed(ctx context.Context, embed Embed) error {
+	// Create the message payload
+	payload := Message{
+		Username: c.username,
+		Embeds:   []Embed{fit(embed)},
+	}
+
+	// Convert payload to JSON
+	jsonPayload, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message payload: %w", err)
+	}
+
+	// Create the request
+	req, err := http.NewRequestWithContext(ctx, "POST", c.webhookURL, bytes.NewBuffer(jsonPayload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	// Send the request
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Discord answers 204 No Content, or 200 when ?wait=true is set
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		var discordResponse Response
+		json.NewDecoder(resp.Body).Decode(&discordResponse)
+		return &messenger.HTTPError{StatusCode: resp.StatusCode, Err: fmt.Errorf("failed to send message: %s (status: %d)", discordResponse.Message, resp.StatusCode)}
+	}
+
+	return nil
+}
+
+// RenderEmbed builds an embed for a notification. Trades and orders are
+// colored by side, position closes and PnL updates by the sign of the PnL,
+// and everything else by severity. Text over Discord's limits is cut short
+// with an ellipsis.
+func RenderEmbed(notification messenger.Notification) Embed {
+	embed := Embed{
+		Title:       notification.Title,
+		Description: notification.Body,
+		Color:       severityColor(notification.Severity),
+	}
+
+	for _, field := range notification.Fields {
+		embed.Fields = append(embed.Fields, EmbedField{Name: field.Name, Value: field.Value, Inline: true})
+	}
+
+	if !notification.Timestamp.IsZero() {
+		embed.Timestamp = notification.Timestamp.UTC().Format(time.RFC3339)
+	}
+
+	switch d := notification.Event.Data.(type) {
+	case types.Trade:
+		embed.Color = sideColor(d.Side)
+	case types.Order:
+		embed.Color = sideColor(d.Side)
+	case types.Position:
+		embed.Color = sideColor(d.Side)
+		if notification.EventType == eventbus.EventPositionClosed {
+			embed.Color = pnlColor(d.RealizedPnL)
+		}
+	case types.PnLUpdate:
+		embed.Color = pnlColor(d.PnL)
+	}
+
+	return fit(embed)
+}
+
+// fit cuts an embed down to Discord's limits. Fields beyond MaxFields are
+// dropped, and the fields are given room before the description, so a long
+// body such as a stack trace doesn't push out the symbol or strategy.
+func fit(embed Embed) Embed {
+	embed.Title = truncate(embed.Title, MaxTitleLength)
+	used := utf8.RuneCountInString(embed.Title)
+
+	var fields []EmbedField
+	for _, field := range embed.Fields[:min(len(embed.Fields), MaxFields)] {
+		field.Name = truncate(field.Name, MaxFieldNameLength)
+		field.Value = truncate(field.Value, MaxFieldValueLength)
+		length := utf8.RuneCountInString(field.Name) + utf8.RuneCountInString(field.Value)
+		if used+length > MaxEmbedLength {
+			break
+		}
+		fields = append(fields, field)
+		used += length
+	}
+	embed.Fields = fields
+
+	embed.Description = truncate(embed.Description, min(MaxDescriptionLength, MaxEmbedLength-used))
+	return embed
+}
+
+// truncate cuts text to at most limit characters, ending it with an ellipsis
+// when anything was cut
+func truncate(text string, limit int) string {
+	if utf8.RuneCountInString(text) <= limit {
+		return text
+	}
+	if limit <= 0 {
+		return ""
+	}
+	runes := []rune(text)
+	return string(runes[:limit-1]) + ellipsis
+}
+
+// severityColor returns the embed color for a severity
+func severityColor(severity messenger.Severity) int {
+	switch severity {
+	case messenger.SeveritySuccess:
+		return ColorPositive
+	case messenger.SeverityError, messenger.SeverityCritical:
+		return ColorNegative
+	case messenger.SeverityWarning:
+		return ColorWarning
+	}
+	return ColorNeutral
+}
+
+// sideColor returns the embed color for a buy or sell side
+func sideColor(side string) int {
+	switch strings.ToLower(side) {
+	case "buy":
+		return ColorPositive
+	case "sell":
+		return ColorNegative
+	}
+	return ColorNeutral
+}
+
+// pnlColor returns the embed color for the sign of a profit or loss
+func pnlColor(pnl float64) int {
+	if pnl > 0 {
+		return ColorPositive
+	}
+	if pnl < 0 {
+		return ColorNegative
+	}
+	return ColorNeutral
+}
+
+// Name returns the name of the messenger
+func (c *Client) Name() string {
+	return "Discord"
+}
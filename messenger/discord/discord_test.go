@@ -0,0 +1,123 @@
+package discord
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/evdnx/gonotify/eventbus"
+	"github.com/evdnx/gonotify/messenger"
+	"github.com/evdnx/gonotify/types"
+)
+
+func TestSendNotificationRendersTradeEmbed(t *testing.T) {
+	var received Message
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("failed to decode payload: %v", err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "gonotify")
+	notification := messenger.Notification{
+		Title:     "💰 Trade Executed",
+		Body:      "sell BTCUSDT",
+		EventType: eventbus.EventTradeExecuted,
+		Fields: []messenger.Field{
+			{Name: "Symbol", Value: "BTCUSDT"},
+			{Name: "Quantity", Value: "0.500000 BTC"},
+			{Name: "Price", Value: "68000.00 USDT"},
+			{Name: "Fee", Value: "0.001000 BNB"},
+		},
+		Timestamp: time.Now(),
+		Event: eventbus.Event{
+			Type: eventbus.EventTradeExecuted,
+			Data: types.Trade{Symbol: "BTCUSDT", Side: "sell"},
+		},
+	}
+	if err := client.SendNotification(context.Background(), notification); err != nil {
+		t.Fatalf("SendNotification failed: %v", err)
+	}
+
+	if received.Username != "gonotify" || len(received.Embeds) != 1 {
+		t.Fatalf("unexpected payload: %+v", received)
+	}
+	embed := received.Embeds[0]
+	if embed.Title != "💰 Trade Executed" || embed.Description != "sell BTCUSDT" {
+		t.Fatalf("unexpected embed: %+v", embed)
+	}
+	if embed.Color != ColorNegative {
+		t.Fatalf("expected sell color, got %#x", embed.Color)
+	}
+	if len(embed.Fields) != 4 || embed.Fields[3].Name != "Fee" || !embed.Fields[3].Inline {
+		t.Fatalf("unexpected fields: %+v", embed.Fields)
+	}
+}
+
+func TestRenderEmbedPnLColor(t *testing.T) {
+	gain := RenderEmbed(messenger.Notification{Event: eventbus.Event{Data: types.PnLUpdate{PnL: 12}}})
+	loss := RenderEmbed(messenger.Notification{Event: eventbus.Event{Data: types.PnLUpdate{PnL: -12}}})
+	if gain.Color != ColorPositive || loss.Color != ColorNegative {
+		t.Fatalf("unexpected colors: gain %#x, loss %#x", gain.Color, loss.Color)
+	}
+}
+
+func TestRenderEmbedTruncatesToDiscordLimits(t *testing.T) {
+	notification := messenger.Notification{
+		Title: strings.Repeat("T", 300),
+		Body:  strings.Repeat("é", 5000),
+	}
+	for i := 0; i < 30; i++ {
+		notification.Fields = append(notification.Fields, messenger.Field{Name: fmt.Sprintf("Field %d", i), Value: strings.Repeat("v", 1500)})
+	}
+
+	embed := RenderEmbed(notification)
+
+	if n := utf8.RuneCountInString(embed.Title); n != MaxTitleLength || !strings.HasSuffix(embed.Title, "…") {
+		t.Errorf("expected a title of %d characters ending in an ellipsis, got %d", MaxTitleLength, n)
+	}
+	if len(embed.Fields) == 0 || len(embed.Fields) > MaxFields {
+		t.Fatalf("unexpected number of fields: %d", len(embed.Fields))
+	}
+	total := utf8.RuneCountInString(embed.Title) + utf8.RuneCountInString(embed.Description)
+	for _, field := range embed.Fields {
+		if n := utf8.RuneCountInString(field.Value); n != MaxFieldValueLength || !strings.HasSuffix(field.Value, "…") {
+			t.Errorf("%s: expected a value of %d characters ending in an ellipsis, got %d", field.Name, MaxFieldValueLength, n)
+		}
+		total += utf8.RuneCountInString(field.Name) + utf8.RuneCountInString(field.Value)
+	}
+	if total > MaxEmbedLength {
+		t.Errorf("embed is %d characters long", total)
+	}
+	if embed.Description != "" && !strings.HasSuffix(embed.Description, "…") {
+		t.Errorf("expected the description to end in an ellipsis")
+	}
+
+	// A long body alone is cut to the description limit
+	embed = RenderEmbed(messenger.Notification{Title: "Strategy Error", Body: strings.Repeat("x", 5000)})
+	if n := utf8.RuneCountInString(embed.Description); n != MaxDescriptionLength {
+		t.Errorf("expected a description of %d characters, got %d", MaxDescriptionLength, n)
+	}
+}
+
+func TestSendMessageError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(Response{Message: "Unknown Webhook", Code: 10015})
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "")
+	err := client.SendMessage("hello")
+	if err == nil || !strings.Contains(err.Error(), "Unknown Webhook") {
+		t.Fatalf("expected Unknown Webhook error, got %v", err)
+	}
+}
@@ -1,83 +1,247 @@
 package element
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
 	"time"
+
+	"github.com/evdnx/gonotify/messenger"
+)
+
+// defaultTimeout bounds SendMessage, which takes no context
+const defaultTimeout = 10 * time.Second
+
+// Message types. Notices are meant for bots and are shown less prominently
+// and never answered by other bots.
+const (
+	MsgTypeText   = "m.text"
+	MsgTypeNotice = "m.notice"
 )
 
+// FormatHTML is the format of HTML formatted bodies
+const FormatHTML = "org.matrix.custom.html"
+
+// RoomMention in Options.Mentions notifies the whole room
+const RoomMention = "@room"
+
 // Client is a client for sending messages to Element (Matrix) messenger
 type Client struct {
 	homeserverURL string
-	accessToken   string
-	roomID        string
+	roomID        string // room ID or alias as configured
+	options       Options
 	httpClient    *http.Client
+
+	// mu guards the session below, which changes as the client logs in,
+	// refreshes its token and resolves the room
+	mu           sync.Mutex
+	accessToken  string
+	refreshToken string
+	expiresAt    time.Time // zero when the access token doesn't expire
+	deviceID     string
+	room         string // resolved room ID, empty until the first message
+
+	progress messenger.Progress // parts of split messages already sent
+}
+
+// Options configure how the client renders notifications
+type Options struct {
+	// MsgType is MsgTypeText or MsgTypeNotice, MsgTypeText when empty
+	MsgType string
+
+	// Mentions are the user IDs (e.g. @alice:matrix.org), or RoomMention,
+	// pinged by notifications of MentionSeverity or above
+	Mentions []string
+
+	// MentionSeverity is the least severity that pings Mentions,
+	// messenger.SeverityCritical when empty
+	MentionSeverity messenger.Severity
+
+	// User and Password log in with m.login.password when there is no
+	// access token, and again when the token expires and can't be refreshed
+	User     string
+	Password string
+
+	// DeviceID reuses an existing device for logins instead of creating one
+	DeviceID string
+
+	// RefreshToken renews the access token when it expires, and gets the
+	// first one when there is no access token
+	RefreshToken string
+
+	// OnRefreshToken is called with the new refresh token whenever the
+	// homeserver issues one, so it can be stored for the next start. It runs
+	// while the client holds its session and must not call the client.
+	OnRefreshToken func(refreshToken string)
+
+	// AutoJoin joins the room before the first message, accepting a pending
+	// invite
+	AutoJoin bool
 }
 
 // Message represents a message to be sent to Element
 type Message struct {
-	MsgType string `json:"msgtype"`
-	Body    string `json:"body"`
+	MsgType       string   `json:"msgtype"`
+	Body          string   `json:"body"`
+	Format        string   `json:"format,omitempty"`
+	FormattedBody string   `json:"formatted_body,omitempty"`
+	Mentions      Mentions `json:"m.mentions"`
 }
 
-// NewClient creates a new Element client
+// Mentions lists who a message intentionally mentions. An empty value keeps
+// clients from pinging users whose names merely appear in the body.
+type Mentions struct {
+	UserIDs []string `json:"user_ids,omitempty"`
+	Room    bool     `json:"room,omitempty"`
+}
+
+// Error represents an error response from the Matrix client-server API
+type Error struct {
+	ErrCode      string `json:"errcode"`
+	Error        string `json:"error"`
+	RetryAfterMs int64  `json:"retry_after_ms,omitempty"`
+}
+
+// NewClient creates a new Element client. The room may be a room ID or an
+// alias such as #trading:matrix.org.
 func NewClient(homeserverURL, accessToken, roomID string) *Client {
+	return NewClientWithOptions(homeserverURL, accessToken, roomID, Options{})
+}
+
+// NewClientWithOptions creates a new Element client with the given rendering
+// and session options. The access token may be empty when Options has a user
+// and password to log in with.
+func NewClientWithOptions(homeserverURL, accessToken, roomID string, options Options) *Client {
+	if options.MsgType == "" {
+		options.MsgType = MsgTypeText
+	}
+	if options.MentionSeverity == "" {
+		options.MentionSeverity = messenger.SeverityCritical
+	}
 	return &Client{
 		homeserverURL: homeserverURL,
-		accessToken:   accessToken,
 		roomID:        roomID,
-		httpClient: &http.Client{
-			Timeout: 10 * time.Second,
-		},
+		options:       options,
+		httpClient:    &http.Client{},
+		accessToken:   accessToken,
+		refreshToken:  options.RefreshToken,
 	}
 }
 
 // SendMessage sends a message to the Element chat room
 func (c *Client) SendMessage(message string) error {
-	// Create the message payload
-	payload := Message{
-		MsgType: "m.text",
-		Body:    message,
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+	return c.SendMessageContext(ctx, message)
+}
+
+// SendMessageContext sends a message to the Element chat room, aborting when
+// ctx is done. Messages over MaxContentSize are split into numbered parts.
+func (c *Client) SendMessageContext(ctx context.Context, message string) error {
+	return c.sendParts(ctx, c.messages(plainBlocks(message), false, Mentions{}))
+}
+
+// SendNotification sends a notification as an HTML formatted message,
+// mentioning the configured users if it is severe enough. Long
+// notifications, such as errors with stack traces, are split into numbered
+// parts at line boundaries.
+func (c *Client) SendNotification(ctx context.Context, notification messenger.Notification) error {
+	mentions := c.mentions(notification)
+	return c.sendParts(ctx, c.messages(c.render(notification, mentions), true, mentions))
+}
+
+// sendParts sends the parts of a message in order, stopping at the first
+// that fails. A retry of the message resumes at the failed part under the
+// same transaction IDs, so the room doesn't get any part twice. Tokens and
+// the password are redacted from errors.
+func (c *Client) sendParts(ctx context.Context, messages []Message) error {
+	parts := make([]string, len(messages))
+	for i, message := range messages {
+		encoded, err := json.Marshal(message)
+		if err != nil {
+			return fmt.Errorf("failed to marshal message payload: %w", err)
+		}
+		parts[i] = string(encoded)
 	}
 
-	// Convert payload to JSON
-	jsonPayload, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("failed to marshal message payload: %w", err)
+	id, sent := c.progress.Resume(parts)
+	for i := sent; i < len(messages); i++ {
+		txnID := fmt.Sprintf("%s.%d", id, i)
+		if err := c.send(ctx, messages[i], txnID); err != nil {
+			if len(messages) > 1 {
+				err = fmt.Errorf("part %d/%d: %w", i+1, len(messages), err)
+			}
+			return messenger.Redact(err, c.secrets()...)
+		}
+		c.progress.Advance(id, i+1)
 	}
+	c.progress.Finish(id)
+	return nil
+}
 
-	// Create the request URL
-	// Format: /_matrix/client/r0/rooms/{roomId}/send/m.room.message/{txnId}
-	txnID := fmt.Sprintf("%d", time.Now().UnixNano())
-	url := fmt.Sprintf("%s/_matrix/client/r0/rooms/%s/send/m.room.message/%s?access_token=%s",
-		c.homeserverURL, c.roomID, txnID, c.accessToken)
+// secrets returns the credentials of the client
+func (c *Client) secrets() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return []string{c.accessToken, c.refreshToken, c.options.Password, c.options.RefreshToken}
+}
 
-	// Create the request
-	req, err := http.NewRequest("PUT", url, bytes.NewBuffer(jsonPayload))
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+// send sends a message payload to the Element chat room. When the
+// homeserver rejects the access token, the token is renewed and the message
+// sent once more.
+func (c *Client) send(ctx context.Context, payload Message, txnID string) error {
+	token, err := c.sendEvent(ctx, payload, txnID)
+	if errors.Is(err, errUnknownToken) && c.expire(token) {
+		_, err = c.sendEvent(ctx, payload, txnID)
 	}
-	req.Header.Set("Content-Type", "application/json")
+	return err
+}
 
-	// Send the request
-	resp, err := c.httpClient.Do(req)
+// sendEvent sends a message event with the current session and returns the
+// access token it used. The homeserver ignores events sent again under the
+// same transaction ID, so retries can't post a message twice.
+func (c *Client) sendEvent(ctx context.Context, payload Message, txnID string) (string, error) {
+	token, room, err := c.session(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to send message: %w", err)
+		return token, err
 	}
-	defer resp.Body.Close()
 
-	// Check the response
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to send message, status code: %d", resp.StatusCode)
+	// Format: /_matrix/client/v3/rooms/{roomId}/send/m.room.message/{txnId}
+	path := fmt.Sprintf("/rooms/%s/send/m.room.message/%s", url.PathEscape(room), url.PathEscape(txnID))
+	return token, c.request(ctx, "PUT", path, token, payload, nil, "send message")
+}
+
+// responseError wraps a failed API call as an HTTP error, or as a rate-limit
+// error for M_LIMIT_EXCEEDED responses. The wait comes from retry_after_ms,
+// falling back to the Retry-After header.
+func responseError(resp *http.Response, err error) error {
+	httpErr := &messenger.HTTPError{StatusCode: resp.StatusCode, Err: err}
+
+	var matrixErr Error
+	json.NewDecoder(resp.Body).Decode(&matrixErr)
+	if matrixErr.ErrCode == "M_UNKNOWN_TOKEN" {
+		httpErr.Err = fmt.Errorf("%w: %w", err, errUnknownToken)
+		return httpErr
+	}
+	if matrixErr.ErrCode != "M_LIMIT_EXCEEDED" {
+		return httpErr
 	}
 
-	return nil
+	retryAfter := time.Duration(matrixErr.RetryAfterMs) * time.Millisecond
+	if retryAfter <= 0 {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			retryAfter = time.Duration(seconds) * time.Second
+		}
+	}
+	return &messenger.RateLimitError{RetryAfter: retryAfter, Err: httpErr}
 }
 
 // Name returns the name of the messenger
 func (c *Client) Name() string {
 	return "Element"
 }
-
@@ -0,0 +1,422 @@
+package element
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/evdnx/gonotify/messenger"
+)
+
+func TestSendMessageRateLimited(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"errcode":"M_LIMIT_EXCEEDED","error":"Too many requests","retry_after_ms":2500}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "token", "!room:example.org")
+
+	err := client.SendMessage("hello")
+	retryAfter, ok := messenger.RetryAfter(err)
+	if !ok || retryAfter != 2500*time.Millisecond {
+		t.Fatalf("expected a 2.5s rate-limit error, got %v", err)
+	}
+}
+
+func TestSendMessageRateLimitedRetryAfterHeader(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "3")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"errcode":"M_LIMIT_EXCEEDED","error":"Too many requests"}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "token", "!room:example.org")
+
+	err := client.SendMessage("hello")
+	if retryAfter, ok := messenger.RetryAfter(err); !ok || retryAfter != 3*time.Second {
+		t.Fatalf("expected a 3s rate-limit error, got %v", err)
+	}
+}
+
+func TestSendMessageForbidden(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"errcode":"M_FORBIDDEN","error":"not in room"}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "token", "!room:example.org")
+
+	err := client.SendMessage("hello")
+	if err == nil || messenger.Retryable(err) {
+		t.Fatalf("expected a permanent error, got %v", err)
+	}
+}
+
+func TestSendNotificationFormatted(t *testing.T) {
+	var message Message
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&message)
+		w.Write([]byte(`{"event_id":"$event"}`))
+	}))
+	defer server.Close()
+
+	client := NewClientWithOptions(server.URL, "token", "!room:example.org", Options{
+		MsgType:  MsgTypeNotice,
+		Mentions: []string{"@alice:example.org", RoomMention},
+	})
+
+	notification := messenger.Notification{
+		Title:       "Strategy Error",
+		Body:        "order <rejected> & retried",
+		Severity:    messenger.SeverityCritical,
+		Fields:      []messenger.Field{{Name: "Symbol", Value: "BTCUSDT"}},
+		DisplayTime: "2024-01-01 09:30:00",
+	}
+	if err := client.SendNotification(context.Background(), notification); err != nil {
+		t.Fatalf("failed to send: %v", err)
+	}
+
+	if message.MsgType != MsgTypeNotice || message.Format != FormatHTML {
+		t.Errorf("unexpected message type %q and format %q", message.MsgType, message.Format)
+	}
+	wantBody := "@room @alice:example.org\nStrategy Error\norder <rejected> & retried\n\nSymbol: BTCUSDT\n\n2024-01-01 09:30:00"
+	if message.Body != wantBody {
+		t.Errorf("unexpected body:\n%s", message.Body)
+	}
+	wantHTML := `@room <a href="https://matrix.to/#/@alice:example.org">@alice:example.org</a><br><strong>Strategy Error</strong><br>order &lt;rejected&gt; &amp; retried<br><br><strong>Symbol:</strong> <code>BTCUSDT</code><br><br><em>2024-01-01 09:30:00</em>`
+	if message.FormattedBody != wantHTML {
+		t.Errorf("unexpected formatted body:\n%s", message.FormattedBody)
+	}
+	if !message.Mentions.Room || len(message.Mentions.UserIDs) != 1 || message.Mentions.UserIDs[0] != "@alice:example.org" {
+		t.Errorf("unexpected mentions %+v", message.Mentions)
+	}
+}
+
+func TestSendNotificationMentionsOnlySevereNotifications(t *testing.T) {
+	var raw map[string]json.RawMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&raw)
+		w.Write([]byte(`{"event_id":"$event"}`))
+	}))
+	defer server.Close()
+
+	client := NewClientWithOptions(server.URL, "token", "!room:example.org", Options{
+		Mentions: []string{"@alice:example.org"},
+	})
+
+	notification := messenger.Notification{Title: "Trade Executed", Severity: messenger.SeverityWarning}
+	if err := client.SendNotification(context.Background(), notification); err != nil {
+		t.Fatalf("failed to send: %v", err)
+	}
+	if string(raw["m.mentions"]) != "{}" {
+		t.Errorf("expected empty mentions, got %s", raw["m.mentions"])
+	}
+	if strings.Contains(string(raw["body"]), "@alice") {
+		t.Errorf("unexpected mention in body %s", raw["body"])
+	}
+}
+
+func TestSendNotificationSplitsLongMessages(t *testing.T) {
+	var messages []Message
+	var sizes []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		sizes = append(sizes, len(body))
+		var message Message
+		json.Unmarshal(body, &message)
+		messages = append(messages, message)
+		w.Write([]byte(`{"event_id":"$event"}`))
+	}))
+	defer server.Close()
+
+	client := NewClientWithOptions(server.URL, "token", "!room:example.org", Options{
+		Mentions: []string{"@alice:example.org"},
+	})
+
+	var trace []string
+	for i := 0; i < 3000; i++ {
+		trace = append(trace, fmt.Sprintf("main.(*Strategy).run(0x%x) <strategy.go:%d> +0x1f", i, i))
+	}
+	notification := messenger.Notification{
+		Title:    "Strategy Error",
+		Body:     strings.Join(trace, "\n"),
+		Severity: messenger.SeverityCritical,
+	}
+	if err := client.SendNotification(context.Background(), notification); err != nil {
+		t.Fatalf("failed to send: %v", err)
+	}
+
+	if len(messages) < 2 {
+		t.Fatalf("expected the message to be split, got %d part(s)", len(messages))
+	}
+	var lines []string
+	for i, message := range messages {
+		if sizes[i] > MaxContentSize+200 {
+			t.Errorf("part %d is %d bytes", i+1, sizes[i])
+		}
+		number, rest, _ := strings.Cut(message.Body, "\n")
+		if want := fmt.Sprintf("%d/%d", i+1, len(messages)); number != want || !strings.HasPrefix(message.FormattedBody, want+"<br>") {
+			t.Errorf("expected part number %q, got %q", want, number)
+		}
+		if (len(message.Mentions.UserIDs) > 0) != (i == 0) {
+			t.Errorf("part %d: unexpected mentions %+v", i+1, message.Mentions)
+		}
+		lines = append(lines, strings.Split(rest, "\n")...)
+	}
+	if len(lines) != len(trace)+2 || lines[len(lines)-1] != trace[len(trace)-1] {
+		t.Errorf("expected the whole trace, got %d lines ending with %q", len(lines), lines[len(lines)-1])
+	}
+}
+
+func TestSendMessageRetryResumesAtFailedPart(t *testing.T) {
+	var bodies, txnIDs []string
+	failed := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var message Message
+		json.NewDecoder(r.Body).Decode(&message)
+		number, _, _ := strings.Cut(message.Body, "\n")
+		txnIDs = append(txnIDs, r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:])
+		if number == "2/3" && !failed {
+			failed = true
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		bodies = append(bodies, number)
+		w.Write([]byte(`{"event_id":"$event"}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "token", "!room:example.org")
+	message := strings.Repeat(strings.Repeat("x", 1000)+"\n", 60)
+
+	if err := client.SendMessage(message); err == nil || !messenger.Retryable(err) {
+		t.Fatalf("expected a retryable error, got %v", err)
+	}
+	if err := client.SendMessage(message); err != nil {
+		t.Fatalf("failed to send: %v", err)
+	}
+
+	if strings.Join(bodies, ",") != "1/3,2/3,3/3" {
+		t.Fatalf("expected every part once, got %v", bodies)
+	}
+	// The failed part is sent again under the same transaction ID
+	if len(txnIDs) != 4 || txnIDs[1] != txnIDs[2] || txnIDs[0] == txnIDs[1] || txnIDs[2] == txnIDs[3] {
+		t.Errorf("unexpected transaction IDs %v", txnIDs)
+	}
+
+	// Sending the message again is a new delivery
+	bodies, first := nil, txnIDs[0]
+	if err := client.SendMessage(message); err != nil {
+		t.Fatalf("failed to send: %v", err)
+	}
+	if len(bodies) != 3 || txnIDs[4] == first {
+		t.Errorf("expected a new delivery, got %v with transaction ID %s", bodies, txnIDs[4])
+	}
+}
+
+func TestSendMessageAuthorizationHeader(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret-token" {
+			t.Errorf("unexpected Authorization header %q", r.Header.Get("Authorization"))
+		}
+		if r.URL.RawQuery != "" {
+			t.Errorf("unexpected query %q", r.URL.RawQuery)
+		}
+		if !strings.HasPrefix(r.URL.EscapedPath(), "/_matrix/client/v3/rooms/%21room:example.org/send/m.room.message/") {
+			t.Errorf("unexpected path %q", r.URL.EscapedPath())
+		}
+		w.Write([]byte(`{"event_id":"$event"}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "secret-token", "!room:example.org")
+	if err := client.SendMessage("hello"); err != nil {
+		t.Fatalf("failed to send: %v", err)
+	}
+}
+
+func TestSendMessageRedactsToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Close()
+
+	client := NewClient(server.URL, "secret-token", "!room:example.org")
+	err := client.SendMessage("hello")
+	if err == nil || strings.Contains(err.Error(), "secret-token") {
+		t.Fatalf("expected an error without the token, got %v", err)
+	}
+}
+
+// homeserver is a fake Matrix homeserver that issues access tokens that
+// expire after the first message, and with rotate a new refresh token on
+// every refresh
+type homeserver struct {
+	t         *testing.T
+	rotate    bool
+	logins    int
+	refreshes int
+	token     string
+	sentTo    []string
+}
+
+func (h *homeserver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch path := r.URL.EscapedPath(); {
+	case path == "/_matrix/client/v3/login":
+		var login LoginRequest
+		json.NewDecoder(r.Body).Decode(&login)
+		if login.Type != "m.login.password" || login.Identifier.User != "bot" || login.Password != "hunter2" || !login.RefreshToken {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errcode":"M_FORBIDDEN","error":"Invalid password"}`))
+			return
+		}
+		h.logins++
+		h.token = fmt.Sprintf("access-%d", h.logins)
+		fmt.Fprintf(w, `{"access_token":%q,"refresh_token":"refresh-1","device_id":"DEVICE","expires_in_ms":3600000}`, h.token)
+
+	case path == "/_matrix/client/v3/refresh":
+		var refresh struct {
+			RefreshToken string `json:"refresh_token"`
+		}
+		json.NewDecoder(r.Body).Decode(&refresh)
+		want := "refresh-1"
+		if h.rotate {
+			want = fmt.Sprintf("refresh-%d", h.refreshes+1)
+		}
+		if refresh.RefreshToken != want {
+			h.t.Errorf("unexpected refresh token %q", refresh.RefreshToken)
+		}
+		h.refreshes++
+		h.token = fmt.Sprintf("refreshed-%d", h.refreshes)
+		if h.rotate {
+			fmt.Fprintf(w, `{"access_token":%q,"refresh_token":"refresh-%d","expires_in_ms":3600000}`, h.token, h.refreshes+1)
+			return
+		}
+		fmt.Fprintf(w, `{"access_token":%q,"expires_in_ms":3600000}`, h.token)
+
+	case r.Header.Get("Authorization") != "Bearer "+h.token:
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"errcode":"M_UNKNOWN_TOKEN","error":"Access token has expired","soft_logout":true}`))
+
+	case path == "/_matrix/client/v3/directory/room/%23trading:example.org":
+		w.Write([]byte(`{"room_id":"!resolved:example.org","servers":["example.org"]}`))
+
+	case path == "/_matrix/client/v3/join/%23trading:example.org":
+		w.Write([]byte(`{"room_id":"!joined:example.org"}`))
+
+	case strings.HasPrefix(path, "/_matrix/client/v3/rooms/"):
+		room, _, _ := strings.Cut(strings.TrimPrefix(path, "/_matrix/client/v3/rooms/"), "/")
+		h.sentTo = append(h.sentTo, room)
+		w.Write([]byte(`{"event_id":"$event"}`))
+		h.token = "expired" // the next message needs a new token
+
+	default:
+		h.t.Errorf("unexpected request %s %s", r.Method, path)
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestPasswordLoginAndTokenRefresh(t *testing.T) {
+	h := &homeserver{t: t}
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	client := NewClientWithOptions(server.URL, "", "#trading:example.org", Options{User: "bot", Password: "hunter2"})
+
+	for i := 0; i < 2; i++ {
+		if err := client.SendMessage("hello"); err != nil {
+			t.Fatalf("message %d: failed to send: %v", i+1, err)
+		}
+	}
+
+	if h.logins != 1 || h.refreshes != 1 {
+		t.Errorf("expected one login and one refresh, got %d and %d", h.logins, h.refreshes)
+	}
+	if client.DeviceID() != "DEVICE" {
+		t.Errorf("expected the device assigned at login, got %q", client.DeviceID())
+	}
+	if len(h.sentTo) != 2 || h.sentTo[0] != "%21resolved:example.org" || h.sentTo[1] != h.sentTo[0] {
+		t.Errorf("expected both messages in the resolved room, got %v", h.sentTo)
+	}
+}
+
+func TestRefreshTokenWithoutAccessToken(t *testing.T) {
+	h := &homeserver{t: t}
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	client := NewClientWithOptions(server.URL, "", "!room:example.org", Options{RefreshToken: "refresh-1"})
+	if err := client.SendMessage("hello"); err != nil {
+		t.Fatalf("failed to send: %v", err)
+	}
+	if h.logins != 0 || h.refreshes != 1 {
+		t.Errorf("expected a refresh and no login, got %d refresh(es) and %d login(s)", h.refreshes, h.logins)
+	}
+}
+
+func TestRotatedRefreshTokens(t *testing.T) {
+	h := &homeserver{t: t, rotate: true}
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	var stored []string
+	options := Options{RefreshToken: "refresh-1", OnRefreshToken: func(refreshToken string) {
+		stored = append(stored, refreshToken)
+	}}
+	client := NewClientWithOptions(server.URL, "", "!room:example.org", options)
+	for i := 0; i < 2; i++ {
+		if err := client.SendMessage("hello"); err != nil {
+			t.Fatalf("message %d: failed to send: %v", i+1, err)
+		}
+	}
+	if len(stored) != 2 || stored[0] != "refresh-2" || stored[1] != "refresh-3" {
+		t.Fatalf("expected both rotated refresh tokens, got %v", stored)
+	}
+
+	// A restarted client resumes with the stored token
+	restarted := NewClientWithOptions(server.URL, "", "!room:example.org", Options{RefreshToken: stored[1]})
+	if err := restarted.SendMessage("hello"); err != nil {
+		t.Fatalf("failed to send after restart: %v", err)
+	}
+}
+
+func TestAutoJoin(t *testing.T) {
+	h := &homeserver{t: t, token: "token"}
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	client := NewClientWithOptions(server.URL, "token", "#trading:example.org", Options{AutoJoin: true})
+	if err := client.SendMessage("hello"); err != nil {
+		t.Fatalf("failed to send: %v", err)
+	}
+	if len(h.sentTo) != 1 || h.sentTo[0] != "%21joined:example.org" {
+		t.Errorf("expected the message in the joined room, got %v", h.sentTo)
+	}
+
+	// Without a refresh token or password the expired token is final
+	err := client.SendMessage("hello")
+	var httpErr *messenger.HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected an unauthorized error, got %v", err)
+	}
+}
+
+func TestLoginErrorsRedactPassword(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Close()
+
+	client := NewClientWithOptions(server.URL, "", "!room:example.org", Options{User: "bot", Password: "hunter2"})
+	err := client.SendMessage("hello")
+	if err == nil || !strings.Contains(err.Error(), "failed to log in") || strings.Contains(err.Error(), "hunter2") {
+		t.Fatalf("expected a login error without the password, got %v", err)
+	}
+}
@@ -0,0 +1,125 @@
+package element
+
+import (
+	"html"
+	"strings"
+
+	"github.com/evdnx/gonotify/messenger"
+)
+
+// severityRank orders severities from least to most urgent
+var severityRank = map[messenger.Severity]int{
+	messenger.SeverityInfo:     1,
+	messenger.SeveritySuccess:  2,
+	messenger.SeverityWarning:  3,
+	messenger.SeverityError:    4,
+	messenger.SeverityCritical: 5,
+}
+
+// ValidSeverity reports whether severity is a known notification severity
+func ValidSeverity(severity messenger.Severity) bool {
+	_, ok := severityRank[severity]
+	return ok
+}
+
+// block is a line of a message: text rendered as plain text and as HTML.
+// Blocks too long for one event are cut into pieces rendered with the same
+// styles, so every part of a split message has balanced HTML.
+type block struct {
+	text  string
+	plain func(string) string
+	html  func(string) string
+}
+
+// render returns the block as plain text and as HTML
+func (b block) render() (string, string) {
+	return b.renderText(b.text)
+}
+
+// renderText renders text with the block's styles. Blocks without styles
+// show the text as is in plain text and escaped in HTML.
+func (b block) renderText(text string) (string, string) {
+	plain, formatted := text, html.EscapeString(text)
+	if b.plain != nil {
+		plain = b.plain(text)
+	}
+	if b.html != nil {
+		formatted = b.html(text)
+	}
+	return plain, formatted
+}
+
+// plainBlocks returns the lines of text as unstyled blocks
+func plainBlocks(text string) []block {
+	var blocks []block
+	for _, line := range strings.Split(text, "\n") {
+		blocks = append(blocks, block{text: line})
+	}
+	return blocks
+}
+
+// render builds the lines of a notification: a bold title, the body, the
+// fields with monospace values and the timestamp. Severe notifications
+// mention the configured users at the top of the message.
+func (c *Client) render(notification messenger.Notification, mentions Mentions) []block {
+	var blocks []block
+
+	if len(mentions.UserIDs) > 0 || mentions.Room {
+		var plainPills, htmlPills []string
+		if mentions.Room {
+			plainPills = append(plainPills, RoomMention)
+			htmlPills = append(htmlPills, RoomMention)
+		}
+		for _, userID := range mentions.UserIDs {
+			plainPills = append(plainPills, userID)
+			htmlPills = append(htmlPills, `<a href="https://matrix.to/#/`+html.EscapeString(userID)+`">`+html.EscapeString(userID)+"</a>")
+		}
+		pills := strings.Join(htmlPills, " ")
+		blocks = append(blocks, block{strings.Join(plainPills, " "), nil, func(string) string { return pills }})
+	}
+
+	if notification.Title != "" {
+		blocks = append(blocks, block{notification.Title, nil, styled("strong")})
+	}
+	if notification.Body != "" {
+		blocks = append(blocks, plainBlocks(notification.Body)...)
+	}
+	if len(notification.Fields) > 0 {
+		blocks = append(blocks, block{})
+		for _, field := range notification.Fields {
+			plainLabel := field.Name + ": "
+			htmlLabel := "<strong>" + html.EscapeString(field.Name) + ":</strong> "
+			blocks = append(blocks, block{
+				text:  field.Value,
+				plain: func(s string) string { return plainLabel + s },
+				html:  func(s string) string { return htmlLabel + "<code>" + html.EscapeString(s) + "</code>" },
+			})
+		}
+	}
+	if timeText := notification.TimeText(); timeText != "" {
+		blocks = append(blocks, block{}, block{timeText, nil, styled("em")})
+	}
+	return blocks
+}
+
+// styled returns a style wrapping escaped text in an HTML element
+func styled(tag string) func(string) string {
+	return func(s string) string { return "<" + tag + ">" + html.EscapeString(s) + "</" + tag + ">" }
+}
+
+// mentions returns who a notification pings: the configured users if its
+// severity is at least the mention severity, and nobody otherwise
+func (c *Client) mentions(notification messenger.Notification) Mentions {
+	var mentions Mentions
+	if len(c.options.Mentions) == 0 || severityRank[notification.Severity] < severityRank[c.options.MentionSeverity] {
+		return mentions
+	}
+	for _, mention := range c.options.Mentions {
+		if mention == RoomMention {
+			mentions.Room = true
+			continue
+		}
+		mentions.UserIDs = append(mentions.UserIDs, mention)
+	}
+	return mentions
+}
@@ -0,0 +1,261 @@
+package element
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// refreshMargin renews access tokens this long before they expire, so
+// requests in flight don't race the expiry
+const refreshMargin = 30 * time.Second
+
+// deviceDisplayName names the devices created by password logins
+const deviceDisplayName = "gonotify"
+
+// errUnknownToken reports that the homeserver no longer accepts the access
+// or refresh token
+var errUnknownToken = errors.New("token is unknown or expired")
+
+// LoginRequest represents an m.login.password login
+type LoginRequest struct {
+	Type                     string     `json:"type"`
+	Identifier               Identifier `json:"identifier"`
+	Password                 string     `json:"password"`
+	DeviceID                 string     `json:"device_id,omitempty"`
+	InitialDeviceDisplayName string     `json:"initial_device_display_name,omitempty"`
+	RefreshToken             bool       `json:"refresh_token"`
+}
+
+// Identifier identifies the user logging in
+type Identifier struct {
+	Type string `json:"type"`
+	User string `json:"user"`
+}
+
+// TokenResponse represents the tokens returned by logins and refreshes.
+// ExpiresInMs is zero for tokens that don't expire.
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresInMs  int64  `json:"expires_in_ms,omitempty"`
+	DeviceID     string `json:"device_id,omitempty"`
+}
+
+// session returns the access token and room ID to send with. Without an
+// access token yet it refreshes the configured refresh token or logs in. It
+// renews tokens that are about to expire and resolves or joins the room
+// before the first message. The access token is returned along with errors
+// from resolving the room, which may reject it.
+func (c *Client) session(ctx context.Context) (string, string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch {
+	case c.accessToken == "" && c.refreshToken != "":
+		if err := c.renew(ctx); err != nil {
+			return "", "", err
+		}
+	case c.accessToken == "":
+		if err := c.login(ctx); err != nil {
+			return "", "", err
+		}
+	case !c.expiresAt.IsZero() && time.Until(c.expiresAt) < refreshMargin:
+		if err := c.renew(ctx); err != nil {
+			return "", "", err
+		}
+	}
+
+	if c.room == "" {
+		if err := c.resolveRoom(ctx); err != nil {
+			return c.accessToken, "", err
+		}
+	}
+	return c.accessToken, c.room, nil
+}
+
+// DeviceID returns the device the client logs in as: the one the homeserver
+// assigned at the last login, or else the configured one. Storing it as the
+// configured device lets restarts reuse the device instead of creating one
+// per start.
+func (c *Client) DeviceID() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.deviceID != "" {
+		return c.deviceID
+	}
+	return c.options.DeviceID
+}
+
+// expire marks the access token as expired after the homeserver rejected it,
+// unless another delivery has already replaced it. It reports whether the
+// client can get a new token.
+func (c *Client) expire(token string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.accessToken == token {
+		c.expiresAt = time.Now()
+	}
+	return c.refreshToken != "" || c.canLogin()
+}
+
+// renew replaces an expired access token, preferring the refresh token and
+// falling back to logging in again
+func (c *Client) renew(ctx context.Context) error {
+	if c.refreshToken != "" {
+		err := c.refresh(ctx)
+		if err == nil || !errors.Is(err, errUnknownToken) || !c.canLogin() {
+			return err
+		}
+	}
+	if c.canLogin() {
+		return c.login(ctx)
+	}
+	return fmt.Errorf("access token expired and no refresh token or password is configured")
+}
+
+// canLogin reports whether the client has the credentials to log in
+func (c *Client) canLogin() bool {
+	return c.options.User != "" && c.options.Password != ""
+}
+
+// login logs in with the configured user and password, asking for a refresh
+// token so the session outlives the access token
+func (c *Client) login(ctx context.Context) error {
+	if !c.canLogin() {
+		return fmt.Errorf("no access token, user or password is configured")
+	}
+
+	deviceID := c.deviceID
+	if deviceID == "" {
+		deviceID = c.options.DeviceID
+	}
+	request := LoginRequest{
+		Type:                     "m.login.password",
+		Identifier:               Identifier{Type: "m.id.user", User: c.options.User},
+		Password:                 c.options.Password,
+		DeviceID:                 deviceID,
+		InitialDeviceDisplayName: deviceDisplayName,
+		RefreshToken:             true,
+	}
+
+	var tokens TokenResponse
+	if err := c.request(ctx, "POST", "/login", "", request, &tokens, "log in"); err != nil {
+		return err
+	}
+	if tokens.DeviceID != "" {
+		c.deviceID = tokens.DeviceID
+	}
+	c.setTokens(tokens)
+	return nil
+}
+
+// refresh exchanges the refresh token for a new access token
+func (c *Client) refresh(ctx context.Context) error {
+	request := struct {
+		RefreshToken string `json:"refresh_token"`
+	}{c.refreshToken}
+
+	var tokens TokenResponse
+	if err := c.request(ctx, "POST", "/refresh", "", request, &tokens, "refresh access token"); err != nil {
+		return err
+	}
+	c.setTokens(tokens)
+	return nil
+}
+
+// setTokens stores the tokens of a login or refresh. Refresh tokens are only
+// replaced when the homeserver rotates them, and OnRefreshToken hears of the
+// new one.
+func (c *Client) setTokens(tokens TokenResponse) {
+	c.accessToken = tokens.AccessToken
+	if tokens.RefreshToken != "" && tokens.RefreshToken != c.refreshToken {
+		c.refreshToken = tokens.RefreshToken
+		if c.options.OnRefreshToken != nil {
+			c.options.OnRefreshToken(tokens.RefreshToken)
+		}
+	}
+	c.expiresAt = time.Time{}
+	if tokens.ExpiresInMs > 0 {
+		c.expiresAt = time.Now().Add(time.Duration(tokens.ExpiresInMs) * time.Millisecond)
+	}
+}
+
+// resolveRoom finds the ID of the configured room. With AutoJoin the room is
+// joined, accepting a pending invite, which also resolves aliases; otherwise
+// aliases such as #trading:matrix.org are looked up in the room directory.
+func (c *Client) resolveRoom(ctx context.Context) error {
+	var response struct {
+		RoomID string `json:"room_id"`
+	}
+
+	switch {
+	case c.options.AutoJoin:
+		path := "/join/" + url.PathEscape(c.roomID)
+		if err := c.request(ctx, "POST", path, c.accessToken, struct{}{}, &response, "join room "+c.roomID); err != nil {
+			return err
+		}
+	case strings.HasPrefix(c.roomID, "#"):
+		path := "/directory/room/" + url.PathEscape(c.roomID)
+		if err := c.request(ctx, "GET", path, c.accessToken, nil, &response, "resolve room alias "+c.roomID); err != nil {
+			return err
+		}
+	default:
+		response.RoomID = c.roomID
+	}
+
+	if response.RoomID == "" {
+		return fmt.Errorf("homeserver returned no room ID for %s", c.roomID)
+	}
+	c.room = response.RoomID
+	return nil
+}
+
+// request calls an endpoint of the client-server API below
+// /_matrix/client/v3, sending payload as JSON unless it is nil and decoding
+// the response into result unless it is nil. token authenticates the request
+// when not empty, and action describes the call in errors.
+func (c *Client) request(ctx context.Context, method, path, token string, payload, result interface{}, action string) error {
+	var body bytes.Buffer
+	if payload != nil {
+		if err := json.NewEncoder(&body).Encode(payload); err != nil {
+			return fmt.Errorf("failed to marshal %s payload: %w", action, err)
+		}
+	}
+
+	endpoint := strings.TrimSuffix(c.homeserverURL, "/") + "/_matrix/client/v3" + path
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, &body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to %s: %w", action, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp, fmt.Errorf("failed to %s, status code: %d", action, resp.StatusCode))
+	}
+	if result != nil {
+		if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+			return fmt.Errorf("failed to decode %s response: %w", action, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package element
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/evdnx/gonotify/messenger"
+)
+
+// MaxContentSize is the most bytes of body and formatted body sent in one
+// event. Homeservers reject events over 65536 bytes, and the rest is left for
+// the other fields and signatures of the event.
+const MaxContentSize = 48 * 1024
+
+// partNumberReserve is the room kept for the "1/3" line of split messages
+const partNumberReserve = 32
+
+// messages renders blocks into numbered messages of at most MaxContentSize.
+// Blocks are kept whole where they fit, so parts break at line boundaries.
+// Formatted messages carry an HTML body, and only the first part carries the
+// mentions so users are pinged once.
+func (c *Client) messages(blocks []block, formatted bool, mentions Mentions) []Message {
+	limit := MaxContentSize - partNumberReserve
+
+	// Cut blocks that don't fit in a part of their own, giving every piece
+	// the block's styles
+	var pieces []block
+	for _, b := range blocks {
+		if blockSize(b.render()) <= limit {
+			pieces = append(pieces, b)
+			continue
+		}
+		overhead := blockSize(b.renderText(""))
+		size := func(s string) int { return blockSize(b.renderText(s)) - overhead }
+		for _, text := range messenger.SplitText(b.text, limit-overhead, size) {
+			pieces = append(pieces, block{text, b.plain, b.html})
+		}
+	}
+
+	parts := messenger.Pack(pieces, limit, func(b block) int { return blockSize(b.render()) })
+	messages := make([]Message, 0, len(parts))
+	for i, part := range parts {
+		var plain, html []string
+		if len(parts) > 1 {
+			number := fmt.Sprintf("%d/%d", i+1, len(parts))
+			plain, html = append(plain, number), append(html, number)
+		}
+		for _, b := range trimBlank(part) {
+			p, h := b.render()
+			plain, html = append(plain, p), append(html, h)
+		}
+
+		message := Message{MsgType: c.options.MsgType, Body: strings.Join(plain, "\n")}
+		if formatted {
+			message.Format = FormatHTML
+			message.FormattedBody = strings.Join(html, "<br>")
+		}
+		if i == 0 {
+			message.Mentions = mentions
+		}
+		messages = append(messages, message)
+	}
+	return messages
+}
+
+// trimBlank drops the blank lines at the start and end of a part
+func trimBlank(blocks []block) []block {
+	blank := func(b block) bool {
+		plain, html := b.render()
+		return plain == "" && html == ""
+	}
+	for len(blocks) > 0 && blank(blocks[0]) {
+		blocks = blocks[1:]
+	}
+	for len(blocks) > 0 && blank(blocks[len(blocks)-1]) {
+		blocks = blocks[:len(blocks)-1]
+	}
+	return blocks
+}
+
+// blockSize returns the bytes a line takes in the event: its plain text and
+// HTML as encoded in JSON, with their line breaks
+func blockSize(plain, html string) int {
+	return jsonSize(plain+"\n") + jsonSize(html+"<br>")
+}
+
+// jsonSize returns the length of a string encoded in JSON, without quotes
+func jsonSize(s string) int {
+	encoded, _ := json.Marshal(s)
+	return len(encoded) - 2
+}
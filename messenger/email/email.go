@@ -0,0 +1,378 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/evdnx/gonotify/messenger"
+)
+
+// Connection security modes
+const (
+	SecurityNone     = "none"
+	SecuritySTARTTLS = "starttls"
+	SecurityTLS      = "tls"
+)
+
+// Authentication mechanisms
+const (
+	AuthPlain = "plain"
+	AuthLogin = "login"
+)
+
+// Default ports of the security modes
+const (
+	DefaultPortNone     = 25
+	DefaultPortSTARTTLS = 587
+	DefaultPortTLS      = 465
+)
+
+// Config contains the SMTP settings for an email client
+type Config struct {
+	Host          string
+	Port          int // defaults to the port of the security mode
+	Username      string
+	Password      string
+	From          string
+	To            []string
+	Security      string // "starttls" (default), "tls" or "none"
+	Auth          string // "plain" (default) or "login"; ignored without a username
+	SubjectPrefix string
+}
+
+// defaultTimeout bounds SendMessage, which takes no context
+const defaultTimeout = 10 * time.Second
+
+// Client is a client for sending notifications as emails over SMTP
+type Client struct {
+	config    Config
+	tlsConfig *tls.Config
+}
+
+// NewClient creates a new email client. Without a port it connects to the
+// standard port of the security mode.
+func NewClient(cfg Config) *Client {
+	if cfg.Security == "" {
+		cfg.Security = SecuritySTARTTLS
+	}
+	if cfg.Auth == "" {
+		cfg.Auth = AuthPlain
+	}
+	if cfg.Port == 0 {
+		switch cfg.Security {
+		case SecurityTLS:
+			cfg.Port = DefaultPortTLS
+		case SecurityNone:
+			cfg.Port = DefaultPortNone
+		default:
+			cfg.Port = DefaultPortSTARTTLS
+		}
+	}
+	return &Client{
+		config:    cfg,
+		tlsConfig: &tls.Config{ServerName: cfg.Host},
+	}
+}
+
+// SendMessage sends a message as an email using the message as the subject
+func (c *Client) SendMessage(message string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+	return c.send(ctx, c.subject(message), messenger.Notification{Title: message})
+}
+
+// SendNotification sends the notification as an email whose subject names
+// the symbol or strategy it is about
+func (c *Client) SendNotification(ctx context.Context, notification messenger.Notification) error {
+	subject := notification.Title
+	if topic := topic(notification); topic != "" && !strings.Contains(subject, topic) {
+		subject = fmt.Sprintf("%s - %s", subject, topic)
+	}
+
+	return c.send(ctx, c.subject(subject), notification)
+}
+
+// send composes the multipart message and delivers it to all recipients
+func (c *Client) send(ctx context.Context, subject string, notification messenger.Notification) error {
+	if len(c.config.To) == 0 {
+		return fmt.Errorf("no recipients configured")
+	}
+
+	body, err := c.compose(subject, notification)
+	if err != nil {
+		return fmt.Errorf("failed to compose message: %w", err)
+	}
+
+	client, stop, err := c.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	defer stop()
+
+	if c.config.Username != "" {
+		if err := client.Auth(c.auth()); err != nil {
+			return fmt.Errorf("failed to authenticate: %w", err)
+		}
+	}
+
+	if err := client.Mail(c.config.From); err != nil {
+		return fmt.Errorf("failed to set sender: %w", err)
+	}
+	for _, to := range c.config.To {
+		if err := client.Rcpt(to); err != nil {
+			return fmt.Errorf("failed to add recipient %s: %w", to, err)
+		}
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("failed to start message data: %w", err)
+	}
+	if _, err := writer.Write(body); err != nil {
+		return fmt.Errorf("failed to write message data: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+
+	return client.Quit()
+}
+
+// dial connects to the SMTP server using the configured security mode. The
+// connection is closed when ctx is done, aborting any exchange in progress;
+// the returned stop function releases that hook.
+func (c *Client) dial(ctx context.Context) (*smtp.Client, func() bool, error) {
+	addr := net.JoinHostPort(c.config.Host, fmt.Sprintf("%d", c.config.Port))
+
+	var conn net.Conn
+	var err error
+	if c.config.Security == SecurityTLS {
+		dialer := &tls.Dialer{Config: c.tlsConfig}
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	} else {
+		dialer := &net.Dialer{}
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+
+	client, err := smtp.NewClient(conn, c.config.Host)
+	if err != nil {
+		stop()
+		conn.Close()
+		return nil, nil, fmt.Errorf("failed to create SMTP client: %w", err)
+	}
+
+	if c.config.Security == SecuritySTARTTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			stop()
+			client.Close()
+			return nil, nil, fmt.Errorf("SMTP server does not support STARTTLS")
+		}
+		if err := client.StartTLS(c.tlsConfig); err != nil {
+			stop()
+			client.Close()
+			return nil, nil, fmt.Errorf("failed to start TLS: %w", err)
+		}
+	}
+
+	return client, stop, nil
+}
+
+// auth returns the configured SMTP authentication mechanism
+func (c *Client) auth() smtp.Auth {
+	if c.config.Auth == AuthLogin {
+		return &loginAuth{username: c.config.Username, password: c.config.Password, host: c.config.Host}
+	}
+	return smtp.PlainAuth("", c.config.Username, c.config.Password, c.config.Host)
+}
+
+// compose builds a multipart/alternative message with text and HTML parts
+func (c *Client) compose(subject string, notification messenger.Notification) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+
+	headers := textproto.MIMEHeader{}
+	headers.Set("From", c.config.From)
+	headers.Set("To", strings.Join(c.config.To, ", "))
+	headers.Set("Subject", mime.QEncoding.Encode("utf-8", subject))
+	headers.Set("Date", time.Now().Format(time.RFC1123Z))
+	headers.Set("Message-ID", messageID(c.config.From))
+	headers.Set("MIME-Version", "1.0")
+	headers.Set("Content-Type", fmt.Sprintf("multipart/alternative; boundary=%q", writer.Boundary()))
+
+	var header bytes.Buffer
+	for _, key := range []string{"From", "To", "Subject", "Date", "Message-ID", "MIME-Version", "Content-Type"} {
+		fmt.Fprintf(&header, "%s: %s\r\n", key, headers.Get(key))
+	}
+	header.WriteString("\r\n")
+
+	if err := writePart(writer, "text/plain", textBody(notification)); err != nil {
+		return nil, err
+	}
+	if err := writePart(writer, "text/html", htmlBody(notification)); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return append(header.Bytes(), buf.Bytes()...), nil
+}
+
+// writePart writes a quoted-printable encoded body part
+func writePart(writer *multipart.Writer, contentType, content string) error {
+	part, err := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentType + "; charset=utf-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return err
+	}
+	qp := quotedprintable.NewWriter(part)
+	if _, err := qp.Write([]byte(content)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// textBody renders the plain-text body
+func textBody(notification messenger.Notification) string {
+	var b strings.Builder
+	b.WriteString(notification.Title)
+	b.WriteString("\r\n")
+	if notification.Body != "" {
+		b.WriteString("\r\n")
+		b.WriteString(notification.Body)
+		b.WriteString("\r\n")
+	}
+	if len(notification.Fields) > 0 {
+		b.WriteString("\r\n")
+		for _, field := range notification.Fields {
+			fmt.Fprintf(&b, "%s: %s\r\n", field.Name, field.Value)
+		}
+	}
+	if timeText := notification.TimeText(); timeText != "" {
+		b.WriteString("\r\n")
+		b.WriteString(timeText)
+		b.WriteString("\r\n")
+	}
+	return b.String()
+}
+
+// htmlBody renders the HTML body with the fields as a table
+func htmlBody(notification messenger.Notification) string {
+	var b strings.Builder
+	b.WriteString("<html><body>")
+	fmt.Fprintf(&b, "<h2>%s</h2>", html.EscapeString(notification.Title))
+	if notification.Body != "" {
+		fmt.Fprintf(&b, "<p>%s</p>", html.EscapeString(notification.Body))
+	}
+	if len(notification.Fields) > 0 {
+		b.WriteString(`<table cellpadding="4" style="border-collapse:collapse">`)
+		for _, field := range notification.Fields {
+			fmt.Fprintf(&b, "<tr><th align=\"left\">%s</th><td>%s</td></tr>",
+				html.EscapeString(field.Name), html.EscapeString(field.Value))
+		}
+		b.WriteString("</table>")
+	}
+	if timeText := notification.TimeText(); timeText != "" {
+		fmt.Fprintf(&b, "<p><small>%s</small></p>", html.EscapeString(timeText))
+	}
+	b.WriteString("</body></html>")
+	return b.String()
+}
+
+// subject prefixes the subject line with the configured prefix
+func (c *Client) subject(subject string) string {
+	if c.config.SubjectPrefix == "" {
+		return subject
+	}
+	return c.config.SubjectPrefix + " " + subject
+}
+
+// topic returns the symbol or strategy a notification refers to
+func topic(notification messenger.Notification) string {
+	if symbol, ok := notification.Field("Symbol"); ok {
+		return symbol
+	}
+	if strategy, ok := notification.Field("Strategy"); ok {
+		return strategy
+	}
+	return ""
+}
+
+// messageID generates a unique Message-ID header value
+func messageID(from string) string {
+	domain := "localhost"
+	if at := strings.LastIndex(from, "@"); at >= 0 {
+		domain = strings.Trim(from[at+1:], "> ")
+	}
+	random := make([]byte, 12)
+	rand.Read(random)
+	return fmt.Sprintf("<%d.%s@%s>", time.Now().UnixNano(), hex.EncodeToString(random), domain)
+}
+
+// loginAuth implements the LOGIN authentication mechanism, which net/smtp
+// does not provide
+type loginAuth struct {
+	username string
+	password string
+	host     string
+}
+
+// Start begins the LOGIN exchange, refusing to send credentials in the clear
+// to anything but localhost
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, errors.New("unencrypted connection")
+	}
+	if server.Name != a.host {
+		return "", nil, errors.New("wrong host name")
+	}
+	return "LOGIN", nil, nil
+}
+
+// Next answers the server's username and password challenges
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	switch strings.ToLower(strings.TrimSpace(string(fromServer))) {
+	case "username:", "user name", "username":
+		return []byte(a.username), nil
+	case "password:", "password":
+		return []byte(a.password), nil
+	}
+	return nil, fmt.Errorf("unexpected LOGIN challenge: %s", fromServer)
+}
+
+// isLocalhost reports whether the host refers to the local machine
+func isLocalhost(host string) bool {
+	return host == "localhost" || host == "127.0.0.1" || host == "::1"
+}
+
+// Name returns the name of the messenger
+func (c *Client) Name() string {
+	return "Email"
+}
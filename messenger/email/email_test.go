@@ -0,0 +1,244 @@
+package email
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/evdnx/gonotify/messenger"
+)
+
+// smtpSession records what a client sent to the test SMTP server
+type smtpSession struct {
+	auth string
+	from string
+	to   []string
+	data string
+}
+
+// startSMTPServer runs a minimal in-process SMTP server that accepts a single
+// session and reports it on the returned channel
+func startSMTPServer(t *testing.T) (int, <-chan smtpSession) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	sessions := make(chan smtpSession, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		tp := textproto.NewConn(conn)
+		var session smtpSession
+		tp.PrintfLine("220 localhost ESMTP test")
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+			verb, arg, _ := strings.Cut(line, " ")
+			switch strings.ToUpper(verb) {
+			case "EHLO", "HELO":
+				tp.PrintfLine("250-localhost")
+				tp.PrintfLine("250 AUTH PLAIN LOGIN")
+			case "AUTH":
+				mechanism, initial, _ := strings.Cut(arg, " ")
+				if mechanism == "LOGIN" {
+					tp.PrintfLine("334 %s", base64.StdEncoding.EncodeToString([]byte("Username:")))
+					user, _ := tp.ReadLine()
+					tp.PrintfLine("334 %s", base64.StdEncoding.EncodeToString([]byte("Password:")))
+					pass, _ := tp.ReadLine()
+					u, _ := base64.StdEncoding.DecodeString(user)
+					p, _ := base64.StdEncoding.DecodeString(pass)
+					session.auth = "LOGIN " + string(u) + ":" + string(p)
+				} else {
+					decoded, _ := base64.StdEncoding.DecodeString(initial)
+					parts := strings.Split(string(decoded), "\x00")
+					session.auth = "PLAIN " + parts[1] + ":" + parts[2]
+				}
+				tp.PrintfLine("235 Authentication successful")
+			case "MAIL":
+				session.from = strings.Trim(strings.TrimPrefix(arg, "FROM:"), "<>")
+				tp.PrintfLine("250 OK")
+			case "RCPT":
+				session.to = append(session.to, strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>"))
+				tp.PrintfLine("250 OK")
+			case "DATA":
+				tp.PrintfLine("354 Go ahead")
+				data, _ := tp.ReadDotBytes()
+				session.data = string(data)
+				tp.PrintfLine("250 OK")
+			case "QUIT":
+				tp.PrintfLine("221 Bye")
+				sessions <- session
+				return
+			default:
+				tp.PrintfLine("502 Command not implemented")
+			}
+		}
+	}()
+
+	return listener.Addr().(*net.TCPAddr).Port, sessions
+}
+
+func waitForSession(t *testing.T, sessions <-chan smtpSession) smtpSession {
+	t.Helper()
+	select {
+	case session := <-sessions:
+		return session
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for SMTP session")
+	}
+	return smtpSession{}
+}
+
+func TestSendNotificationMultipart(t *testing.T) {
+	port, sessions := startSMTPServer(t)
+
+	client := NewClient(Config{
+		Host:          "127.0.0.1",
+		Port:          port,
+		Username:      "bot",
+		Password:      "secret",
+		From:          "bot@example.com",
+		To:            []string{"compliance@example.com"},
+		Security:      SecurityNone,
+		SubjectPrefix: "[GoNotify]",
+	})
+
+	notification := messenger.Notification{
+		Title:     "🔒💰 Position Closed",
+		Body:      "buy BTCUSDT <1.0>",
+		Fields:    []messenger.Field{{Name: "Symbol", Value: "BTCUSDT"}},
+		Timestamp: time.Now(),
+	}
+	if err := client.SendNotification(context.Background(), notification); err != nil {
+		t.Fatalf("SendNotification failed: %v", err)
+	}
+
+	session := waitForSession(t, sessions)
+	if session.auth != "PLAIN bot:secret" {
+		t.Fatalf("unexpected auth: %q", session.auth)
+	}
+	if session.from != "bot@example.com" || len(session.to) != 1 || session.to[0] != "compliance@example.com" {
+		t.Fatalf("unexpected envelope: %+v", session)
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(session.data))
+	if err != nil {
+		t.Fatalf("failed to parse message: %v", err)
+	}
+	subject, _ := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if subject != "[GoNotify] 🔒💰 Position Closed - BTCUSDT" {
+		t.Fatalf("unexpected subject: %q", subject)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("unexpected content type: %q", msg.Header.Get("Content-Type"))
+	}
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	var contentTypes []string
+	var htmlPart string
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("failed to read part: %v", err)
+		}
+		body, _ := io.ReadAll(part)
+		contentTypes = append(contentTypes, part.Header.Get("Content-Type"))
+		if strings.HasPrefix(part.Header.Get("Content-Type"), "text/html") {
+			htmlPart = string(body)
+		}
+	}
+	if len(contentTypes) != 2 {
+		t.Fatalf("expected text and HTML parts, got %v", contentTypes)
+	}
+	if !strings.Contains(htmlPart, "&lt;1.0&gt;") || !strings.Contains(htmlPart, "<th align=\"left\">Symbol</th>") {
+		t.Fatalf("expected escaped HTML body, got %q", htmlPart)
+	}
+}
+
+func TestSendMessageLoginAuth(t *testing.T) {
+	port, sessions := startSMTPServer(t)
+
+	client := NewClient(Config{
+		Host:     "127.0.0.1",
+		Port:     port,
+		Username: "bot",
+		Password: "secret",
+		From:     "bot@example.com",
+		To:       []string{"desk@example.com"},
+		Security: SecurityNone,
+		Auth:     AuthLogin,
+	})
+
+	if err := client.SendMessage("🚨 System Error: connection lost"); err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+
+	session := waitForSession(t, sessions)
+	if session.auth != "LOGIN bot:secret" {
+		t.Fatalf("unexpected auth: %q", session.auth)
+	}
+	if !strings.Contains(session.data, "System Error") {
+		t.Fatalf("expected subject in message, got %q", session.data)
+	}
+}
+
+func TestStartTLSRequired(t *testing.T) {
+	port, _ := startSMTPServer(t)
+
+	client := NewClient(Config{
+		Host: "127.0.0.1",
+		Port: port,
+		From: "bot@example.com",
+		To:   []string{"desk@example.com"},
+	})
+
+	err := client.SendMessage("hello")
+	if err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Fatalf("expected STARTTLS error, got %v", err)
+	}
+}
+
+func TestNewClientDefaultsPortToSecurityMode(t *testing.T) {
+	tests := []struct {
+		security string
+		want     int
+	}{
+		{"", DefaultPortSTARTTLS},
+		{SecuritySTARTTLS, 587},
+		{SecurityTLS, 465},
+		{SecurityNone, 25},
+	}
+	for _, tt := range tests {
+		client := NewClient(Config{Host: "smtp.example.com", Security: tt.security})
+		if client.config.Port != tt.want {
+			t.Errorf("security %q: expected port %d, got %d", tt.security, tt.want, client.config.Port)
+		}
+	}
+
+	client := NewClient(Config{Host: "smtp.example.com", Port: 2525, Security: SecurityTLS})
+	if client.config.Port != 2525 {
+		t.Errorf("expected the configured port, got %d", client.config.Port)
+	}
+}
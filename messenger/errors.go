@@ -0,0 +1,186 @@
+package messenger
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// redacted replaces secrets in the messages of redacted errors
+const redacted = "[REDACTED]"
+
+// HTTPError reports a delivery rejected by a messenger's HTTP API. Err
+// carries the messenger's own description of the failure.
+type HTTPError struct {
+	StatusCode int
+	Err        error
+}
+
+// Error returns the wrapped error's message
+func (e *HTTPError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the wrapped error
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+// RateLimitError reports a delivery rejected because the messenger's API is
+// rate limiting the client. RetryAfter is how long the API asked to wait
+// before the next attempt.
+type RateLimitError struct {
+	RetryAfter time.Duration
+	Err        error
+}
+
+// Error returns the wrapped error's message
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%v (retry after %v)", e.Err, e.RetryAfter)
+}
+
+// Unwrap returns the wrapped error
+func (e *RateLimitError) Unwrap() error {
+	return e.Err
+}
+
+// Retryable reports that rate-limited deliveries are always worth retrying
+func (e *RateLimitError) Retryable() bool {
+	return true
+}
+
+// RetryAfter returns the wait requested by a rate-limited messenger, if err
+// is or wraps a RateLimitError
+func RetryAfter(err error) (time.Duration, bool) {
+	var rateLimit *RateLimitError
+	if errors.As(err, &rateLimit) && rateLimit.RetryAfter > 0 {
+		return rateLimit.RetryAfter, true
+	}
+	return 0, false
+}
+
+// Retryable reports whether a failed delivery is worth attempting again.
+// Errors may decide for themselves by implementing Retryable() bool.
+// Otherwise server errors, 408 and 429 responses, transient SMTP replies,
+// timeouts, network errors and lost connections are retryable, while other
+// client errors, such as unsupported URLs and untrusted certificates, and
+// anything unrecognised are not.
+func Retryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var classified interface{ Retryable() bool }
+	if errors.As(err, &classified) {
+		return classified.Retryable()
+	}
+
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		code := httpErr.StatusCode
+		return code >= 500 || code == http.StatusTooManyRequests || code == http.StatusRequestTimeout
+	}
+
+	var smtpErr *textproto.Error
+	if errors.As(err, &smtpErr) {
+		return smtpErr.Code >= 400 && smtpErr.Code < 500
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	// HTTP clients wrap every failure in a *url.Error, which counts as a
+	// net.Error even for bad URLs and certificates
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Timeout() || connectionError(urlErr.Err)
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// connectionError reports whether an HTTP request failed to reach the server
+// or lost the connection, as opposed to being refused by the client itself
+// or failing certificate verification
+func connectionError(err error) bool {
+	var certErr *tls.CertificateVerificationError
+	if errors.As(err, &certErr) {
+		return false
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return !dnsErr.IsNotFound
+	}
+
+	var opErr *net.OpError
+	return errors.As(err, &opErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// RedactedError hides secrets, such as tokens embedded in request URLs, from
+// the message of the error it wraps
+type RedactedError struct {
+	Err     error
+	secrets []string
+}
+
+// Error returns the wrapped error's message with the secrets replaced
+func (e *RedactedError) Error() string {
+	return redact(e.Err.Error(), e.secrets)
+}
+
+// Unwrap returns the wrapped error
+func (e *RedactedError) Unwrap() error {
+	return e.Err
+}
+
+// Redact wraps err so its message no longer contains any of the secrets.
+// URLs of wrapped *url.Error values are redacted too, since callers may log
+// them directly. The result still unwraps to err, so checks such as
+// Retryable keep working. Redact returns nil for a nil err.
+func Redact(err error, secrets ...string) error {
+	if err == nil {
+		return nil
+	}
+
+	var nonEmpty []string
+	for _, secret := range secrets {
+		if secret != "" {
+			nonEmpty = append(nonEmpty, secret)
+		}
+	}
+	if len(nonEmpty) == 0 {
+		return err
+	}
+
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		urlErr.URL = redact(urlErr.URL, nonEmpty)
+	}
+	return &RedactedError{Err: err, secrets: nonEmpty}
+}
+
+// redact replaces every occurrence of the secrets in s, including their URL
+// escaped forms
+func redact(s string, secrets []string) string {
+	for _, secret := range secrets {
+		s = strings.ReplaceAll(s, secret, redacted)
+		if escaped := url.PathEscape(secret); escaped != secret {
+			s = strings.ReplaceAll(s, escaped, redacted)
+		}
+		if escaped := url.QueryEscape(secret); escaped != secret {
+			s = strings.ReplaceAll(s, escaped, redacted)
+		}
+	}
+	return s
+}
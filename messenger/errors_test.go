@@ -0,0 +1,82 @@
+package messenger
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+type permanentError struct{}
+
+func (permanentError) Error() string   { return "permanent" }
+func (permanentError) Retryable() bool { return false }
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"server error", &HTTPError{StatusCode: 502, Err: errors.New("bad gateway")}, true},
+		{"too many requests", &HTTPError{StatusCode: 429, Err: errors.New("slow down")}, true},
+		{"client error", &HTTPError{StatusCode: 400, Err: errors.New("bad request")}, false},
+		{"wrapped client error", fmt.Errorf("send: %w", &HTTPError{StatusCode: 403, Err: errors.New("forbidden")}), false},
+		{"transient smtp reply", &textproto.Error{Code: 421, Msg: "try again later"}, true},
+		{"permanent smtp reply", &textproto.Error{Code: 550, Msg: "no such user"}, false},
+		{"deadline", fmt.Errorf("send: %w", context.DeadlineExceeded), true},
+		{"canceled", context.Canceled, false},
+		{"network", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, true},
+		{"connection refused", &url.Error{Op: "Post", URL: "https://example.com", Err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}}, true},
+		{"connection lost", &url.Error{Op: "Post", URL: "https://example.com", Err: io.EOF}, true},
+		{"request timeout", &url.Error{Op: "Post", URL: "https://example.com", Err: context.DeadlineExceeded}, true},
+		{"request canceled", &url.Error{Op: "Post", URL: "https://example.com", Err: context.Canceled}, false},
+		{"unsupported scheme", &url.Error{Op: "Post", URL: "ftp://example.com", Err: errors.New(`unsupported protocol scheme "ftp"`)}, false},
+		{"untrusted certificate", &url.Error{Op: "Post", URL: "https://example.com", Err: &tls.CertificateVerificationError{Err: x509.UnknownAuthorityError{}}}, false},
+		{"unknown host", &url.Error{Op: "Post", URL: "https://example.invalid", Err: &net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "no such host", Name: "example.invalid", IsNotFound: true}}}, false},
+		{"self classified", fmt.Errorf("send: %w", permanentError{}), false},
+		{"unknown", errors.New("boom"), false},
+	}
+
+	for _, tt := range tests {
+		if got := Retryable(tt.err); got != tt.want {
+			t.Errorf("%s: Retryable() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRedact(t *testing.T) {
+	if Redact(nil, "secret") != nil {
+		t.Error("expected nil for a nil error")
+	}
+
+	urlErr := &url.Error{
+		Op:  "Post",
+		URL: "https://api.telegram.org/bot123:secret/sendMessage",
+		Err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")},
+	}
+	err := Redact(fmt.Errorf("failed to send message: %w", urlErr), "123:secret", "")
+
+	if strings.Contains(err.Error(), "secret") {
+		t.Errorf("token in error message: %v", err)
+	}
+	if strings.Contains(urlErr.URL, "secret") {
+		t.Errorf("token in error URL: %s", urlErr.URL)
+	}
+	if !Retryable(err) {
+		t.Error("expected the redacted network error to stay retryable")
+	}
+
+	escaped := Redact(errors.New("GET /rooms?token=a%2Fb failed"), "a/b")
+	if strings.Contains(escaped.Error(), "a%2Fb") {
+		t.Errorf("escaped token in error message: %v", escaped)
+	}
+}
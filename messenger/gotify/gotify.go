@@ -0,0 +1,155 @@
+package gotify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/evdnx/gonotify/eventbus"
+	"github.com/evdnx/gonotify/messenger"
+	"github.com/evdnx/gonotify/types"
+)
+
+// Gotify message priorities as interpreted by the Android client: 0 is
+// silent, 1-3 are quiet, 4-7 make a sound and 8-10 are shown as alerts.
+const (
+	PriorityLow      = 2
+	PriorityDefault  = 5
+	PriorityHigh     = 7
+	PriorityCritical = 9
+)
+
+// defaultTimeout bounds SendMessage, which takes no context
+const defaultTimeout = 10 * time.Second
+
+// Client is a client for sending messages to a Gotify server
+type Client struct {
+	serverURL  string
+	appToken   string
+	httpClient *http.Client
+}
+
+// Message represents a message to be sent to Gotify
+type Message struct {
+	Title    string `json:"title,omitempty"`
+	Message  string `json:"message"`
+	Priority int    `json:"priority"`
+}
+
+// Response represents an error response from the Gotify API
+type Response struct {
+	Error            string `json:"error"`
+	ErrorCode        int    `json:"errorCode"`
+	ErrorDescription string `json:"errorDescription"`
+}
+
+// NewClient creates a new Gotify client for an application token
+func NewClient(serverURL, appToken string) *Client {
+	return &Client{
+		serverURL:  strings.TrimRight(serverURL, "/"),
+		appToken:   appToken,
+		httpClient: &http.Client{},
+	}
+}
+
+// SendMessage sends a message with the default priority
+func (c *Client) SendMessage(message string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+	return c.send(ctx, Message{
+		Message:  message,
+		Priority: PriorityDefault,
+	})
+}
+
+// SendNotification sends the notification with a priority that reflects its
+// event and severity
+func (c *Client) SendNotification(ctx context.Context, notification messenger.Notification) error {
+	body := notification.Body
+	if timeText := notification.TimeText(); timeText != "" {
+		body = strings.TrimSpace(fmt.Sprintf("[%s] %s", timeText, body))
+	}
+	if len(notification.Fields) > 0 {
+		lines := make([]string, len(notification.Fields))
+		for i, field := range notification.Fields {
+			lines[i] = fmt.Sprintf("%s: %s", field.Name, field.Value)
+		}
+		body = strings.TrimSpace(body + "\n\n" + strings.Join(lines, "\n"))
+	}
+	return c.send(ctx, Message{
+		Title:    notification.Title,
+		Message:  body,
+		Priority: priority(notification),
+	})
+}
+
+// send posts the message to the Gotify message endpoint
+func (c *Client) send(ctx context.Context, payload Message) error {
+	// Convert payload to JSON
+	jsonPayload, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message payload: %w", err)
+	}
+
+	// Create the request
+	url := fmt.Sprintf("%s/message", c.serverURL)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonPayload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Gotify-Key", c.appToken)
+
+	// Send the request
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Check the response
+	if resp.StatusCode != http.StatusOK {
+		var gotifyResponse Response
+		json.NewDecoder(resp.Body).Decode(&gotifyResponse)
+		return &messenger.HTTPError{StatusCode: resp.StatusCode, Err: fmt.Errorf("failed to send message: %s (status: %d)", gotifyResponse.ErrorDescription, resp.StatusCode)}
+	}
+
+	return nil
+}
+
+// priority maps a notification to a Gotify priority
+func priority(notification messenger.Notification) int {
+	switch notification.EventType {
+	case eventbus.EventSystemError, eventbus.EventStrategyError:
+		return PriorityCritical
+	case eventbus.EventPositionClosed:
+		return PriorityHigh
+	case eventbus.EventOrderFilled:
+		if order, ok := notification.Event.Data.(types.Order); ok {
+			switch order.Type {
+			case "stop", "stop_market", "take_profit", "take_profit_market":
+				return PriorityHigh
+			}
+		}
+		return PriorityDefault
+	case eventbus.EventPnLUpdate:
+		return PriorityLow
+	}
+
+	switch notification.Severity {
+	case messenger.SeverityCritical:
+		return PriorityCritical
+	case messenger.SeverityError, messenger.SeverityWarning:
+		return PriorityHigh
+	}
+	return PriorityDefault
+}
+
+// Name returns the name of the messenger
+func (c *Client) Name() string {
+	return "Gotify"
+}
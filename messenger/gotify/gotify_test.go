@@ -0,0 +1,61 @@
+package gotify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/evdnx/gonotify/eventbus"
+	"github.com/evdnx/gonotify/messenger"
+)
+
+func TestSendNotificationPriority(t *testing.T) {
+	var received Message
+	var key, path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key = r.Header.Get("X-Gotify-Key")
+		path = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&received)
+		w.Write([]byte(`{"id":1}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL+"/", "app-token")
+	notification := messenger.Notification{
+		Title:     "🚨 System Error",
+		Body:      "connection lost",
+		Fields:    []messenger.Field{{Name: "Component", Value: "exchange"}},
+		Severity:  messenger.SeverityCritical,
+		EventType: eventbus.EventSystemError,
+	}
+	if err := client.SendNotification(context.Background(), notification); err != nil {
+		t.Fatalf("SendNotification failed: %v", err)
+	}
+
+	if key != "app-token" || path != "/message" {
+		t.Fatalf("unexpected request: key %q, path %q", key, path)
+	}
+	if received.Title != "🚨 System Error" || received.Message != "connection lost\n\nComponent: exchange" {
+		t.Fatalf("unexpected message: %+v", received)
+	}
+	if received.Priority != PriorityCritical {
+		t.Fatalf("expected critical priority, got %d", received.Priority)
+	}
+}
+
+func TestSendMessageError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(Response{Error: "Unauthorized", ErrorCode: 401, ErrorDescription: "you need to provide a valid access token"})
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "bad-token")
+	err := client.SendMessage("hello")
+	if err == nil || !strings.Contains(err.Error(), "valid access token") {
+		t.Fatalf("expected access token error, got %v", err)
+	}
+}
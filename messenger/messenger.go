@@ -1,8 +1,16 @@
 package messenger
 
+import "context"
+
 // Messenger defines the contract for sending messages to different platforms.
 type Messenger interface {
 	SendMessage(message string) error
 	Name() string
 }
 
+// ContextMessenger is implemented by messengers whose deliveries honour the
+// deadline and cancellation of a context.
+type ContextMessenger interface {
+	Messenger
+	SendMessageContext(ctx context.Context, message string) error
+}
@@ -0,0 +1,131 @@
+package messenger
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/evdnx/gonotify/eventbus"
+)
+
+// Severity indicates how urgent a notification is.
+type Severity string
+
+// Notification severities, from least to most urgent.
+const (
+	SeverityInfo     Severity = "info"
+	SeveritySuccess  Severity = "success"
+	SeverityWarning  Severity = "warning"
+	SeverityError    Severity = "error"
+	SeverityCritical Severity = "critical"
+)
+
+// Field is a key/value detail of a notification, such as the symbol or price
+// of a trade.
+type Field struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Notification is a structured notification produced by the service. Rich
+// backends render its parts individually; string-only messengers receive the
+// output of Text.
+type Notification struct {
+	Title     string             `json:"title"`
+	Body      string             `json:"body,omitempty"`
+	Severity  Severity           `json:"severity"`
+	EventType eventbus.EventType `json:"event_type,omitempty"`
+	Fields    []Field            `json:"fields,omitempty"`
+	Tags      []string           `json:"tags,omitempty"`
+	Timestamp time.Time          `json:"timestamp"`
+
+	// ExchangeTimestamp is when the exchange says the trade or order took
+	// place, or zero when the payload carries no time
+	ExchangeTimestamp time.Time `json:"exchange_timestamp,omitzero"`
+
+	// DisplayTime is the timestamp as shown to readers, rendered by the
+	// service in the configured zone and format. TimeText falls back to the
+	// default layout when it is empty.
+	DisplayTime string `json:"display_time,omitempty"`
+
+	// Event is the originating event, with Data holding the typed payload
+	// (e.g. types.Trade). It is zero for service messages such as the
+	// startup notification.
+	Event eventbus.Event `json:"-"`
+}
+
+// NotificationMessenger is implemented by messengers that render structured
+// notifications. Deliveries must honour the deadline and cancellation of ctx.
+type NotificationMessenger interface {
+	Messenger
+	SendNotification(ctx context.Context, notification Notification) error
+}
+
+// Message returns the title and body as a single line.
+func (n Notification) Message() string {
+	if n.Body == "" {
+		return n.Title
+	}
+	if n.Title == "" {
+		return n.Body
+	}
+	return fmt.Sprintf("%s: %s", n.Title, n.Body)
+}
+
+// Text returns the notification flattened into the single line sent to
+// string-only messengers, prefixed with its timestamp.
+func (n Notification) Text() string {
+	timeText := n.TimeText()
+	if timeText == "" {
+		return n.Message()
+	}
+	return fmt.Sprintf("[%s] %s", timeText, n.Message())
+}
+
+// TimeText returns the timestamp as shown to readers, or an empty string if
+// the notification has none.
+func (n Notification) TimeText() string {
+	if n.DisplayTime != "" {
+		return n.DisplayTime
+	}
+	if n.Timestamp.IsZero() {
+		return ""
+	}
+	return n.Timestamp.Format("2006-01-02 15:04:05")
+}
+
+// Field returns the value of the named field and whether it is present.
+func (n Notification) Field(name string) (string, bool) {
+	for _, field := range n.Fields {
+		if field.Name == name {
+			return field.Value, true
+		}
+	}
+	return "", false
+}
+
+// Adapt returns m as a NotificationMessenger. Messengers that only implement
+// SendMessage are wrapped so they receive the flattened Text.
+func Adapt(m Messenger) NotificationMessenger {
+	if nm, ok := m.(NotificationMessenger); ok {
+		return nm
+	}
+	return &textAdapter{Messenger: m}
+}
+
+// textAdapter delivers notifications to a string-only messenger
+type textAdapter struct {
+	Messenger
+}
+
+// SendNotification sends the flattened notification text. Messengers that
+// do not implement ContextMessenger cannot be interrupted once started.
+func (a *textAdapter) SendNotification(ctx context.Context, notification Notification) error {
+	if cm, ok := a.Messenger.(ContextMessenger); ok {
+		return cm.SendMessageContext(ctx, notification.Text())
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return a.SendMessage(notification.Text())
+}
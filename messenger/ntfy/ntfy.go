@@ -0,0 +1,178 @@
+package ntfy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/evdnx/gonotify/eventbus"
+	"github.com/evdnx/gonotify/messenger"
+	"github.com/evdnx/gonotify/types"
+)
+
+// ntfy message priorities
+const (
+	PriorityMin     = 1
+	PriorityLow     = 2
+	PriorityDefault = 3
+	PriorityHigh    = 4
+	PriorityUrgent  = 5
+)
+
+// defaultTimeout bounds SendMessage, which takes no context
+const defaultTimeout = 10 * time.Second
+
+// Client is a client for publishing notifications to an ntfy topic
+type Client struct {
+	serverURL  string
+	topic      string
+	token      string
+	httpClient *http.Client
+}
+
+// Message represents a JSON message published to ntfy
+type Message struct {
+	Topic    string   `json:"topic"`
+	Title    string   `json:"title,omitempty"`
+	Message  string   `json:"message"`
+	Priority int      `json:"priority,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+}
+
+// Response represents an error response from the ntfy server
+type Response struct {
+	Code  int    `json:"code"`
+	Error string `json:"error"`
+}
+
+// NewClient creates a new ntfy client. The token is an optional access token
+// for protected topics.
+func NewClient(serverURL, topic, token string) *Client {
+	if serverURL == "" {
+		serverURL = "https://ntfy.sh"
+	}
+	return &Client{
+		serverURL:  strings.TrimRight(serverURL, "/"),
+		topic:      topic,
+		token:      token,
+		httpClient: &http.Client{},
+	}
+}
+
+// SendMessage publishes a message with the default priority
+func (c *Client) SendMessage(message string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+	return c.publish(ctx, Message{
+		Topic:    c.topic,
+		Message:  message,
+		Priority: PriorityDefault,
+	})
+}
+
+// SendNotification publishes the notification with a priority and tags that
+// reflect its event and severity
+func (c *Client) SendNotification(ctx context.Context, notification messenger.Notification) error {
+	body := notification.Body
+	if timeText := notification.TimeText(); timeText != "" {
+		body = strings.TrimSpace(fmt.Sprintf("[%s] %s", timeText, body))
+	}
+	if len(notification.Fields) > 0 {
+		lines := make([]string, len(notification.Fields))
+		for i, field := range notification.Fields {
+			lines[i] = fmt.Sprintf("%s: %s", field.Name, field.Value)
+		}
+		body = strings.TrimSpace(body + "\n\n" + strings.Join(lines, "\n"))
+	}
+	priority, tags := classify(notification)
+	return c.publish(ctx, Message{
+		Topic:    c.topic,
+		Title:    notification.Title,
+		Message:  body,
+		Priority: priority,
+		Tags:     tags,
+	})
+}
+
+// publish posts the message to the server's JSON publishing endpoint
+func (c *Client) publish(ctx context.Context, payload Message) error {
+	// Convert payload to JSON
+	jsonPayload, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message payload: %w", err)
+	}
+
+	// Create the request
+	req, err := http.NewRequestWithContext(ctx, "POST", c.serverURL, bytes.NewBuffer(jsonPayload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	// Send the request
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Check the response
+	if resp.StatusCode != http.StatusOK {
+		var ntfyResponse Response
+		json.NewDecoder(resp.Body).Decode(&ntfyResponse)
+		return &messenger.HTTPError{StatusCode: resp.StatusCode, Err: fmt.Errorf("failed to send message: %s (status: %d)", ntfyResponse.Error, resp.StatusCode)}
+	}
+
+	return nil
+}
+
+// classify maps a notification to an ntfy priority and emoji tags
+func classify(notification messenger.Notification) (int, []string) {
+	switch notification.EventType {
+	case eventbus.EventSystemError, eventbus.EventStrategyError:
+		return PriorityUrgent, []string{"rotating_light"}
+	case eventbus.EventSystemRecovered, eventbus.EventStrategyRecovered:
+		return PriorityDefault, []string{"white_check_mark"}
+	case eventbus.EventPositionClosed:
+		return PriorityHigh, []string{"lock"}
+	case eventbus.EventPositionOpened:
+		return PriorityDefault, []string{"unlock"}
+	case eventbus.EventTradeExecuted:
+		return PriorityDefault, []string{"moneybag"}
+	case eventbus.EventOrderFilled:
+		if order, ok := notification.Event.Data.(types.Order); ok {
+			switch order.Type {
+			case "stop", "stop_market":
+				return PriorityHigh, []string{"octagonal_sign"}
+			case "take_profit", "take_profit_market":
+				return PriorityHigh, []string{"dart"}
+			}
+		}
+		return PriorityDefault, []string{"memo"}
+	case eventbus.EventPnLUpdate:
+		if update, ok := notification.Event.Data.(types.PnLUpdate); ok && update.PnL < 0 {
+			return PriorityDefault, []string{"chart_with_downwards_trend"}
+		}
+		return PriorityDefault, []string{"chart_with_upwards_trend"}
+	}
+
+	switch notification.Severity {
+	case messenger.SeverityCritical:
+		return PriorityUrgent, []string{"rotating_light"}
+	case messenger.SeverityError, messenger.SeverityWarning:
+		return PriorityHigh, []string{"warning"}
+	}
+	return PriorityDefault, nil
+}
+
+// Name returns the name of the messenger
+func (c *Client) Name() string {
+	return "ntfy"
+}
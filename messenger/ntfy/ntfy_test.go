@@ -0,0 +1,69 @@
+package ntfy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/evdnx/gonotify/eventbus"
+	"github.com/evdnx/gonotify/messenger"
+	"github.com/evdnx/gonotify/types"
+)
+
+func TestSendNotificationPriorityAndTags(t *testing.T) {
+	var received Message
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&received)
+		w.Write([]byte(`{"id":"abc"}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "trading", "tk_secret")
+	notification := messenger.Notification{
+		Title:     "🛑 Order Filled",
+		Body:      "sell BTCUSDT",
+		Fields:    []messenger.Field{{Name: "Price", Value: "42000"}, {Name: "Quantity", Value: "0.5"}},
+		EventType: eventbus.EventOrderFilled,
+		Timestamp: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Event: eventbus.Event{
+			Type: eventbus.EventOrderFilled,
+			Data: types.Order{Symbol: "BTCUSDT", Type: "stop"},
+		},
+	}
+	if err := client.SendNotification(context.Background(), notification); err != nil {
+		t.Fatalf("SendNotification failed: %v", err)
+	}
+
+	if authorization != "Bearer tk_secret" {
+		t.Fatalf("unexpected authorization header: %q", authorization)
+	}
+	if received.Topic != "trading" || received.Title != "🛑 Order Filled" {
+		t.Fatalf("unexpected message: %+v", received)
+	}
+	if received.Message != "[2024-01-01 00:00:00] sell BTCUSDT\n\nPrice: 42000\nQuantity: 0.5" {
+		t.Fatalf("unexpected body: %q", received.Message)
+	}
+	if received.Priority != PriorityHigh || len(received.Tags) != 1 || received.Tags[0] != "octagonal_sign" {
+		t.Fatalf("unexpected priority/tags: %d %v", received.Priority, received.Tags)
+	}
+}
+
+func TestSendMessageError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(Response{Code: 40301, Error: "forbidden"})
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "trading", "")
+	err := client.SendMessage("hello")
+	if err == nil || !strings.Contains(err.Error(), "forbidden") {
+		t.Fatalf("expected forbidden error, got %v", err)
+	}
+}
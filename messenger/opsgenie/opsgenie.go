@@ -0,0 +1,193 @@
+package opsgenie
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/evdnx/gonotify/eventbus"
+	"github.com/evdnx/gonotify/messenger"
+)
+
+// Client is a client for creating and closing alerts through the Opsgenie
+// Alerts API
+type Client struct {
+	apiKey     string
+	events     map[eventbus.EventType]bool
+	httpClient *http.Client
+	apiURL     string
+}
+
+// Alert represents an Opsgenie alert creation request
+type Alert struct {
+	Message     string      `json:"message"`
+	Alias       string      `json:"alias,omitempty"`
+	Description string      `json:"description,omitempty"`
+	Tags        []string    `json:"tags,omitempty"`
+	Details     interface{} `json:"details,omitempty"`
+	Source      string      `json:"source,omitempty"`
+	Priority    string      `json:"priority,omitempty"` // "P1" to "P5"
+}
+
+// CloseRequest represents an Opsgenie alert close request
+type CloseRequest struct {
+	Source string `json:"source,omitempty"`
+	Note   string `json:"note,omitempty"`
+}
+
+// Response represents the response from the Alerts API
+type Response struct {
+	Result    string `json:"result,omitempty"`
+	Message   string `json:"message,omitempty"`
+	RequestID string `json:"requestId"`
+}
+
+// NewClient creates a new Opsgenie client. apiURL selects the region and
+// defaults to https://api.opsgenie.com. Only events whose type (or, for
+// recovery events, the error type they recover from) is listed in pageOn
+// create or close alerts; when pageOn is empty, system and strategy errors
+// page.
+func NewClient(apiKey, apiURL string, pageOn []eventbus.EventType) *Client {
+	if apiURL == "" {
+		apiURL = "https://api.opsgenie.com"
+	}
+	if len(pageOn) == 0 {
+		pageOn = []eventbus.EventType{eventbus.EventSystemError, eventbus.EventStrategyError}
+	}
+	events := make(map[eventbus.EventType]bool, len(pageOn))
+	for _, eventType := range pageOn {
+		events[eventType] = true
+	}
+	return &Client{
+		apiKey:     apiKey,
+		events:     events,
+		httpClient: &http.Client{},
+		apiURL:     strings.TrimRight(apiURL, "/"),
+	}
+}
+
+// SendMessage ignores plain messages; only events listed for paging create
+// alerts
+func (c *Client) SendMessage(message string) error {
+	return nil
+}
+
+// SendNotification creates or closes the alert the notification's event
+// maps to. Opsgenie deduplicates open alerts by alias, so repeated errors
+// increase the count of a single alert.
+func (c *Client) SendNotification(ctx context.Context, notification messenger.Notification) error {
+	event := notification.Event
+	if event.Type == "" {
+		return nil
+	}
+
+	alert := messenger.AlertFor(event)
+	if !c.events[alert.Trigger] {
+		return nil
+	}
+
+	text := notification.Message()
+
+	if alert.Resolve {
+		endpoint := fmt.Sprintf("%s/v2/alerts/%s/close?identifierType=alias", c.apiURL, url.PathEscape(alert.Key))
+		return c.post(ctx, endpoint, CloseRequest{Source: "gonotify", Note: text})
+	}
+
+	endpoint := fmt.Sprintf("%s/v2/alerts", c.apiURL)
+	return c.post(ctx, endpoint, Alert{
+		Message:     truncate(text, 130),
+		Alias:       alert.Key,
+		Description: truncate(text, 15000),
+		Tags:        append([]string{"gonotify", string(event.Type)}, notification.Tags...),
+		Details:     details(event.Data),
+		Source:      "gonotify",
+		Priority:    priority(alert.Trigger),
+	})
+}
+
+// post sends an authenticated request to the Alerts API
+func (c *Client) post(ctx context.Context, endpoint string, payload interface{}) error {
+	// Convert payload to JSON
+	jsonPayload, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal alert payload: %w", err)
+	}
+
+	// Create the request
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewBuffer(jsonPayload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "GenieKey "+c.apiKey)
+
+	// Send the request
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send alert: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Check the response
+	if resp.StatusCode != http.StatusAccepted {
+		var ogResponse Response
+		json.NewDecoder(resp.Body).Decode(&ogResponse)
+		return &messenger.HTTPError{StatusCode: resp.StatusCode, Err: fmt.Errorf("failed to send alert: %s (status: %d)", ogResponse.Message, resp.StatusCode)}
+	}
+
+	return nil
+}
+
+// details converts a typed payload into the string map Opsgenie expects
+func details(data interface{}) map[string]string {
+	if data == nil {
+		return nil
+	}
+	if text, ok := data.(string); ok {
+		return map[string]string{"error": text}
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil
+	}
+
+	result := make(map[string]string, len(fields))
+	for key, value := range fields {
+		result[key] = fmt.Sprint(value)
+	}
+	return result
+}
+
+// priority returns the alert priority for an error event type
+func priority(eventType eventbus.EventType) string {
+	switch eventType {
+	case eventbus.EventSystemError:
+		return "P1"
+	case eventbus.EventStrategyError:
+		return "P2"
+	}
+	return "P3"
+}
+
+// truncate shortens text to at most limit characters
+func truncate(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	return string(runes[:limit-3]) + "..."
+}
+
+// Name returns the name of the messenger
+func (c *Client) Name() string {
+	return "Opsgenie"
+}
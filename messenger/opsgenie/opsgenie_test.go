@@ -0,0 +1,67 @@
+package opsgenie
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/evdnx/gonotify/eventbus"
+	"github.com/evdnx/gonotify/messenger"
+)
+
+func TestCreateAndCloseSystemErrorAlert(t *testing.T) {
+	var paths []string
+	var alert Alert
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.RequestURI())
+		authorization = r.Header.Get("Authorization")
+		if r.URL.Path == "/v2/alerts" {
+			json.NewDecoder(r.Body).Decode(&alert)
+		}
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(Response{Result: "Request will be processed", RequestID: "req-1"})
+	}))
+	defer server.Close()
+
+	client := NewClient("genie-key", server.URL, nil)
+
+	if err := client.SendNotification(context.Background(), notificationFor(eventbus.Event{Type: eventbus.EventSystemError, Data: "connection lost"},
+		"🚨 System Error", "connection lost")); err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	if err := client.SendNotification(context.Background(), notificationFor(eventbus.Event{Type: eventbus.EventSystemRecovered, Data: "reconnected"},
+		"✅ System Recovered", "reconnected")); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+
+	if authorization != "GenieKey genie-key" {
+		t.Fatalf("unexpected authorization header: %q", authorization)
+	}
+	if alert.Alias != "system_error" || alert.Priority != "P1" || alert.Message != "🚨 System Error: connection lost" {
+		t.Fatalf("unexpected alert: %+v", alert)
+	}
+	if len(paths) != 2 || paths[1] != "/v2/alerts/system_error/close?identifierType=alias" {
+		t.Fatalf("unexpected requests: %v", paths)
+	}
+}
+
+func TestCreateAlertError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(Response{Message: "Key format is not valid!", RequestID: "req-2"})
+	}))
+	defer server.Close()
+
+	client := NewClient("bad-key", server.URL, nil)
+	err := client.SendNotification(context.Background(), notificationFor(eventbus.Event{Type: eventbus.EventSystemError, Data: "boom"}, "🚨 System Error", "boom"))
+	if err == nil {
+		t.Fatal("expected error for unauthorized request")
+	}
+}
+
+func notificationFor(event eventbus.Event, title, body string) messenger.Notification {
+	return messenger.Notification{Title: title, Body: body, EventType: event.Type, Event: event}
+}
@@ -0,0 +1,177 @@
+package pagerduty
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/evdnx/gonotify/eventbus"
+	"github.com/evdnx/gonotify/messenger"
+)
+
+// Client is a client for opening and resolving incidents through the
+// PagerDuty Events API v2
+type Client struct {
+	routingKey string
+	source     string
+	events     map[eventbus.EventType]bool
+	httpClient *http.Client
+	apiURL     string
+}
+
+// Event represents a PagerDuty Events API v2 event
+type Event struct {
+	RoutingKey  string   `json:"routing_key"`
+	EventAction string   `json:"event_action"` // "trigger" or "resolve"
+	DedupKey    string   `json:"dedup_key,omitempty"`
+	Payload     *Payload `json:"payload,omitempty"`
+}
+
+// Payload describes the incident for trigger events
+type Payload struct {
+	Summary       string      `json:"summary"`
+	Source        string      `json:"source"`
+	Severity      string      `json:"severity"` // "critical", "error", "warning" or "info"
+	Timestamp     string      `json:"timestamp,omitempty"`
+	Class         string      `json:"class,omitempty"`
+	CustomDetails interface{} `json:"custom_details,omitempty"`
+}
+
+// Response represents the response from the Events API
+type Response struct {
+	Status   string   `json:"status"`
+	Message  string   `json:"message"`
+	DedupKey string   `json:"dedup_key,omitempty"`
+	Errors   []string `json:"errors,omitempty"`
+}
+
+// NewClient creates a new PagerDuty client. Only events whose type (or, for
+// recovery events, the error type they recover from) is listed in pageOn
+// open or resolve incidents; when pageOn is empty, system and strategy
+// errors page.
+func NewClient(routingKey, source string, pageOn []eventbus.EventType) *Client {
+	if source == "" {
+		source = "gonotify"
+	}
+	if len(pageOn) == 0 {
+		pageOn = []eventbus.EventType{eventbus.EventSystemError, eventbus.EventStrategyError}
+	}
+	events := make(map[eventbus.EventType]bool, len(pageOn))
+	for _, eventType := range pageOn {
+		events[eventType] = true
+	}
+	return &Client{
+		routingKey: routingKey,
+		source:     source,
+		events:     events,
+		httpClient: &http.Client{},
+		apiURL:     "https://events.pagerduty.com",
+	}
+}
+
+// SendMessage ignores plain messages; only events listed for paging open
+// incidents
+func (c *Client) SendMessage(message string) error {
+	return nil
+}
+
+// SendNotification triggers or resolves the incident the notification's
+// event maps to
+func (c *Client) SendNotification(ctx context.Context, notification messenger.Notification) error {
+	event := notification.Event
+	if event.Type == "" {
+		return nil
+	}
+
+	alert := messenger.AlertFor(event)
+	if !c.events[alert.Trigger] {
+		return nil
+	}
+
+	pdEvent := Event{
+		RoutingKey: c.routingKey,
+		DedupKey:   alert.Key,
+	}
+
+	if alert.Resolve {
+		pdEvent.EventAction = "resolve"
+	} else {
+		pdEvent.EventAction = "trigger"
+		pdEvent.Payload = &Payload{
+			Summary:       summary(notification.Message()),
+			Source:        c.source,
+			Severity:      severity(alert.Trigger),
+			Class:         string(event.Type),
+			CustomDetails: event.Data,
+		}
+		if !event.Timestamp.IsZero() {
+			pdEvent.Payload.Timestamp = event.Timestamp.UTC().Format(time.RFC3339)
+		}
+	}
+
+	return c.enqueue(ctx, pdEvent)
+}
+
+// enqueue posts the event to the Events API
+func (c *Client) enqueue(ctx context.Context, pdEvent Event) error {
+	// Convert payload to JSON
+	jsonPayload, err := json.Marshal(pdEvent)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event payload: %w", err)
+	}
+
+	// Create the request
+	url := fmt.Sprintf("%s/v2/enqueue", c.apiURL)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonPayload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	// Send the request
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send event: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Parse response
+	var pdResponse Response
+	json.NewDecoder(resp.Body).Decode(&pdResponse)
+
+	// Check the response
+	if resp.StatusCode != http.StatusAccepted {
+		return &messenger.HTTPError{StatusCode: resp.StatusCode, Err: fmt.Errorf("failed to send event: %s %s (status: %d)",
+			pdResponse.Message, strings.Join(pdResponse.Errors, "; "), resp.StatusCode)}
+	}
+
+	return nil
+}
+
+// severity returns the incident severity for an error event type
+func severity(eventType eventbus.EventType) string {
+	switch eventType {
+	case eventbus.EventSystemError:
+		return "critical"
+	case eventbus.EventStrategyError:
+		return "error"
+	}
+	return "warning"
+}
+
+// summary truncates the message to the 1024 characters PagerDuty accepts
+func summary(message string) string {
+	if runes := []rune(message); len(runes) > 1024 {
+		message = string(runes[:1021]) + "..."
+	}
+	return message
+}
+
+// Name returns the name of the messenger
+func (c *Client) Name() string {
+	return "PagerDuty"
+}
@@ -0,0 +1,92 @@
+package pagerduty
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/evdnx/gonotify/eventbus"
+	"github.com/evdnx/gonotify/messenger"
+	"github.com/evdnx/gonotify/types"
+)
+
+func newTestServer(t *testing.T, received *[]Event) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/enqueue" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		var event Event
+		json.NewDecoder(r.Body).Decode(&event)
+		*received = append(*received, event)
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(Response{Status: "success", Message: "Event processed", DedupKey: event.DedupKey})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestTriggerAndResolveStrategyError(t *testing.T) {
+	var received []Event
+	server := newTestServer(t, &received)
+
+	client := NewClient("routing-key", "bot-1", nil)
+	client.apiURL = server.URL
+
+	failure := eventbus.Event{
+		Type: eventbus.EventStrategyError,
+		Data: types.StrategyError{Strategy: "mean-revert", Error: "division by zero"},
+	}
+	if err := client.SendNotification(context.Background(), notificationFor(failure, "🚨 Strategy Error in mean-revert", "division by zero")); err != nil {
+		t.Fatalf("trigger failed: %v", err)
+	}
+	if err := client.SendNotification(context.Background(), notificationFor(failure, "🚨 Strategy Error in mean-revert", "division by zero")); err != nil {
+		t.Fatalf("repeat trigger failed: %v", err)
+	}
+
+	recovery := eventbus.Event{
+		Type: eventbus.EventStrategyRecovered,
+		Data: types.StrategyRecovery{Strategy: "mean-revert"},
+	}
+	if err := client.SendNotification(context.Background(), notificationFor(recovery, "✅ Strategy Recovered", "mean-revert")); err != nil {
+		t.Fatalf("resolve failed: %v", err)
+	}
+
+	if len(received) != 3 {
+		t.Fatalf("expected 3 events, got %d", len(received))
+	}
+	if received[0].DedupKey != "strategy_error:mean-revert" || received[0].DedupKey != received[1].DedupKey {
+		t.Fatalf("expected stable dedup key, got %q and %q", received[0].DedupKey, received[1].DedupKey)
+	}
+	if received[0].EventAction != "trigger" || received[0].Payload.Severity != "error" || received[0].Payload.Source != "bot-1" {
+		t.Fatalf("unexpected trigger event: %+v", received[0])
+	}
+	if received[2].EventAction != "resolve" || received[2].DedupKey != received[0].DedupKey {
+		t.Fatalf("unexpected resolve event: %+v", received[2])
+	}
+}
+
+func TestIgnoresUnroutedEvents(t *testing.T) {
+	var received []Event
+	server := newTestServer(t, &received)
+
+	client := NewClient("routing-key", "", []eventbus.EventType{eventbus.EventSystemError})
+	client.apiURL = server.URL
+
+	client.SendMessage("🤖 Notification service started")
+	client.SendNotification(context.Background(), notificationFor(eventbus.Event{
+		Type: eventbus.EventStrategyError,
+		Data: types.StrategyError{Strategy: "mean-revert"},
+	}, "🚨 Strategy Error in mean-revert", "boom"))
+	client.SendNotification(context.Background(), notificationFor(eventbus.Event{Type: eventbus.EventTradeExecuted, Data: types.Trade{ID: "t1"}}, "💰 Trade Executed", ""))
+
+	if len(received) != 0 {
+		t.Fatalf("expected no events, got %+v", received)
+	}
+}
+
+func notificationFor(event eventbus.Event, title, body string) messenger.Notification {
+	return messenger.Notification{Title: title, Body: body, EventType: event.Type, Event: event}
+}
@@ -0,0 +1,91 @@
+package messenger
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// progressTTL is how long the progress of a failed delivery is kept for a
+// retry to resume from. Later deliveries of the same message start over.
+const progressTTL = time.Hour
+
+// Progress remembers how far deliveries of messages split into parts got, so
+// a retry resumes at the part that failed instead of sending the earlier
+// parts again. Deliveries are told apart by the content of their parts.
+// The zero value is ready to use.
+type Progress struct {
+	mu         sync.Mutex
+	deliveries map[string]*progress
+	last       int64 // stamp of the latest delivery, keeping IDs unique
+}
+
+// progress is the state of one delivery
+type progress struct {
+	id      string
+	sent    int
+	updated time.Time
+}
+
+// Resume returns the ID of the delivery of parts and the number of parts
+// earlier attempts sent. The ID stays the same across the attempts until
+// the delivery is finished, so it can serve as an idempotency key.
+func (p *Progress) Resume(parts []string) (string, int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	for key, d := range p.deliveries {
+		if now.Sub(d.updated) > progressTTL {
+			delete(p.deliveries, key)
+		}
+	}
+
+	key := digest(parts)
+	if d, ok := p.deliveries[key]; ok {
+		return d.id, d.sent
+	}
+	if p.deliveries == nil {
+		p.deliveries = make(map[string]*progress)
+	}
+	p.last = max(p.last+1, now.UnixNano())
+	d := &progress{id: fmt.Sprintf("%s.%d", key, p.last), updated: now}
+	p.deliveries[key] = d
+	return d.id, 0
+}
+
+// Advance records that the first sent parts of the delivery went out
+func (p *Progress) Advance(id string, sent int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key, _, _ := strings.Cut(id, ".")
+	if d, ok := p.deliveries[key]; ok && d.id == id {
+		d.sent = sent
+		d.updated = time.Now()
+	}
+}
+
+// Finish forgets a delivery once all its parts went out, so sending the same
+// message again starts a new delivery
+func (p *Progress) Finish(id string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key, _, _ := strings.Cut(id, ".")
+	if d, ok := p.deliveries[key]; ok && d.id == id {
+		delete(p.deliveries, key)
+	}
+}
+
+// digest identifies the content of a message split into parts
+func digest(parts []string) string {
+	h := sha256.New()
+	for _, part := range parts {
+		fmt.Fprintf(h, "%d:%s", len(part), part)
+	}
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
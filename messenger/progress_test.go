@@ -0,0 +1,28 @@
+package messenger
+
+import "testing"
+
+func TestProgress(t *testing.T) {
+	var progress Progress
+	parts := []string{"1/2 first", "2/2 second"}
+
+	id, sent := progress.Resume(parts)
+	if sent != 0 {
+		t.Fatalf("expected a new delivery, got %d part(s) sent", sent)
+	}
+	progress.Advance(id, 1)
+
+	retry, sent := progress.Resume(parts)
+	if retry != id || sent != 1 {
+		t.Fatalf("expected to resume %s after 1 part, got %s after %d", id, retry, sent)
+	}
+	if other, sent := progress.Resume([]string{"1/2 other", "2/2 second"}); other == id || sent != 0 {
+		t.Fatalf("expected a separate delivery for other parts, got %s after %d", other, sent)
+	}
+
+	progress.Finish(id)
+	again, sent := progress.Resume(parts)
+	if again == id || sent != 0 {
+		t.Fatalf("expected a new delivery after finishing, got %s after %d", again, sent)
+	}
+}
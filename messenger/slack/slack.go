@@ -0,0 +1,262 @@
+package slack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/evdnx/gonotify/messenger"
+)
+
+// defaultTimeout bounds SendMessage, which takes no context
+const defaultTimeout = 10 * time.Second
+
+// Block Kit's limits, in characters of mrkdwn text
+const (
+	MaxSectionTextLength = 3000
+	MaxFieldTextLength   = 2000
+	MaxSectionFields     = 10
+)
+
+// ellipsis marks text cut to fit Block Kit's limits
+const ellipsis = "…"
+
+// Client is a client for sending messages to Slack, either through an
+// incoming webhook or through the chat.postMessage Web API.
+type Client struct {
+	webhookURL string
+	botToken   string
+	channel    string
+	httpClient *http.Client
+	apiURL     string
+}
+
+// Message represents a message to be sent to Slack
+type Message struct {
+	Channel string  `json:"channel,omitempty"`
+	Text    string  `json:"text"`
+	Blocks  []Block `json:"blocks,omitempty"`
+}
+
+// Block represents a Block Kit layout block
+type Block struct {
+	Type     string       `json:"type"`
+	Text     *TextObject  `json:"text,omitempty"`
+	Fields   []TextObject `json:"fields,omitempty"`
+	Elements []TextObject `json:"elements,omitempty"`
+}
+
+// TextObject represents a Block Kit text object
+type TextObject struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// Response represents the response from the Slack Web API
+type Response struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// NewWebhookClient creates a new Slack client that posts to an incoming webhook
+func NewWebhookClient(webhookURL string) *Client {
+	return &Client{
+		webhookURL: webhookURL,
+		httpClient: &http.Client{},
+		apiURL:     "https://slack.com/api",
+	}
+}
+
+// NewClient creates a new Slack client that posts through chat.postMessage
+func NewClient(botToken, channel string) *Client {
+	return &Client{
+		botToken:   botToken,
+		channel:    channel,
+		httpClient: &http.Client{},
+		apiURL:     "https://slack.com/api",
+	}
+}
+
+// SendMessage sends a plain-text message to the Slack channel
+func (c *Client) SendMessage(message string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+	return c.send(ctx, Message{
+		Text: message,
+		Blocks: []Block{
+			{Type: "section", Text: &TextObject{Type: "mrkdwn", Text: escapeWithin(message, MaxSectionTextLength)}},
+		},
+	})
+}
+
+// SendNotification renders the notification as Block Kit blocks and sends it
+func (c *Client) SendNotification(ctx context.Context, notification messenger.Notification) error {
+	return c.send(ctx, Message{
+		Text:   notification.Text(),
+		Blocks: renderBlocks(notification),
+	})
+}
+
+// send delivers the payload through the webhook or the Web API
+func (c *Client) send(ctx context.Context, payload Message) error {
+	if c.webhookURL != "" {
+		return c.postWebhook(ctx, payload)
+	}
+
+	payload.Channel = c.channel
+	return c.postMessage(ctx, payload)
+}
+
+// postWebhook delivers the payload to the configured incoming webhook
+func (c *Client) postWebhook(ctx context.Context, payload Message) error {
+	// Convert payload to JSON
+	jsonPayload, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message payload: %w", err)
+	}
+
+	// Create the request
+	req, err := http.NewRequestWithContext(ctx, "POST", c.webhookURL, bytes.NewBuffer(jsonPayload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	// Send the request
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Incoming webhooks answer with a plain-text body describing the error
+	if resp.StatusCode != http.StatusOK {
+		responseBody, _ := io.ReadAll(resp.Body)
+		return &messenger.HTTPError{StatusCode: resp.StatusCode, Err: fmt.Errorf("failed to send message: %s (status: %d)", strings.TrimSpace(string(responseBody)), resp.StatusCode)}
+	}
+
+	return nil
+}
+
+// postMessage delivers the payload through the chat.postMessage Web API
+func (c *Client) postMessage(ctx context.Context, payload Message) error {
+	// Convert payload to JSON
+	jsonPayload, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message payload: %w", err)
+	}
+
+	// Create the request
+	url := fmt.Sprintf("%s/chat.postMessage", c.apiURL)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonPayload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Authorization", "Bearer "+c.botToken)
+
+	// Send the request
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Check the status before parsing, since errors in front of the API may
+	// come as HTML or plain text
+	if resp.StatusCode != http.StatusOK {
+		responseBody, _ := io.ReadAll(resp.Body)
+		return &messenger.HTTPError{StatusCode: resp.StatusCode, Err: fmt.Errorf("failed to send message: %s (status: %d)", strings.TrimSpace(string(responseBody)), resp.StatusCode)}
+	}
+
+	// Parse response
+	var slackResponse Response
+	if err := json.NewDecoder(resp.Body).Decode(&slackResponse); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	if !slackResponse.OK {
+		return &messenger.HTTPError{StatusCode: resp.StatusCode, Err: fmt.Errorf("failed to send message: %s (status: %d)", slackResponse.Error, resp.StatusCode)}
+	}
+
+	return nil
+}
+
+// renderBlocks converts a notification into Block Kit blocks: a section with
+// the bold title and body, sections of up to ten fields each, and a context
+// block carrying the timestamp. Text over Block Kit's limits is cut short
+// with an ellipsis, leaving the title room before the body.
+func renderBlocks(notification messenger.Notification) []Block {
+	headline := fmt.Sprintf("*%s*", escapeWithin(notification.Title, MaxSectionTextLength/2))
+	if notification.Body != "" {
+		headline += "\n" + escapeWithin(notification.Body, MaxSectionTextLength-utf8.RuneCountInString(headline)-1)
+	}
+
+	blocks := []Block{
+		{
+			Type: "section",
+			Text: &TextObject{Type: "mrkdwn", Text: headline},
+		},
+	}
+
+	// Slack accepts at most ten fields per section, so the rest go into
+	// further sections
+	for start := 0; start < len(notification.Fields); start += MaxSectionFields {
+		var fields []TextObject
+		for _, field := range notification.Fields[start:min(start+MaxSectionFields, len(notification.Fields))] {
+			name := fmt.Sprintf("*%s*", escapeWithin(field.Name, MaxFieldTextLength/2))
+			fields = append(fields, TextObject{
+				Type: "mrkdwn",
+				Text: name + "\n" + escapeWithin(field.Value, MaxFieldTextLength-utf8.RuneCountInString(name)-1),
+			})
+		}
+		blocks = append(blocks, Block{Type: "section", Fields: fields})
+	}
+
+	if timeText := notification.TimeText(); timeText != "" {
+		blocks = append(blocks, Block{
+			Type:     "context",
+			Elements: []TextObject{{Type: "mrkdwn", Text: escape(timeText)}},
+		})
+	}
+
+	return blocks
+}
+
+// escape escapes the control characters Slack interprets in mrkdwn text
+func escape(text string) string {
+	replacer := strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	return replacer.Replace(text)
+}
+
+// escapeWithin escapes text for mrkdwn, cutting it to at most limit
+// characters with an ellipsis. Text is only cut between escapes, so no
+// entity is left half-written.
+func escapeWithin(text string, limit int) string {
+	escaped := escape(text)
+	if utf8.RuneCountInString(escaped) <= limit {
+		return escaped
+	}
+
+	var b strings.Builder
+	length := 0
+	for _, r := range text {
+		e := escape(string(r))
+		if length+utf8.RuneCountInString(e) > limit-1 {
+			break
+		}
+		b.WriteString(e)
+		length += utf8.RuneCountInString(e)
+	}
+	return b.String() + ellipsis
+}
+
+// Name returns the name of the messenger
+func (c *Client) Name() string {
+	return "Slack"
+}
@@ -0,0 +1,161 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/evdnx/gonotify/messenger"
+)
+
+func TestWebhookSendMessage(t *testing.T) {
+	var received Message
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("failed to decode payload: %v", err)
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	client := NewWebhookClient(server.URL)
+	notification := messenger.Notification{
+		Title:     "💰 Trade Executed",
+		Body:      "buy BTCUSDT",
+		Fields:    []messenger.Field{{Name: "Symbol", Value: "BTCUSDT"}, {Name: "Price", Value: "68000.00"}},
+		Timestamp: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	if err := client.SendNotification(context.Background(), notification); err != nil {
+		t.Fatalf("SendNotification failed: %v", err)
+	}
+
+	if len(received.Blocks) != 3 {
+		t.Fatalf("expected 3 blocks, got %d", len(received.Blocks))
+	}
+	if received.Blocks[0].Text.Text != "*💰 Trade Executed*\nbuy BTCUSDT" {
+		t.Fatalf("unexpected headline: %q", received.Blocks[0].Text.Text)
+	}
+	if len(received.Blocks[1].Fields) != 2 || received.Blocks[1].Fields[0].Text != "*Symbol*\nBTCUSDT" {
+		t.Fatalf("unexpected fields: %+v", received.Blocks[1].Fields)
+	}
+	if received.Blocks[2].Elements[0].Text != "2024-01-01 00:00:00" {
+		t.Fatalf("unexpected context: %q", received.Blocks[2].Elements[0].Text)
+	}
+	if received.Text != "[2024-01-01 00:00:00] 💰 Trade Executed: buy BTCUSDT" {
+		t.Fatalf("unexpected fallback text: %q", received.Text)
+	}
+}
+
+func TestWebhookSendMessageError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("no_service"))
+	}))
+	defer server.Close()
+
+	client := NewWebhookClient(server.URL)
+	err := client.SendMessage("hello")
+	if err == nil || !strings.Contains(err.Error(), "no_service") {
+		t.Fatalf("expected no_service error, got %v", err)
+	}
+}
+
+func TestPostMessage(t *testing.T) {
+	var received Message
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat.postMessage" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer xoxb-token" {
+			t.Errorf("unexpected authorization header: %q", got)
+		}
+		json.NewDecoder(r.Body).Decode(&received)
+		json.NewEncoder(w).Encode(Response{OK: true})
+	}))
+	defer server.Close()
+
+	client := NewClient("xoxb-token", "#trading")
+	client.apiURL = server.URL
+	if err := client.SendMessage("hello <world>"); err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+
+	if received.Channel != "#trading" {
+		t.Fatalf("unexpected channel: %q", received.Channel)
+	}
+	if received.Blocks[0].Text.Text != "hello &lt;world&gt;" {
+		t.Fatalf("expected escaped text, got %q", received.Blocks[0].Text.Text)
+	}
+}
+
+func TestPostMessageAPIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(Response{OK: false, Error: "channel_not_found"})
+	}))
+	defer server.Close()
+
+	client := NewClient("xoxb-token", "#missing")
+	client.apiURL = server.URL
+	err := client.SendMessage("hello")
+	if err == nil || !strings.Contains(err.Error(), "channel_not_found") {
+		t.Fatalf("expected channel_not_found error, got %v", err)
+	}
+}
+
+func TestPostMessageGatewayError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+		w.Write([]byte("<html><body>502 Bad Gateway</body></html>"))
+	}))
+	defer server.Close()
+
+	client := NewClient("xoxb-token", "#alerts")
+	client.apiURL = server.URL
+	err := client.SendMessage("hello")
+	var httpErr *messenger.HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusBadGateway {
+		t.Fatalf("expected HTTP error with status 502, got %v", err)
+	}
+	if !messenger.Retryable(err) {
+		t.Errorf("expected a retryable error, got %v", err)
+	}
+}
+
+func TestRenderBlocksFitsBlockKitLimits(t *testing.T) {
+	notification := messenger.Notification{
+		Title: "Strategy Error",
+		Body:  strings.Repeat("panic: <nil> & more\n", 400),
+	}
+	for i := 0; i < 12; i++ {
+		notification.Fields = append(notification.Fields, messenger.Field{Name: fmt.Sprintf("Field %d", i), Value: strings.Repeat("v", 2500)})
+	}
+
+	blocks := renderBlocks(notification)
+
+	headline := blocks[0].Text.Text
+	if n := utf8.RuneCountInString(headline); n > MaxSectionTextLength || !strings.HasPrefix(headline, "*Strategy Error*\npanic: &lt;nil&gt; &amp; more") || !strings.HasSuffix(headline, "…") {
+		t.Errorf("unexpected headline of %d characters ending in %q", n, headline[len(headline)-20:])
+	}
+
+	// Fields past the tenth go into another section instead of being lost
+	if len(blocks) != 3 || len(blocks[1].Fields) != MaxSectionFields || len(blocks[2].Fields) != 2 {
+		t.Fatalf("expected two field sections of 10 and 2 fields, got %+v", blocks)
+	}
+	for _, block := range blocks[1:] {
+		for _, field := range block.Fields {
+			if n := utf8.RuneCountInString(field.Text); n > MaxFieldTextLength || !strings.HasSuffix(field.Text, "…") {
+				t.Errorf("expected a field of at most %d characters ending in an ellipsis, got %d", MaxFieldTextLength, n)
+			}
+		}
+	}
+	if blocks[2].Fields[1].Text[:11] != "*Field 11*\n" {
+		t.Errorf("unexpected last field %q", blocks[2].Fields[1].Text[:20])
+	}
+}
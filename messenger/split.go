@@ -0,0 +1,72 @@
+package messenger
+
+import "strings"
+
+// Pack groups items into parts in order, starting a new part whenever the
+// next item would take the size of the current part over limit. Items are
+// never broken up; break items larger than limit with SplitText first.
+func Pack[T any](items []T, limit int, size func(T) int) [][]T {
+	var parts [][]T
+	var current []T
+	used := 0
+	for _, item := range items {
+		itemSize := size(item)
+		if len(current) > 0 && used+itemSize > limit {
+			parts = append(parts, current)
+			current, used = nil, 0
+		}
+		current = append(current, item)
+		used += itemSize
+	}
+	if len(current) > 0 {
+		parts = append(parts, current)
+	}
+	return parts
+}
+
+// SplitText breaks text into pieces of at most limit, as measured by size,
+// preferring line breaks, then spaces, and cutting between characters only
+// when a single word is too long. size must be additive: the size of a string
+// is the sum of the sizes of its parts, as with character counts or escaping
+// that works character by character.
+func SplitText(text string, limit int, size func(string) int) []string {
+	return splitText(text, limit, size, []string{"\n", " ", ""})
+}
+
+// splitText splits text at the first separator and packs the tokens into
+// pieces, splitting tokens that are too long at the remaining separators
+func splitText(text string, limit int, size func(string) int, separators []string) []string {
+	if size(text) <= limit || len(separators) == 0 {
+		return []string{text}
+	}
+	sep := separators[0]
+	sepSize := size(sep)
+
+	var pieces, current []string
+	used := 0
+	flush := func() {
+		if len(current) > 0 {
+			pieces = append(pieces, strings.Join(current, sep))
+			current, used = nil, 0
+		}
+	}
+
+	for _, token := range strings.Split(text, sep) {
+		tokenSize := size(token)
+		if tokenSize > limit {
+			flush()
+			pieces = append(pieces, splitText(token, limit, size, separators[1:])...)
+			continue
+		}
+		if len(current) > 0 && used+sepSize+tokenSize > limit {
+			flush()
+		}
+		if len(current) > 0 {
+			used += sepSize
+		}
+		current = append(current, token)
+		used += tokenSize
+	}
+	flush()
+	return pieces
+}
@@ -0,0 +1,46 @@
+package messenger
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestPack(t *testing.T) {
+	parts := Pack([]string{"aaa", "bb", "c", "dddd", "e"}, 5, func(s string) int { return len(s) })
+	want := [][]string{{"aaa", "bb"}, {"c", "dddd"}, {"e"}}
+	if !reflect.DeepEqual(parts, want) {
+		t.Errorf("expected %v, got %v", want, parts)
+	}
+}
+
+func TestSplitText(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		limit int
+		want  []string
+	}{
+		{"fits", "short", 10, []string{"short"}},
+		{"lines", "one\ntwo\nthree", 8, []string{"one\ntwo", "three"}},
+		{"words", "a long line of words", 8, []string{"a long", "line of", "words"}},
+		{"characters", "abcdefghij", 4, []string{"abcd", "efgh", "ij"}},
+		{"multibyte", "ééééé", 2, []string{"éé", "éé", "é"}},
+	}
+	for _, tt := range tests {
+		got := SplitText(tt.text, tt.limit, utf8.RuneCountInString)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestSplitTextMeasuresEscapedSize(t *testing.T) {
+	escaped := func(s string) int { return len(s) + strings.Count(s, ".") }
+	for _, piece := range SplitText(strings.Repeat("a.", 20), 9, escaped) {
+		if escaped(piece) > 9 {
+			t.Errorf("piece %q is over the limit", piece)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package telegram
+
+import (
+	"html"
+	"net/url"
+	"strings"
+
+	"github.com/evdnx/gonotify/messenger"
+	"github.com/evdnx/gonotify/types"
+)
+
+// markdownV2Escaper escapes every character MarkdownV2 reserves outside of
+// code entities
+var markdownV2Escaper = strings.NewReplacer(
+	"\\", "\\\\", "_", "\\_", "*", "\\*", "[", "\\[", "]", "\\]", "(", "\\(", ")", "\\)",
+	"~", "\\~", "`", "\\`", ">", "\\>", "#", "\\#", "+", "\\+", "-", "\\-", "=", "\\=",
+	"|", "\\|", "{", "\\{", "}", "\\}", ".", "\\.", "!", "\\!",
+)
+
+// markdownV2CodeEscaper escapes the characters reserved inside code entities
+var markdownV2CodeEscaper = strings.NewReplacer("\\", "\\\\", "`", "\\`")
+
+// markdownV2URLEscaper escapes the characters reserved inside link URLs
+var markdownV2URLEscaper = strings.NewReplacer("\\", "\\\\", ")", "\\)")
+
+// EscapeMarkdownV2 escapes text for use in a MarkdownV2 message
+func EscapeMarkdownV2(text string) string {
+	return markdownV2Escaper.Replace(text)
+}
+
+// EscapeHTML escapes text for use in an HTML message
+func EscapeHTML(text string) string {
+	return html.EscapeString(text)
+}
+
+// block is a line of a message: text rendered with a style. Blocks too long
+// for one message are cut into pieces rendered with the same style, so every
+// part of a split message has balanced formatting entities.
+type block struct {
+	text  string
+	style func(string) string
+}
+
+// render returns the block as it appears in the message
+func (b block) render() string {
+	return b.renderText(b.text)
+}
+
+// renderText renders text with the block's style
+func (b block) renderText(text string) string {
+	if b.style == nil {
+		return text
+	}
+	return b.style(text)
+}
+
+// plainBlocks returns the lines of text as unstyled blocks
+func plainBlocks(text string) []block {
+	var blocks []block
+	for _, line := range strings.Split(text, "\n") {
+		blocks = append(blocks, block{text: line})
+	}
+	return blocks
+}
+
+// render formats the notification for the configured parse mode: a bold
+// title, the body, the fields with monospace values, a link to the order
+// page and the timestamp. Without a parse mode it is the flattened text.
+func (c *Client) render(notification messenger.Notification) []block {
+	var bold, italic, code func(string) string
+	var escape func(string) string
+	var link func(text, url string) string
+	switch c.options.ParseMode {
+	case ParseModeMarkdownV2:
+		escape = EscapeMarkdownV2
+		bold = func(s string) string { return "*" + EscapeMarkdownV2(s) + "*" }
+		italic = func(s string) string { return "_" + EscapeMarkdownV2(s) + "_" }
+		code = func(s string) string { return "`" + markdownV2CodeEscaper.Replace(s) + "`" }
+		link = func(text, url string) string {
+			return "[" + EscapeMarkdownV2(text) + "](" + markdownV2URLEscaper.Replace(url) + ")"
+		}
+	case ParseModeHTML:
+		escape = EscapeHTML
+		bold = func(s string) string { return "<b>" + EscapeHTML(s) + "</b>" }
+		italic = func(s string) string { return "<i>" + EscapeHTML(s) + "</i>" }
+		code = func(s string) string { return "<code>" + EscapeHTML(s) + "</code>" }
+		link = func(text, url string) string {
+			return `<a href="` + EscapeHTML(url) + `">` + EscapeHTML(text) + "</a>"
+		}
+	default:
+		return plainBlocks(notification.Text())
+	}
+
+	var blocks []block
+	if notification.Title != "" {
+		blocks = append(blocks, block{notification.Title, bold})
+	}
+	if notification.Body != "" {
+		for _, line := range strings.Split(notification.Body, "\n") {
+			blocks = append(blocks, block{line, escape})
+		}
+	}
+	if len(notification.Fields) > 0 {
+		blocks = append(blocks, block{})
+		for _, field := range notification.Fields {
+			label := bold(field.Name+":") + " "
+			value := code
+			if field.Name == "Symbol" {
+				value = bold
+			}
+			blocks = append(blocks, block{field.Value, func(s string) string { return label + value(s) }})
+		}
+	}
+	if orderURL := c.orderURL(notification); orderURL != "" {
+		blocks = append(blocks, block{"View order", func(s string) string { return link(s, orderURL) }})
+	}
+	if timeText := notification.TimeText(); timeText != "" {
+		blocks = append(blocks, block{}, block{timeText, italic})
+	}
+	return blocks
+}
+
+// orderURL returns the exchange page of the notification's order, or an
+// empty string when no order URL is configured or the event has no order
+func (c *Client) orderURL(notification messenger.Notification) string {
+	if c.options.OrderURL == "" {
+		return ""
+	}
+
+	var order types.Order
+	switch data := notification.Event.Data.(type) {
+	case types.Order:
+		order = data
+	case *types.Order:
+		order = *data
+	default:
+		return ""
+	}
+	if order.ID == "" {
+		return ""
+	}
+
+	replacer := strings.NewReplacer("{symbol}", url.QueryEscape(order.Symbol), "{id}", url.QueryEscape(order.ID))
+	return replacer.Replace(c.options.OrderURL)
+}
@@ -0,0 +1,86 @@
+package telegram
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/evdnx/gonotify/messenger"
+)
+
+// Telegram's limits on the length of texts and captions, in UTF-16 code units
+const (
+	MaxMessageLength = 4096
+	MaxCaptionLength = 1024
+)
+
+// partNumberReserve is the room kept for the "1/3" line of split messages
+const partNumberReserve = 16
+
+// split renders blocks into numbered parts, the first at most firstLimit
+// long and the others at most limit. Blocks are kept whole where they fit,
+// so parts break at line boundaries.
+func split(blocks []block, firstLimit, limit int) []string {
+	firstLimit -= partNumberReserve
+	limit -= partNumberReserve
+
+	// Cut blocks that don't fit in a part of their own, giving every piece
+	// the block's style
+	var pieces []block
+	for _, b := range blocks {
+		maxLength := min(firstLimit, limit) - 1
+		if length(b.render()) <= maxLength {
+			pieces = append(pieces, b)
+			continue
+		}
+		overhead := length(b.renderText(""))
+		size := func(s string) int { return length(b.renderText(s)) - overhead }
+		for _, text := range messenger.SplitText(b.text, maxLength-overhead, size) {
+			pieces = append(pieces, block{text, b.style})
+		}
+	}
+
+	size := func(b block) int { return length(b.render()) + 1 }
+	parts := messenger.Pack(pieces, firstLimit, size)
+	if len(parts) > 1 {
+		var rest []block
+		for _, part := range parts[1:] {
+			rest = append(rest, part...)
+		}
+		parts = append(parts[:1], messenger.Pack(rest, limit, size)...)
+	}
+
+	texts := make([]string, 0, len(parts))
+	for i, part := range parts {
+		var lines []string
+		if len(parts) > 1 {
+			lines = append(lines, fmt.Sprintf("%d/%d", i+1, len(parts)))
+		}
+		for _, b := range trimBlank(part) {
+			lines = append(lines, b.render())
+		}
+		texts = append(texts, strings.Join(lines, "\n"))
+	}
+	return texts
+}
+
+// trimBlank drops the blank lines at the start and end of a part
+func trimBlank(blocks []block) []block {
+	for len(blocks) > 0 && blocks[0].render() == "" {
+		blocks = blocks[1:]
+	}
+	for len(blocks) > 0 && blocks[len(blocks)-1].render() == "" {
+		blocks = blocks[:len(blocks)-1]
+	}
+	return blocks
+}
+
+// length returns the length of text as Telegram counts it, in UTF-16 code
+// units
+func length(text string) int {
+	n := 0
+	for _, r := range text {
+		n += utf16.RuneLen(r)
+	}
+	return n
+}
@@ -2,6 +2,7 @@ package telegram
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -10,46 +11,139 @@ import (
 	"os"
 	"path/filepath"
 	"time"
+
+	"github.com/evdnx/gonotify/messenger"
+)
+
+// defaultTimeout bounds SendMessage, SendFile and SendFileWithCaption, which
+// take no context
+const defaultTimeout = 10 * time.Second
+
+// Parse modes Telegram formats message text with
+const (
+	ParseModeMarkdownV2 = "MarkdownV2"
+	ParseModeHTML       = "HTML"
 )
 
 // Client is a client for sending messages to Telegram
 type Client struct {
 	botToken   string
 	chatID     string
+	options    Options
 	httpClient *http.Client
 	apiURL     string
+	progress   messenger.Progress // parts of split messages already sent
+}
+
+// Options control how notifications are formatted and delivered
+type Options struct {
+	// ParseMode is ParseModeMarkdownV2 or ParseModeHTML to send notifications
+	// with bold titles, monospace values and links; empty sends plain text
+	ParseMode string
+
+	// DisableWebPagePreview turns off link previews
+	DisableWebPagePreview bool
+
+	// DisableNotification delivers messages silently
+	DisableNotification bool
+
+	// OrderURL links order notifications to the exchange's order page. The
+	// placeholders {symbol} and {id} are replaced with the order's symbol
+	// and ID.
+	OrderURL string
 }
 
 // Message represents a message to be sent to Telegram
 type Message struct {
-	ChatID string `json:"chat_id"`
-	Text   string `json:"text"`
+	ChatID                string `json:"chat_id"`
+	Text                  string `json:"text"`
+	ParseMode             string `json:"parse_mode,omitempty"`
+	DisableWebPagePreview bool   `json:"disable_web_page_preview,omitempty"`
+	DisableNotification   bool   `json:"disable_notification,omitempty"`
 }
 
 // Response represents the response from Telegram API
 type Response struct {
-	OK          bool   `json:"ok"`
-	Description string `json:"description,omitempty"`
+	OK          bool                `json:"ok"`
+	Description string              `json:"description,omitempty"`
+	ErrorCode   int                 `json:"error_code,omitempty"`
+	Parameters  *ResponseParameters `json:"parameters,omitempty"`
+}
+
+// ResponseParameters describes why a request failed and how to recover
+type ResponseParameters struct {
+	RetryAfter      int   `json:"retry_after,omitempty"` // seconds to wait after a 429
+	MigrateToChatID int64 `json:"migrate_to_chat_id,omitempty"`
 }
 
-// NewClient creates a new Telegram client
+// NewClient creates a new Telegram client that sends plain text
 func NewClient(botToken, chatID string) *Client {
+	return NewClientWithOptions(botToken, chatID, Options{})
+}
+
+// NewClientWithOptions creates a new Telegram client with formatting and
+// delivery options
+func NewClientWithOptions(botToken, chatID string, options Options) *Client {
 	return &Client{
-		botToken: botToken,
-		chatID:   chatID,
-		httpClient: &http.Client{
-			Timeout: 10 * time.Second,
-		},
-		apiURL: "https://api.telegram.org",
+		botToken:   botToken,
+		chatID:     chatID,
+		options:    options,
+		httpClient: &http.Client{},
+		apiURL:     "https://api.telegram.org",
 	}
 }
 
 // SendMessage sends a message to the Telegram chat
 func (c *Client) SendMessage(message string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+	return c.SendMessageContext(ctx, message)
+}
+
+// SendMessageContext sends a message to the Telegram chat as plain text,
+// aborting when ctx is done. Messages over MaxMessageLength are split into
+// numbered parts.
+func (c *Client) SendMessageContext(ctx context.Context, message string) error {
+	return c.sendParts(ctx, split(plainBlocks(message), MaxMessageLength, MaxMessageLength), "")
+}
+
+// SendNotification sends the notification formatted with the configured
+// parse mode. Values from the notification are escaped, so error messages
+// and symbols cannot break the formatting. Long notifications, such as
+// errors with stack traces, are split into numbered parts at line
+// boundaries.
+func (c *Client) SendNotification(ctx context.Context, notification messenger.Notification) error {
+	return c.sendParts(ctx, split(c.render(notification), MaxMessageLength, MaxMessageLength), c.options.ParseMode)
+}
+
+// sendParts sends the parts of a message in order, stopping at the first
+// that fails. A retry of the message resumes at the failed part, so the
+// chat doesn't get the earlier parts twice. The bot token is redacted from
+// errors, since it is part of every request URL.
+func (c *Client) sendParts(ctx context.Context, parts []string, parseMode string) error {
+	id, sent := c.progress.Resume(parts)
+	for i := sent; i < len(parts); i++ {
+		if err := c.send(ctx, parts[i], parseMode); err != nil {
+			if len(parts) > 1 {
+				err = fmt.Errorf("part %d/%d: %w", i+1, len(parts), err)
+			}
+			return messenger.Redact(err, c.botToken)
+		}
+		c.progress.Advance(id, i+1)
+	}
+	c.progress.Finish(id)
+	return nil
+}
+
+// send delivers text to the chat through sendMessage
+func (c *Client) send(ctx context.Context, text, parseMode string) error {
 	// Create the message payload
 	payload := Message{
-		ChatID: c.chatID,
-		Text:   message,
+		ChatID:                c.chatID,
+		Text:                  text,
+		ParseMode:             parseMode,
+		DisableWebPagePreview: c.options.DisableWebPagePreview,
+		DisableNotification:   c.options.DisableNotification,
 	}
 
 	// Convert payload to JSON
@@ -62,7 +156,7 @@ func (c *Client) SendMessage(message string) error {
 	url := fmt.Sprintf("%s/bot%s/sendMessage", c.apiURL, c.botToken)
 
 	// Create the request
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonPayload))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonPayload))
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
@@ -75,22 +169,89 @@ func (c *Client) SendMessage(message string) error {
 	}
 	defer resp.Body.Close()
 
-	// Parse response
+	// Check the status before parsing, since proxies in front of the API
+	// may answer with an HTML error page
 	var tgResponse Response
+	if resp.StatusCode != http.StatusOK {
+		json.NewDecoder(resp.Body).Decode(&tgResponse)
+		return responseError(resp.StatusCode, tgResponse, fmt.Errorf("failed to send message: %s (status: %d)", describe(tgResponse.Description, resp.StatusCode), resp.StatusCode))
+	}
+
+	// Parse response
 	if err := json.NewDecoder(resp.Body).Decode(&tgResponse); err != nil {
 		return fmt.Errorf("failed to decode response: %w", err)
 	}
-
-	// Check the response
-	if resp.StatusCode != http.StatusOK || !tgResponse.OK {
-		return fmt.Errorf("failed to send message: %s (status: %d)", tgResponse.Description, resp.StatusCode)
+	if !tgResponse.OK {
+		return responseError(resp.StatusCode, tgResponse, fmt.Errorf("failed to send message: %s (status: %d)", tgResponse.Description, resp.StatusCode))
 	}
 
 	return nil
 }
 
+// describe returns the API's description of a failure, or the status text
+// when the response carried none
+func describe(description string, statusCode int) string {
+	if description == "" {
+		return http.StatusText(statusCode)
+	}
+	return description
+}
+
+// responseError wraps a failed API call as an HTTP error, or as a rate-limit
+// error when Telegram asked to retry later
+func responseError(statusCode int, response Response, err error) error {
+	httpErr := &messenger.HTTPError{StatusCode: statusCode, Err: err}
+	if response.Parameters != nil && response.Parameters.RetryAfter > 0 {
+		return &messenger.RateLimitError{
+			RetryAfter: time.Duration(response.Parameters.RetryAfter) * time.Second,
+			Err:        httpErr,
+		}
+	}
+	return httpErr
+}
+
 // SendFile sends a file to the Telegram chat using sendDocument API
 func (c *Client) SendFile(filePath string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+	return c.SendFileContext(ctx, filePath)
+}
+
+// SendFileContext sends a file to the Telegram chat using sendDocument API,
+// aborting when ctx is done
+func (c *Client) SendFileContext(ctx context.Context, filePath string) error {
+	return c.SendFileWithCaptionContext(ctx, filePath, "")
+}
+
+// SendFileWithCaption sends a file with a plain text caption to the
+// Telegram chat
+func (c *Client) SendFileWithCaption(filePath, caption string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+	return c.SendFileWithCaptionContext(ctx, filePath, caption)
+}
+
+// SendFileWithCaptionContext sends a file with a plain text caption to the
+// Telegram chat, aborting when ctx is done. Captions over MaxCaptionLength
+// are split into numbered parts; the first is the caption and the rest
+// follow as messages.
+func (c *Client) SendFileWithCaptionContext(ctx context.Context, filePath, caption string) error {
+	var parts []string
+	if caption != "" {
+		parts = split(plainBlocks(caption), MaxCaptionLength, MaxMessageLength)
+	}
+	if err := c.sendDocument(ctx, filePath, parts); err != nil {
+		return messenger.Redact(err, c.botToken)
+	}
+	if len(parts) > 1 {
+		return c.sendParts(ctx, parts[1:], "")
+	}
+	return nil
+}
+
+// sendDocument uploads a file through sendDocument, captioned with the first
+// of the caption parts if there are any
+func (c *Client) sendDocument(ctx context.Context, filePath string, captionParts []string) error {
 	// Open the file
 	file, err := os.Open(filePath)
 	if err != nil {
@@ -108,6 +269,20 @@ func (c *Client) SendFile(filePath string) error {
 		return fmt.Errorf("failed to write chat_id field: %w", err)
 	}
 
+	// Deliver silently if configured
+	if c.options.DisableNotification {
+		if err := writer.WriteField("disable_notification", "true"); err != nil {
+			return fmt.Errorf("failed to write disable_notification field: %w", err)
+		}
+	}
+
+	// Add caption field
+	if len(captionParts) > 0 {
+		if err := writer.WriteField("caption", captionParts[0]); err != nil {
+			return fmt.Errorf("failed to write caption field: %w", err)
+		}
+	}
+
 	// Add document field
 	filename := filepath.Base(filePath)
 	part, err := writer.CreateFormFile("document", filename)
@@ -129,7 +304,7 @@ func (c *Client) SendFile(filePath string) error {
 
 	// Create the HTTP request
 	url := fmt.Sprintf("%s/bot%s/sendDocument", c.apiURL, c.botToken)
-	req, err := http.NewRequest("POST", url, &requestBody)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, &requestBody)
 	if err != nil {
 		return fmt.Errorf("failed to create HTTP request: %w", err)
 	}
@@ -147,7 +322,9 @@ func (c *Client) SendFile(filePath string) error {
 	// Check response
 	if resp.StatusCode != http.StatusOK {
 		responseBody, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("telegram API error: status %d, body: %s", resp.StatusCode, string(responseBody))
+		var tgResponse Response
+		json.Unmarshal(responseBody, &tgResponse)
+		return responseError(resp.StatusCode, tgResponse, fmt.Errorf("telegram API error: status %d, body: %s", resp.StatusCode, string(responseBody)))
 	}
 
 	return nil
@@ -0,0 +1,326 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/evdnx/gonotify/eventbus"
+	"github.com/evdnx/gonotify/messenger"
+	"github.com/evdnx/gonotify/types"
+)
+
+func TestSendMessageRateLimited(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 7","parameters":{"retry_after":7}}`))
+	}))
+	defer server.Close()
+
+	client := NewClient("token", "chat")
+	client.apiURL = server.URL
+
+	err := client.SendMessage("hello")
+	retryAfter, ok := messenger.RetryAfter(err)
+	if !ok || retryAfter != 7*time.Second {
+		t.Fatalf("expected a 7s rate-limit error, got %v", err)
+	}
+
+	var httpErr *messenger.HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected wrapped HTTP error, got %v", err)
+	}
+}
+
+func TestSendMessageClientError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"ok":false,"error_code":400,"description":"Bad Request: chat not found"}`))
+	}))
+	defer server.Close()
+
+	client := NewClient("token", "chat")
+	client.apiURL = server.URL
+
+	err := client.SendMessage("hello")
+	if err == nil || messenger.Retryable(err) {
+		t.Fatalf("expected a permanent error, got %v", err)
+	}
+	if _, ok := messenger.RetryAfter(err); ok {
+		t.Fatalf("unexpected rate-limit error: %v", err)
+	}
+}
+
+func TestSendMessageGatewayError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+		w.Write([]byte("<html><body>502 Bad Gateway</body></html>"))
+	}))
+	defer server.Close()
+
+	client := NewClient("token", "chat")
+	client.apiURL = server.URL
+
+	err := client.SendMessage("hello")
+	var httpErr *messenger.HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusBadGateway {
+		t.Fatalf("expected HTTP error with status 502, got %v", err)
+	}
+	if !messenger.Retryable(err) {
+		t.Errorf("expected a retryable error, got %v", err)
+	}
+}
+
+func TestSendNotificationFormatting(t *testing.T) {
+	notification := messenger.Notification{
+		Title: "Order Filled",
+		Body:  "sell_all (test) done!",
+		Fields: []messenger.Field{
+			{Name: "Symbol", Value: "BTC_USDT"},
+			{Name: "Price", Value: "68,000.5"},
+		},
+		Event: eventbus.Event{Type: eventbus.EventOrderFilled, Data: types.Order{ID: "42", Symbol: "BTC_USDT"}},
+	}
+
+	tests := []struct {
+		parseMode string
+		want      string
+	}{
+		{ParseModeMarkdownV2, "*Order Filled*\nsell\\_all \\(test\\) done\\!\n\n*Symbol:* *BTC\\_USDT*\n*Price:* `68,000.5`\n[View order](https://example.com/trade?s=BTC_USDT&id=42)"},
+		{ParseModeHTML, "<b>Order Filled</b>\nsell_all (test) done!\n\n<b>Symbol:</b> <b>BTC_USDT</b>\n<b>Price:</b> <code>68,000.5</code>\n<a href=\"https://example.com/trade?s=BTC_USDT&amp;id=42\">View order</a>"},
+	}
+	for _, tt := range tests {
+		var message Message
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			json.NewDecoder(r.Body).Decode(&message)
+			w.Write([]byte(`{"ok":true}`))
+		}))
+
+		client := NewClientWithOptions("token", "chat", Options{
+			ParseMode:             tt.parseMode,
+			DisableWebPagePreview: true,
+			DisableNotification:   true,
+			OrderURL:              "https://example.com/trade?s={symbol}&id={id}",
+		})
+		client.apiURL = server.URL
+
+		if err := client.SendNotification(context.Background(), notification); err != nil {
+			t.Fatalf("%s: failed to send: %v", tt.parseMode, err)
+		}
+		server.Close()
+
+		if message.Text != tt.want {
+			t.Errorf("%s: unexpected text:\n%s", tt.parseMode, message.Text)
+		}
+		if message.ParseMode != tt.parseMode || !message.DisableWebPagePreview || !message.DisableNotification {
+			t.Errorf("%s: unexpected options %+v", tt.parseMode, message)
+		}
+	}
+}
+
+func TestSendNotificationPlainText(t *testing.T) {
+	var message Message
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&message)
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+
+	client := NewClient("token", "chat")
+	client.apiURL = server.URL
+
+	notification := messenger.Notification{Title: "Error", Body: "*not bold*"}
+	if err := client.SendNotification(context.Background(), notification); err != nil {
+		t.Fatalf("failed to send: %v", err)
+	}
+	if message.Text != notification.Text() || message.ParseMode != "" {
+		t.Errorf("expected plain text, got %+v", message)
+	}
+}
+
+func TestSendNotificationSplitsLongMessages(t *testing.T) {
+	var messages []Message
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var message Message
+		json.NewDecoder(r.Body).Decode(&message)
+		messages = append(messages, message)
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+
+	client := NewClientWithOptions("token", "chat", Options{ParseMode: ParseModeMarkdownV2})
+	client.apiURL = server.URL
+
+	var trace []string
+	for i := 0; i < 200; i++ {
+		trace = append(trace, fmt.Sprintf("main.(*Strategy).run(0x%x) strategy.go:%d +0x1f", i, i))
+	}
+	notification := messenger.Notification{
+		Title:  "Strategy Error",
+		Body:   strings.Join(trace, "\n"),
+		Fields: []messenger.Field{{Name: "Strategy", Value: "grid_v2"}},
+	}
+	if err := client.SendNotification(context.Background(), notification); err != nil {
+		t.Fatalf("failed to send: %v", err)
+	}
+
+	if len(messages) < 2 {
+		t.Fatalf("expected the message to be split, got %d part(s)", len(messages))
+	}
+	var lines []string
+	for i, message := range messages {
+		if length(message.Text) > MaxMessageLength {
+			t.Errorf("part %d is %d characters long", i+1, length(message.Text))
+		}
+		number, rest, _ := strings.Cut(message.Text, "\n")
+		if want := fmt.Sprintf("%d/%d", i+1, len(messages)); number != want {
+			t.Errorf("expected part number %q, got %q", want, number)
+		}
+		lines = append(lines, strings.Split(rest, "\n")...)
+	}
+	if lines[0] != "*Strategy Error*" || lines[len(lines)-1] != "*Strategy:* `grid_v2`" {
+		t.Errorf("unexpected first or last line: %q, %q", lines[0], lines[len(lines)-1])
+	}
+	if want := EscapeMarkdownV2(trace[199]); lines[len(lines)-3] != want {
+		t.Errorf("expected the whole trace, got %q", lines[len(lines)-3])
+	}
+}
+
+func TestSendMessageRetryResumesAtFailedPart(t *testing.T) {
+	var numbers []string
+	failed := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var message Message
+		json.NewDecoder(r.Body).Decode(&message)
+		number, _, _ := strings.Cut(message.Text, "\n")
+		if number == "2/3" && !failed {
+			failed = true
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 1","parameters":{"retry_after":1}}`))
+			return
+		}
+		numbers = append(numbers, number)
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+
+	client := NewClient("token", "chat")
+	client.apiURL = server.URL
+	message := strings.Repeat(strings.Repeat("x", 1000)+"\n", 10)
+
+	if err := client.SendMessage(message); err == nil || !messenger.Retryable(err) {
+		t.Fatalf("expected a retryable error, got %v", err)
+	}
+	if err := client.SendMessage(message); err != nil {
+		t.Fatalf("failed to send: %v", err)
+	}
+	if strings.Join(numbers, ",") != "1/3,2/3,3/3" {
+		t.Fatalf("expected every part once, got %v", numbers)
+	}
+
+	// Sending the message again starts over
+	numbers = nil
+	if err := client.SendMessage(message); err != nil {
+		t.Fatalf("failed to send: %v", err)
+	}
+	if len(numbers) != 3 {
+		t.Errorf("expected all parts again, got %v", numbers)
+	}
+}
+
+func TestSendMessageSplitsLongLines(t *testing.T) {
+	var texts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var message Message
+		json.NewDecoder(r.Body).Decode(&message)
+		texts = append(texts, message.Text)
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+
+	client := NewClient("token", "chat")
+	client.apiURL = server.URL
+
+	if err := client.SendMessage(strings.Repeat("x", 10000)); err != nil {
+		t.Fatalf("failed to send: %v", err)
+	}
+	if len(texts) != 3 || !strings.HasPrefix(texts[2], "3/3\n") {
+		t.Fatalf("expected 3 numbered parts, got %d", len(texts))
+	}
+	total := 0
+	for _, text := range texts {
+		_, rest, _ := strings.Cut(text, "\n")
+		total += len(rest)
+	}
+	if total != 10000 {
+		t.Errorf("expected all 10000 characters to be delivered, got %d", total)
+	}
+}
+
+func TestSendFileWithCaptionSplitsLongCaptions(t *testing.T) {
+	var caption string
+	var texts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/sendDocument") {
+			caption = r.FormValue("caption")
+		} else {
+			var message Message
+			json.NewDecoder(r.Body).Decode(&message)
+			texts = append(texts, message.Text)
+		}
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+
+	client := NewClient("token", "chat")
+	client.apiURL = server.URL
+
+	path := filepath.Join(t.TempDir(), "report.csv")
+	if err := os.WriteFile(path, []byte("symbol,pnl\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.SendFileWithCaption(path, strings.Repeat("line of the report\n", 100)); err != nil {
+		t.Fatalf("failed to send: %v", err)
+	}
+
+	if length(caption) > MaxCaptionLength || !strings.HasPrefix(caption, "1/2\n") {
+		t.Errorf("unexpected caption of %d characters: %.10q", length(caption), caption)
+	}
+	if len(texts) != 1 || !strings.HasPrefix(texts[0], "2/2\n") {
+		t.Errorf("expected the rest of the caption in one message, got %d", len(texts))
+	}
+}
+
+func TestErrorsRedactBotToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Close()
+
+	client := NewClient("123456:secret-token", "chat")
+	client.apiURL = server.URL
+
+	err := client.SendMessage("hello")
+	if err == nil || strings.Contains(err.Error(), "secret-token") {
+		t.Fatalf("expected an error without the token, got %v", err)
+	}
+	if !messenger.Retryable(err) {
+		t.Errorf("expected a retryable network error, got %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "report.csv")
+	if err := os.WriteFile(path, []byte("symbol,pnl\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	err = client.SendFile(path)
+	if err == nil || strings.Contains(err.Error(), "secret-token") {
+		t.Fatalf("expected an error without the token, got %v", err)
+	}
+}
@@ -0,0 +1,169 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/evdnx/gonotify/eventbus"
+	"github.com/evdnx/gonotify/messenger"
+)
+
+// DefaultSignatureHeader is the header carrying the HMAC-SHA256 signature of
+// the request body when no other header name is configured.
+const DefaultSignatureHeader = "X-GoNotify-Signature"
+
+// Config contains the settings for a webhook client
+type Config struct {
+	URL                string
+	Method             string // defaults to POST
+	Secret             string // signing key; requests are unsigned when empty
+	SignatureHeader    string // defaults to DefaultSignatureHeader
+	Headers            map[string]string
+	Timeout            time.Duration // defaults to 10 seconds
+	SuccessStatusCodes []int         // defaults to any 2xx status
+}
+
+// Client is a client for posting notifications to an arbitrary HTTP endpoint
+type Client struct {
+	config     Config
+	httpClient *http.Client
+}
+
+// Envelope is the JSON document delivered to the webhook endpoint
+type Envelope struct {
+	EventType eventbus.EventType `json:"event_type,omitempty"`
+	Timestamp time.Time          `json:"timestamp"`
+	Title     string             `json:"title,omitempty"`
+	Body      string             `json:"body,omitempty"`
+	Severity  messenger.Severity `json:"severity,omitempty"`
+	Fields    []messenger.Field  `json:"fields,omitempty"`
+	Tags      []string           `json:"tags,omitempty"`
+	Data      interface{}        `json:"data,omitempty"`
+	Text      string             `json:"text"`
+}
+
+// NewClient creates a new webhook client
+func NewClient(cfg Config) *Client {
+	if cfg.Method == "" {
+		cfg.Method = http.MethodPost
+	}
+	if cfg.SignatureHeader == "" {
+		cfg.SignatureHeader = DefaultSignatureHeader
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	return &Client{
+		config:     cfg,
+		httpClient: &http.Client{},
+	}
+}
+
+// SendMessage posts an envelope carrying only the rendered text
+func (c *Client) SendMessage(message string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.config.Timeout)
+	defer cancel()
+	return c.send(ctx, Envelope{
+		Timestamp: time.Now().UTC(),
+		Text:      message,
+	})
+}
+
+// SendNotification posts an envelope carrying the structured notification,
+// the typed payload of its event and the rendered text
+func (c *Client) SendNotification(ctx context.Context, notification messenger.Notification) error {
+	ctx, cancel := context.WithTimeout(ctx, c.config.Timeout)
+	defer cancel()
+
+	timestamp := notification.Event.Timestamp
+	if timestamp.IsZero() {
+		timestamp = notification.Timestamp
+	}
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	return c.send(ctx, Envelope{
+		EventType: notification.EventType,
+		Timestamp: timestamp.UTC(),
+		Title:     notification.Title,
+		Body:      notification.Body,
+		Severity:  notification.Severity,
+		Fields:    notification.Fields,
+		Tags:      notification.Tags,
+		Data:      notification.Event.Data,
+		Text:      notification.Text(),
+	})
+}
+
+// send signs and delivers the envelope
+func (c *Client) send(ctx context.Context, envelope Envelope) error {
+	// Convert payload to JSON
+	jsonPayload, err := json.Marshal(envelope)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook payload: %w", err)
+	}
+
+	// Create the request
+	req, err := http.NewRequestWithContext(ctx, c.config.Method, c.config.URL, bytes.NewBuffer(jsonPayload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range c.config.Headers {
+		req.Header.Set(key, value)
+	}
+	if c.config.Secret != "" {
+		req.Header.Set(c.config.SignatureHeader, Sign(c.config.Secret, jsonPayload))
+	}
+
+	// Send the request
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Check the response
+	if !c.isSuccess(resp.StatusCode) {
+		responseBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &messenger.HTTPError{StatusCode: resp.StatusCode, Err: fmt.Errorf("webhook error: status %d, body: %s", resp.StatusCode, strings.TrimSpace(string(responseBody)))}
+	}
+
+	return nil
+}
+
+// isSuccess reports whether the status code counts as a successful delivery
+func (c *Client) isSuccess(statusCode int) bool {
+	if len(c.config.SuccessStatusCodes) == 0 {
+		return statusCode >= 200 && statusCode < 300
+	}
+	for _, code := range c.config.SuccessStatusCodes {
+		if code == statusCode {
+			return true
+		}
+	}
+	return false
+}
+
+// Sign returns the signature header value for a request body, formatted as
+// "sha256=<hex HMAC-SHA256 of body>". Receivers can recompute it with the
+// shared secret and compare using hmac.Equal.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Name returns the name of the messenger
+func (c *Client) Name() string {
+	return "Webhook"
+}
@@ -0,0 +1,89 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/evdnx/gonotify/eventbus"
+	"github.com/evdnx/gonotify/messenger"
+	"github.com/evdnx/gonotify/types"
+)
+
+func TestSendNotificationSignedEnvelope(t *testing.T) {
+	var body []byte
+	var header http.Header
+	var method string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		header = r.Header
+		method = r.Method
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	client := NewClient(Config{
+		URL:     server.URL,
+		Method:  http.MethodPut,
+		Secret:  "s3cret",
+		Headers: map[string]string{"X-Source": "gonotify"},
+	})
+
+	notification := messenger.Notification{
+		Title:     "💰 Trade Executed",
+		Body:      "buy BTCUSDT",
+		Severity:  messenger.SeverityInfo,
+		EventType: eventbus.EventTradeExecuted,
+		Event: eventbus.Event{
+			Type: eventbus.EventTradeExecuted,
+			Data: types.Trade{ID: "trade-1", Symbol: "BTCUSDT", Price: 68000},
+		},
+	}
+	if err := client.SendNotification(context.Background(), notification); err != nil {
+		t.Fatalf("SendNotification failed: %v", err)
+	}
+
+	if method != http.MethodPut {
+		t.Fatalf("expected PUT, got %s", method)
+	}
+	if header.Get("X-Source") != "gonotify" {
+		t.Fatalf("missing extra header: %v", header)
+	}
+	if !hmac.Equal([]byte(header.Get(DefaultSignatureHeader)), []byte(Sign("s3cret", body))) {
+		t.Fatalf("signature mismatch: %q", header.Get(DefaultSignatureHeader))
+	}
+
+	var envelope struct {
+		EventType string      `json:"event_type"`
+		Title     string      `json:"title"`
+		Severity  string      `json:"severity"`
+		Data      types.Trade `json:"data"`
+		Text      string      `json:"text"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		t.Fatalf("failed to decode envelope: %v", err)
+	}
+	if envelope.EventType != string(eventbus.EventTradeExecuted) || envelope.Data.ID != "trade-1" ||
+		envelope.Title != "💰 Trade Executed" || envelope.Severity != "info" || envelope.Text != "💰 Trade Executed: buy BTCUSDT" {
+		t.Fatalf("unexpected envelope: %+v", envelope)
+	}
+}
+
+func TestSuccessStatusCodes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("queued"))
+	}))
+	defer server.Close()
+
+	client := NewClient(Config{URL: server.URL, SuccessStatusCodes: []int{http.StatusOK}})
+	err := client.SendMessage("hello")
+	if err == nil || !strings.Contains(err.Error(), "status 202") {
+		t.Fatalf("expected status error, got %v", err)
+	}
+}
@@ -0,0 +1,322 @@
+package outbox
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/evdnx/gonotify/messenger"
+)
+
+// Dead-letter record operations
+const (
+	opDeadLetter = "dead_letter" // a messenger gave up on a notification
+	opRemove     = "remove"      // the dead letter was redelivered or discarded
+)
+
+// DeadLetter is a notification a messenger failed to deliver
+type DeadLetter struct {
+	ID           string
+	Messenger    string
+	Error        string
+	Attempts     int
+	FailedAt     time.Time
+	Notification messenger.Notification
+}
+
+// DeadLetterStore keeps the notifications messengers gave up on until they
+// are redelivered. It is backed by an append-only file, or only held in
+// memory when opened without a path. Stores with a limit discard their
+// oldest dead letters to make room for new ones.
+type DeadLetterStore struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	limit   int // most dead letters kept, or 0 for no limit
+	letters map[string]*DeadLetter
+	order   []string // dead letter IDs in the order they were added
+}
+
+// deadLetterRecord is a single line of the dead-letter file
+type deadLetterRecord struct {
+	Op           string                  `json:"op"`
+	ID           string                  `json:"id"`
+	Messenger    string                  `json:"messenger,omitempty"`
+	Error        string                  `json:"error,omitempty"`
+	Attempts     int                     `json:"attempts,omitempty"`
+	FailedAt     time.Time               `json:"failed_at,omitempty"`
+	Notification *messenger.Notification `json:"notification,omitempty"`
+	Event        *event                  `json:"event,omitempty"`
+}
+
+// OpenDeadLetters opens the dead-letter store at path, creating it if
+// needed, and compacts it. With an empty path the store is kept in memory.
+// The store keeps every dead letter until it is removed.
+func OpenDeadLetters(path string) (*DeadLetterStore, error) {
+	return OpenDeadLettersWithLimit(path, 0)
+}
+
+// OpenDeadLettersWithLimit opens the dead-letter store like OpenDeadLetters,
+// keeping at most limit dead letters. The oldest are discarded first, and a
+// limit of zero or less keeps them all.
+func OpenDeadLettersWithLimit(path string, limit int) (*DeadLetterStore, error) {
+	d := &DeadLetterStore{
+		path:    path,
+		limit:   max(limit, 0),
+		letters: make(map[string]*DeadLetter),
+	}
+	if path == "" {
+		return d, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create dead-letter directory: %w", err)
+	}
+	err := readRecords(path, func(line []byte) error {
+		var rec deadLetterRecord
+		if json.Unmarshal(line, &rec) == nil {
+			d.apply(rec)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.evict(nil)
+	if err := d.compactLocked(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Add stores a dead letter and returns its ID. The write is synced to disk.
+func (d *DeadLetterStore) Add(letter DeadLetter) (string, error) {
+	letter.ID = newID()
+	rec, err := deadLetterAddRecord(letter)
+	if err != nil {
+		return "", err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.write(rec, true); err != nil {
+		return "", err
+	}
+	d.apply(rec)
+
+	// Make room by discarding the oldest dead letters
+	var writeErr error
+	d.evict(func(rec deadLetterRecord) {
+		if err := d.write(rec, false); err != nil && writeErr == nil {
+			writeErr = err
+		}
+	})
+	if writeErr != nil {
+		return letter.ID, writeErr
+	}
+	return letter.ID, d.maybeCompactLocked()
+}
+
+// Get returns the dead letter with the given ID
+func (d *DeadLetterStore) Get(id string) (DeadLetter, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	letter, ok := d.letters[id]
+	if !ok {
+		return DeadLetter{}, false
+	}
+	return *letter, true
+}
+
+// List returns every dead letter, oldest first
+func (d *DeadLetterStore) List() []DeadLetter {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	letters := make([]DeadLetter, 0, len(d.letters))
+	for _, id := range d.order {
+		if letter, ok := d.letters[id]; ok {
+			letters = append(letters, *letter)
+		}
+	}
+	return letters
+}
+
+// Remove deletes a dead letter. Removing an unknown ID is not an error.
+func (d *DeadLetterStore) Remove(id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.letters[id]; !ok {
+		return nil
+	}
+	rec := deadLetterRecord{Op: opRemove, ID: id}
+	if err := d.write(rec, true); err != nil {
+		return err
+	}
+	d.apply(rec)
+	return d.maybeCompactLocked()
+}
+
+// Close compacts and closes the dead-letter file
+func (d *DeadLetterStore) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.file == nil {
+		return nil
+	}
+	if err := d.compactLocked(); err != nil {
+		return err
+	}
+	err := d.file.Close()
+	d.file = nil
+	return err
+}
+
+// write appends a record to the file of a persistent store. The caller must
+// hold d.mu.
+func (d *DeadLetterStore) write(rec deadLetterRecord, sync bool) error {
+	if d.path == "" {
+		return nil
+	}
+	if d.file == nil {
+		return ErrClosed
+	}
+
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to marshal dead-letter record: %w", err)
+	}
+	if _, err := d.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write dead-letter record: %w", err)
+	}
+	if sync {
+		if err := d.file.Sync(); err != nil {
+			return fmt.Errorf("failed to sync dead letters: %w", err)
+		}
+	}
+	return nil
+}
+
+// apply updates the in-memory state with a record. The caller must hold
+// d.mu, except while the store is being opened.
+func (d *DeadLetterStore) apply(rec deadLetterRecord) {
+	switch rec.Op {
+	case opDeadLetter:
+		if rec.Notification == nil {
+			return
+		}
+		notification := *rec.Notification
+		if rec.Event != nil {
+			notification.Event = rec.Event.decode()
+		}
+		d.letters[rec.ID] = &DeadLetter{
+			ID:           rec.ID,
+			Messenger:    rec.Messenger,
+			Error:        rec.Error,
+			Attempts:     rec.Attempts,
+			FailedAt:     rec.FailedAt,
+			Notification: notification,
+		}
+		d.order = append(d.order, rec.ID)
+
+	case opRemove:
+		delete(d.letters, rec.ID)
+	}
+}
+
+// evict removes the oldest dead letters beyond the limit, passing each
+// removal record to persist when it is not nil. The caller must hold d.mu,
+// except while the store is being opened.
+func (d *DeadLetterStore) evict(persist func(deadLetterRecord)) {
+	for _, id := range d.order {
+		if d.limit == 0 || len(d.letters) <= d.limit {
+			return
+		}
+		if _, ok := d.letters[id]; !ok {
+			continue
+		}
+		rec := deadLetterRecord{Op: opRemove, ID: id}
+		if persist != nil {
+			persist(rec)
+		}
+		d.apply(rec)
+	}
+}
+
+// maybeCompactLocked compacts the store once most of its records are
+// removed dead letters. The caller must hold d.mu.
+func (d *DeadLetterStore) maybeCompactLocked() error {
+	if removed := len(d.order) - len(d.letters); removed >= compactThreshold && removed > len(d.letters) {
+		return d.compactLocked()
+	}
+	return nil
+}
+
+// compactLocked rewrites the file with only the remaining dead letters. The
+// caller must hold d.mu.
+func (d *DeadLetterStore) compactLocked() error {
+	var buf bytes.Buffer
+	order := make([]string, 0, len(d.letters))
+	for _, id := range d.order {
+		letter, ok := d.letters[id]
+		if !ok {
+			continue
+		}
+		order = append(order, id)
+
+		rec, err := deadLetterAddRecord(*letter)
+		if err != nil {
+			return err
+		}
+		line, err := json.Marshal(rec)
+		if err != nil {
+			return fmt.Errorf("failed to marshal dead-letter record: %w", err)
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	d.order = order
+
+	if d.path == "" {
+		return nil
+	}
+	if d.file != nil {
+		d.file.Close()
+		d.file = nil
+	}
+	file, err := rewrite(d.path, buf.Bytes())
+	if err != nil {
+		return err
+	}
+	d.file = file
+	return nil
+}
+
+// deadLetterAddRecord builds the record that persists a dead letter
+func deadLetterAddRecord(letter DeadLetter) (deadLetterRecord, error) {
+	event, err := encodeEvent(letter.Notification.Event)
+	if err != nil {
+		return deadLetterRecord{}, err
+	}
+	return deadLetterRecord{
+		Op:           opDeadLetter,
+		ID:           letter.ID,
+		Messenger:    letter.Messenger,
+		Error:        letter.Error,
+		Attempts:     letter.Attempts,
+		FailedAt:     letter.FailedAt,
+		Notification: &letter.Notification,
+		Event:        event,
+	}, nil
+}
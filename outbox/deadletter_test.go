@@ -0,0 +1,110 @@
+package outbox
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/evdnx/gonotify/types"
+)
+
+func TestDeadLettersSurviveReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dead_letters.jsonl")
+
+	store, err := OpenDeadLetters(path)
+	if err != nil {
+		t.Fatalf("failed to open dead letters: %v", err)
+	}
+	first, err := store.Add(DeadLetter{
+		Messenger:    "Telegram",
+		Error:        "failed to send message: Bad Request: chat not found (status: 400)",
+		Attempts:     1,
+		FailedAt:     time.Date(2024, 1, 1, 12, 0, 5, 0, time.UTC),
+		Notification: tradeNotification(),
+	})
+	if err != nil {
+		t.Fatalf("failed to add dead letter: %v", err)
+	}
+	second, err := store.Add(DeadLetter{Messenger: "Slack", Error: "timeout", Attempts: 3, Notification: tradeNotification()})
+	if err != nil {
+		t.Fatalf("failed to add dead letter: %v", err)
+	}
+	if err := store.Remove(second); err != nil {
+		t.Fatalf("failed to remove dead letter: %v", err)
+	}
+
+	// Simulate a crash: reopen without closing
+	reopened, err := OpenDeadLetters(path)
+	if err != nil {
+		t.Fatalf("failed to reopen dead letters: %v", err)
+	}
+	defer reopened.Close()
+
+	letters := reopened.List()
+	if len(letters) != 1 {
+		t.Fatalf("expected 1 dead letter, got %d", len(letters))
+	}
+	letter := letters[0]
+	if letter.ID != first || letter.Messenger != "Telegram" || letter.Attempts != 1 || letter.Error == "" {
+		t.Fatalf("unexpected dead letter: %+v", letter)
+	}
+	if _, ok := letter.Notification.Event.Data.(types.Trade); !ok {
+		t.Fatalf("expected typed trade payload, got %#v", letter.Notification.Event.Data)
+	}
+	if _, ok := reopened.Get(second); ok {
+		t.Fatal("removed dead letter came back")
+	}
+}
+
+func TestInMemoryDeadLetters(t *testing.T) {
+	store, err := OpenDeadLetters("")
+	if err != nil {
+		t.Fatalf("failed to open dead letters: %v", err)
+	}
+
+	id, err := store.Add(DeadLetter{Messenger: "Telegram", Error: "boom", Attempts: 3, Notification: tradeNotification()})
+	if err != nil {
+		t.Fatalf("failed to add dead letter: %v", err)
+	}
+	if letter, ok := store.Get(id); !ok || letter.Messenger != "Telegram" {
+		t.Fatalf("dead letter not found: %+v", letter)
+	}
+	if err := store.Remove(id); err != nil {
+		t.Fatalf("failed to remove dead letter: %v", err)
+	}
+	if letters := store.List(); len(letters) != 0 {
+		t.Fatalf("expected no dead letters, got %+v", letters)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("failed to close dead letters: %v", err)
+	}
+}
+
+func TestDeadLetterLimitDiscardsOldest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dead_letters.jsonl")
+	store, err := OpenDeadLettersWithLimit(path, 3)
+	if err != nil {
+		t.Fatalf("failed to open dead letters: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		if _, err := store.Add(DeadLetter{Messenger: "Telegram", Attempts: i, Notification: tradeNotification()}); err != nil {
+			t.Fatalf("failed to add dead letter: %v", err)
+		}
+	}
+	if letters := store.List(); len(letters) != 3 || letters[0].Attempts != 2 {
+		t.Fatalf("expected the 3 newest dead letters, got %+v", letters)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("failed to close dead letters: %v", err)
+	}
+
+	// A smaller limit applies to the letters already on disk
+	reopened, err := OpenDeadLettersWithLimit(path, 2)
+	if err != nil {
+		t.Fatalf("failed to reopen dead letters: %v", err)
+	}
+	defer reopened.Close()
+	if letters := reopened.List(); len(letters) != 2 || letters[0].Attempts != 3 {
+		t.Fatalf("expected the 2 newest dead letters, got %+v", letters)
+	}
+}
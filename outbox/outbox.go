@@ -0,0 +1,442 @@
+// Package outbox persists notifications to an append-only file so that
+// deliveries interrupted by a crash or shutdown can be replayed on restart,
+// and keeps the notifications messengers gave up on as dead letters.
+package outbox
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/evdnx/gonotify/eventbus"
+	"github.com/evdnx/gonotify/messenger"
+	"github.com/evdnx/gonotify/types"
+)
+
+// Record operations
+const (
+	opAdd       = "add"       // a notification was queued for delivery
+	opDelivered = "delivered" // a messenger delivered it
+	opFailed    = "failed"    // a messenger gave up on it
+)
+
+// compactThreshold is the number of resolved entries after which the file
+// is compacted while in use
+const compactThreshold = 1000
+
+// ErrClosed is returned when writing to a closed outbox
+var ErrClosed = errors.New("outbox is closed")
+
+// Entry is a notification with the messengers that have yet to deliver it
+type Entry struct {
+	ID           string
+	Notification messenger.Notification
+	Pending      []string
+}
+
+// Outbox is an append-only log of notifications and their delivery state.
+// Each line of the file is one JSON record.
+type Outbox struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	entries map[string]*Entry
+	order   []string // entry IDs in the order they were added
+}
+
+// record is a single line of the outbox file
+type record struct {
+	Op           string                  `json:"op"`
+	ID           string                  `json:"id"`
+	Messenger    string                  `json:"messenger,omitempty"`
+	Messengers   []string                `json:"messengers,omitempty"`
+	Notification *messenger.Notification `json:"notification,omitempty"`
+	Event        *event                  `json:"event,omitempty"`
+	Error        string                  `json:"error,omitempty"`
+}
+
+// event is the persisted form of a notification's originating event
+type event struct {
+	Type      eventbus.EventType `json:"type"`
+	Data      json.RawMessage    `json:"data,omitempty"`
+	Timestamp time.Time          `json:"timestamp"`
+}
+
+// Open opens the outbox at path, creating it if needed. Entries left pending
+// by a previous run are loaded and the file is compacted so that it only
+// holds them. Only one Outbox may use a file at a time.
+func Open(path string) (*Outbox, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create outbox directory: %w", err)
+	}
+
+	o := &Outbox{
+		path:    path,
+		entries: make(map[string]*Entry),
+	}
+	if err := o.load(); err != nil {
+		return nil, err
+	}
+	if err := o.compact(); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// Add persists a notification before it is delivered to the named
+// messengers and returns its ID. The write is synced to disk.
+func (o *Outbox) Add(notification messenger.Notification, messengers []string) (string, error) {
+	rec, err := addRecord(newID(), notification, messengers)
+	if err != nil {
+		return "", err
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if err := o.write(rec, true); err != nil {
+		return "", err
+	}
+	o.apply(rec)
+	return rec.ID, nil
+}
+
+// MarkDelivered records that the messenger delivered the entry
+func (o *Outbox) MarkDelivered(id, messengerName string) error {
+	return o.mark(record{Op: opDelivered, ID: id, Messenger: messengerName})
+}
+
+// MarkFailed records that the messenger gave up on the entry; it is not
+// replayed for that messenger
+func (o *Outbox) MarkFailed(id, messengerName string, cause error) error {
+	rec := record{Op: opFailed, ID: id, Messenger: messengerName}
+	if cause != nil {
+		rec.Error = cause.Error()
+	}
+	return o.mark(rec)
+}
+
+// Pending returns the entries some messenger has yet to deliver, oldest
+// first
+func (o *Outbox) Pending() []Entry {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	pending := make([]Entry, 0, len(o.entries))
+	for _, id := range o.order {
+		if entry, ok := o.entries[id]; ok {
+			copied := *entry
+			copied.Pending = append([]string(nil), entry.Pending...)
+			pending = append(pending, copied)
+		}
+	}
+	return pending
+}
+
+// Close compacts and closes the outbox file
+func (o *Outbox) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.file == nil {
+		return nil
+	}
+	if err := o.compactLocked(); err != nil {
+		return err
+	}
+	err := o.file.Close()
+	o.file = nil
+	return err
+}
+
+// mark appends a delivery outcome for one messenger, compacting the file
+// once enough entries have been resolved
+func (o *Outbox) mark(rec record) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if err := o.write(rec, false); err != nil {
+		return err
+	}
+	o.apply(rec)
+
+	if resolved := len(o.order) - len(o.entries); resolved >= compactThreshold && resolved > len(o.entries) {
+		return o.compactLocked()
+	}
+	return nil
+}
+
+// write appends a record to the file, syncing it when asked to. The caller
+// must hold o.mu.
+func (o *Outbox) write(rec record, sync bool) error {
+	if o.file == nil {
+		return ErrClosed
+	}
+
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to marshal outbox record: %w", err)
+	}
+	if _, err := o.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write outbox record: %w", err)
+	}
+	if sync {
+		if err := o.file.Sync(); err != nil {
+			return fmt.Errorf("failed to sync outbox: %w", err)
+		}
+	}
+	return nil
+}
+
+// apply updates the in-memory state with a record. The caller must hold
+// o.mu.
+func (o *Outbox) apply(rec record) {
+	switch rec.Op {
+	case opAdd:
+		if rec.Notification == nil || len(rec.Messengers) == 0 {
+			return
+		}
+		notification := *rec.Notification
+		if rec.Event != nil {
+			notification.Event = rec.Event.decode()
+		}
+		o.entries[rec.ID] = &Entry{
+			ID:           rec.ID,
+			Notification: notification,
+			Pending:      append([]string(nil), rec.Messengers...),
+		}
+		o.order = append(o.order, rec.ID)
+
+	case opDelivered, opFailed:
+		entry, ok := o.entries[rec.ID]
+		if !ok {
+			return
+		}
+		for i, name := range entry.Pending {
+			if name == rec.Messenger {
+				entry.Pending = append(entry.Pending[:i], entry.Pending[i+1:]...)
+				break
+			}
+		}
+		if len(entry.Pending) == 0 {
+			delete(o.entries, rec.ID)
+		}
+	}
+}
+
+// load replays the records in the file
+func (o *Outbox) load() error {
+	err := readRecords(o.path, func(line []byte) error {
+		var rec record
+		if json.Unmarshal(line, &rec) == nil {
+			o.apply(rec)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Drop the IDs of entries that were fully resolved
+	order := o.order[:0]
+	for _, id := range o.order {
+		if _, ok := o.entries[id]; ok {
+			order = append(order, id)
+		}
+	}
+	o.order = order
+	return nil
+}
+
+// compact rewrites the file with only the pending entries
+func (o *Outbox) compact() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.compactLocked()
+}
+
+// compactLocked rewrites the file with only the pending entries and reopens
+// it for appending. The caller must hold o.mu.
+func (o *Outbox) compactLocked() error {
+	var buf bytes.Buffer
+	order := make([]string, 0, len(o.entries))
+	for _, id := range o.order {
+		entry, ok := o.entries[id]
+		if !ok {
+			continue
+		}
+		order = append(order, id)
+
+		rec, err := addRecord(entry.ID, entry.Notification, entry.Pending)
+		if err != nil {
+			return err
+		}
+		line, err := json.Marshal(rec)
+		if err != nil {
+			return fmt.Errorf("failed to marshal outbox record: %w", err)
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	o.order = order
+
+	if o.file != nil {
+		o.file.Close()
+		o.file = nil
+	}
+	file, err := rewrite(o.path, buf.Bytes())
+	if err != nil {
+		return err
+	}
+	o.file = file
+	return nil
+}
+
+// rewrite atomically replaces the file at path with content and reopens it
+// for appending
+func rewrite(path string, content []byte) (*os.File, error) {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, content, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return nil, fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	return file, nil
+}
+
+// readRecords calls apply with every line of the file at path that holds a
+// valid JSON record. A missing file holds no records, and a truncated last
+// line, left by a crash in the middle of a write, is ignored.
+func readRecords(path string, apply func(line []byte) error) error {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 && json.Valid(line) {
+			if applyErr := apply(line); applyErr != nil {
+				return applyErr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+	}
+}
+
+// addRecord builds the record that persists a notification
+func addRecord(id string, notification messenger.Notification, messengers []string) (record, error) {
+	rec := record{
+		Op:           opAdd,
+		ID:           id,
+		Messengers:   messengers,
+		Notification: &notification,
+	}
+	event, err := encodeEvent(notification.Event)
+	if err != nil {
+		return record{}, err
+	}
+	rec.Event = event
+	return rec, nil
+}
+
+// encodeEvent returns the persisted form of an event, or nil for the zero
+// event of service messages
+func encodeEvent(e eventbus.Event) (*event, error) {
+	if e.Type == "" {
+		return nil, nil
+	}
+	data, err := json.Marshal(e.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal event data: %w", err)
+	}
+	return &event{Type: e.Type, Data: data, Timestamp: e.Timestamp}, nil
+}
+
+// decode restores the event with its payload decoded into the type the
+// service attaches for that event type
+func (e *event) decode() eventbus.Event {
+	restored := eventbus.Event{Type: e.Type, Timestamp: e.Timestamp}
+	if len(e.Data) == 0 {
+		return restored
+	}
+
+	var data interface{}
+	switch e.Type {
+	case eventbus.EventTradeExecuted:
+		data = &types.Trade{}
+	case eventbus.EventOrderFilled:
+		data = &types.Order{}
+	case eventbus.EventPositionOpened, eventbus.EventPositionClosed:
+		data = &types.Position{}
+	case eventbus.EventPnLUpdate:
+		data = &types.PnLUpdate{}
+	case eventbus.EventStrategyError:
+		data = &types.StrategyError{}
+	case eventbus.EventStrategyRecovered:
+		data = &types.StrategyRecovery{}
+	case eventbus.EventBreakerStateChanged:
+		data = &types.BreakerStateChange{}
+	case eventbus.EventSystemError, eventbus.EventSystemRecovered:
+		var message string
+		if json.Unmarshal(e.Data, &message) == nil {
+			restored.Data = message
+		}
+		return restored
+	default:
+		json.Unmarshal(e.Data, &restored.Data)
+		return restored
+	}
+
+	if json.Unmarshal(e.Data, data) == nil {
+		switch d := data.(type) {
+		case *types.Trade:
+			restored.Data = *d
+		case *types.Order:
+			restored.Data = *d
+		case *types.Position:
+			restored.Data = *d
+		case *types.PnLUpdate:
+			restored.Data = *d
+		case *types.StrategyError:
+			restored.Data = *d
+		case *types.StrategyRecovery:
+			restored.Data = *d
+		case *types.BreakerStateChange:
+			restored.Data = *d
+		}
+	}
+	return restored
+}
+
+// newID returns a random entry ID
+func newID() string {
+	random := make([]byte, 8)
+	rand.Read(random)
+	return fmt.Sprintf("%d-%s", time.Now().UnixNano(), hex.EncodeToString(random))
+}
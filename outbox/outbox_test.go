@@ -0,0 +1,162 @@
+package outbox
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/evdnx/gonotify/eventbus"
+	"github.com/evdnx/gonotify/messenger"
+	"github.com/evdnx/gonotify/types"
+)
+
+func tradeNotification() messenger.Notification {
+	return messenger.Notification{
+		Title:     "💰 Trade Executed",
+		Body:      "buy 0.5 BTCUSDT at 68000",
+		Severity:  messenger.SeverityInfo,
+		EventType: eventbus.EventTradeExecuted,
+		Fields:    []messenger.Field{{Name: "Symbol", Value: "BTCUSDT"}},
+		Timestamp: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+		Event: eventbus.Event{
+			Type:      eventbus.EventTradeExecuted,
+			Data:      types.Trade{ID: "trade-1", Symbol: "BTCUSDT", Side: "buy", Price: 68000, Quantity: 0.5},
+			Timestamp: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+		},
+	}
+}
+
+func TestPendingSurvivesReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.jsonl")
+
+	box, err := Open(path)
+	if err != nil {
+		t.Fatalf("failed to open outbox: %v", err)
+	}
+	id, err := box.Add(tradeNotification(), []string{"Telegram", "Slack"})
+	if err != nil {
+		t.Fatalf("failed to add notification: %v", err)
+	}
+	if _, err := box.Add(tradeNotification(), []string{"Telegram"}); err != nil {
+		t.Fatalf("failed to add notification: %v", err)
+	}
+	if err := box.MarkDelivered(id, "Telegram"); err != nil {
+		t.Fatalf("failed to mark delivered: %v", err)
+	}
+
+	// Simulate a crash: reopen without closing
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("failed to reopen outbox: %v", err)
+	}
+	defer reopened.Close()
+
+	pending := reopened.Pending()
+	if len(pending) != 2 {
+		t.Fatalf("expected 2 pending entries, got %d", len(pending))
+	}
+	if pending[0].ID != id || len(pending[0].Pending) != 1 || pending[0].Pending[0] != "Slack" {
+		t.Fatalf("unexpected first entry: %+v", pending[0])
+	}
+
+	notification := pending[0].Notification
+	if notification.Title != "💰 Trade Executed" || !notification.Timestamp.Equal(tradeNotification().Timestamp) {
+		t.Fatalf("notification not restored: %+v", notification)
+	}
+	trade, ok := notification.Event.Data.(types.Trade)
+	if !ok || trade.ID != "trade-1" || trade.Price != 68000 {
+		t.Fatalf("expected typed trade payload, got %#v", notification.Event.Data)
+	}
+}
+
+func TestResolvedEntriesAreCompacted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.jsonl")
+
+	box, err := Open(path)
+	if err != nil {
+		t.Fatalf("failed to open outbox: %v", err)
+	}
+	id, err := box.Add(tradeNotification(), []string{"Telegram", "Slack"})
+	if err != nil {
+		t.Fatalf("failed to add notification: %v", err)
+	}
+	box.MarkDelivered(id, "Telegram")
+	box.MarkFailed(id, "Slack", errors.New("channel_not_found"))
+
+	if pending := box.Pending(); len(pending) != 0 {
+		t.Fatalf("expected no pending entries, got %+v", pending)
+	}
+	if err := box.Close(); err != nil {
+		t.Fatalf("failed to close outbox: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("failed to stat outbox: %v", err)
+	}
+	if info.Size() != 0 {
+		t.Fatalf("expected compacted outbox to be empty, got %d bytes", info.Size())
+	}
+
+	if _, err := box.Add(tradeNotification(), []string{"Telegram"}); !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+}
+
+func TestTruncatedRecordIsIgnored(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.jsonl")
+
+	box, err := Open(path)
+	if err != nil {
+		t.Fatalf("failed to open outbox: %v", err)
+	}
+	if _, err := box.Add(tradeNotification(), []string{"Telegram"}); err != nil {
+		t.Fatalf("failed to add notification: %v", err)
+	}
+
+	// A crash in the middle of a write leaves half a record behind
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatalf("failed to open outbox file: %v", err)
+	}
+	file.WriteString(`{"op":"add","id":"broken","notifica`)
+	file.Close()
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("failed to reopen outbox: %v", err)
+	}
+	defer reopened.Close()
+
+	if pending := reopened.Pending(); len(pending) != 1 {
+		t.Fatalf("expected 1 pending entry, got %d", len(pending))
+	}
+}
+
+func TestCompactsWhileInUse(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.jsonl")
+
+	box, err := Open(path)
+	if err != nil {
+		t.Fatalf("failed to open outbox: %v", err)
+	}
+	defer box.Close()
+
+	for i := 0; i < compactThreshold; i++ {
+		id, err := box.Add(tradeNotification(), []string{"Telegram"})
+		if err != nil {
+			t.Fatalf("failed to add notification: %v", err)
+		}
+		box.MarkDelivered(id, "Telegram")
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("failed to stat outbox: %v", err)
+	}
+	if info.Size() != 0 {
+		t.Fatalf("expected outbox to be compacted, got %d bytes", info.Size())
+	}
+}
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/evdnx/gonotify/types"
+)
+
+// Circuit breaker states
+const (
+	BreakerClosed   = "closed"    // deliveries go through
+	BreakerOpen     = "open"      // deliveries are rejected without calling the messenger
+	BreakerHalfOpen = "half_open" // a probe delivery tests whether the messenger recovered
+)
+
+// Breaker defaults used when the config leaves a setting empty
+const (
+	defaultBreakerFailureThreshold = 5
+	defaultBreakerOpenTimeout      = 30 * time.Second
+	defaultBreakerSuccessThreshold = 1
+)
+
+// errCircuitOpen is recorded for deliveries rejected by an open breaker
+var errCircuitOpen = errors.New("circuit breaker is open")
+
+// breakerOptions configures the circuit breaker of every messenger.
+// onChange is called with every state change and must not block.
+type breakerOptions struct {
+	enabled          bool
+	failureThreshold int
+	openTimeout      time.Duration
+	successThreshold int
+	onChange         func(types.BreakerStateChange)
+}
+
+// circuitBreaker stops deliveries to a messenger that keeps failing. It
+// opens after failureThreshold consecutive failures and rejects deliveries
+// until openTimeout has passed. It then lets a single probe through at a
+// time, closing after successThreshold successful probes and opening again
+// on the first failure.
+type circuitBreaker struct {
+	name    string
+	options breakerOptions
+
+	mu        sync.Mutex
+	state     string
+	failures  int // consecutive failures while closed
+	successes int // successful probes while half-open
+	probing   bool
+	openedAt  time.Time
+}
+
+// newCircuitBreaker creates a closed breaker for the named messenger
+func newCircuitBreaker(name string, options breakerOptions) *circuitBreaker {
+	return &circuitBreaker{
+		name:    name,
+		options: options,
+		state:   BreakerClosed,
+	}
+}
+
+// allow reports whether a delivery may be attempted. Every allowed delivery
+// must be followed by succeed, fail or abandon.
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.options.openTimeout {
+			return false
+		}
+		b.transition(BreakerHalfOpen, nil)
+	case BreakerClosed:
+		return true
+	}
+
+	// Half-open: one probe at a time
+	if b.probing {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+// succeed records a delivery the messenger handled
+func (b *circuitBreaker) succeed() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerClosed:
+		b.failures = 0
+	case BreakerHalfOpen:
+		b.probing = false
+		b.successes++
+		if b.successes >= b.options.successThreshold {
+			b.transition(BreakerClosed, nil)
+		}
+	}
+}
+
+// fail records a delivery the messenger failed
+func (b *circuitBreaker) fail(cause error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerClosed:
+		b.failures++
+		if b.failures >= b.options.failureThreshold {
+			b.transition(BreakerOpen, cause)
+		}
+	case BreakerHalfOpen:
+		b.probing = false
+		b.transition(BreakerOpen, cause)
+	}
+}
+
+// abandon records a delivery that was cut short before the messenger's
+// health could be judged
+func (b *circuitBreaker) abandon() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerHalfOpen {
+		b.probing = false
+	}
+}
+
+// currentState returns the breaker's state
+func (b *circuitBreaker) currentState() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// transition moves the breaker to a new state and reports the change. The
+// caller must hold b.mu.
+func (b *circuitBreaker) transition(to string, cause error) {
+	change := types.BreakerStateChange{
+		Messenger: b.name,
+		From:      b.state,
+		To:        to,
+		Timestamp: time.Now(),
+	}
+	if cause != nil {
+		change.Error = cause.Error()
+	}
+
+	b.state = to
+	b.failures = 0
+	b.successes = 0
+	if to == BreakerOpen {
+		b.openedAt = change.Timestamp
+	}
+
+	if b.options.onChange != nil {
+		b.options.onChange(change)
+	}
+}
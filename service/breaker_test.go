@@ -0,0 +1,198 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/evdnx/gonotify/eventbus"
+	"github.com/evdnx/gonotify/messenger"
+	"github.com/evdnx/gonotify/types"
+)
+
+// recordChanges returns breaker options that collect state changes
+func recordChanges(options breakerOptions) (breakerOptions, func() []string) {
+	var mu sync.Mutex
+	var changes []string
+	options.enabled = true
+	options.onChange = func(change types.BreakerStateChange) {
+		mu.Lock()
+		changes = append(changes, change.From+"->"+change.To)
+		mu.Unlock()
+	}
+	return options, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), changes...)
+	}
+}
+
+func TestBreakerOpensAndRecovers(t *testing.T) {
+	options, changes := recordChanges(breakerOptions{failureThreshold: 2, openTimeout: 50 * time.Millisecond, successThreshold: 2})
+	b := newCircuitBreaker("Telegram", options)
+	outage := errors.New("bad gateway")
+
+	b.allow()
+	b.fail(outage)
+	if b.currentState() != BreakerClosed {
+		t.Fatal("breaker opened before reaching the failure threshold")
+	}
+	b.allow()
+	b.fail(outage)
+	if b.currentState() != BreakerOpen || b.allow() {
+		t.Fatal("expected open breaker to reject deliveries")
+	}
+
+	// After the timeout a single probe goes through
+	time.Sleep(60 * time.Millisecond)
+	if !b.allow() {
+		t.Fatal("expected a probe after the open timeout")
+	}
+	if b.allow() {
+		t.Fatal("expected only one probe at a time")
+	}
+	b.succeed()
+	if b.currentState() != BreakerHalfOpen || !b.allow() {
+		t.Fatal("expected another probe before reaching the success threshold")
+	}
+	b.succeed()
+	if b.currentState() != BreakerClosed {
+		t.Fatalf("expected closed breaker, got %s", b.currentState())
+	}
+
+	want := []string{"closed->open", "open->half_open", "half_open->closed"}
+	if got := changes(); len(got) != len(want) || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Fatalf("expected changes %v, got %v", want, got)
+	}
+}
+
+func TestBreakerReopensOnFailedProbe(t *testing.T) {
+	options, changes := recordChanges(breakerOptions{failureThreshold: 1, openTimeout: 10 * time.Millisecond, successThreshold: 1})
+	b := newCircuitBreaker("Telegram", options)
+
+	b.allow()
+	b.fail(errors.New("timeout"))
+	time.Sleep(20 * time.Millisecond)
+	if !b.allow() {
+		t.Fatal("expected a probe after the open timeout")
+	}
+	b.fail(errors.New("timeout"))
+	if b.currentState() != BreakerOpen || b.allow() {
+		t.Fatal("expected failed probe to reopen the breaker")
+	}
+	if got := changes(); len(got) != 3 || got[2] != "half_open->open" {
+		t.Fatalf("unexpected changes: %v", got)
+	}
+}
+
+func TestBreakerFailsOverWhileOpen(t *testing.T) {
+	outage := &messenger.HTTPError{StatusCode: 502, Err: errors.New("bad gateway")}
+	primary := &flakyMessenger{errs: []error{outage}}
+	fallback := &flakyMessenger{}
+
+	options, changes := recordChanges(breakerOptions{failureThreshold: 1, openTimeout: time.Minute, successThreshold: 1})
+	queueOptions := queueOptions{size: 1, workers: 1, overflow: OverflowBlock, timeout: time.Second, retry: retryPolicy{maxAttempts: 1}, breaker: options}
+
+	var inflight sync.WaitGroup
+	primaryQueue := newDeliveryQueue("Telegram", primary, queueOptions, nil, nil, &inflight)
+	fallbackQueue := newDeliveryQueue("Element", fallback, queueOptions, nil, nil, &inflight)
+	primaryQueue.fallbacks = []*deliveryQueue{fallbackQueue}
+
+	send := func(title string) {
+		primaryQueue.enqueue(delivery{ctx: context.Background(), notification: messenger.Notification{Title: title}, targets: []string{"Telegram"}})
+		inflight.Wait()
+	}
+
+	// The first failure opens the breaker, the next notification fails over
+	send("first")
+	send("second")
+	primaryQueue.close()
+	fallbackQueue.close()
+
+	if primary.attempts != 1 {
+		t.Fatalf("expected the open breaker to stop deliveries, got %d attempts", primary.attempts)
+	}
+	if fallback.attempts != 1 {
+		t.Fatalf("expected the fallback to deliver once, got %d attempts", fallback.attempts)
+	}
+	if got := changes(); len(got) != 1 || got[0] != "closed->open" {
+		t.Fatalf("unexpected changes: %v", got)
+	}
+	if stats := primaryQueue.stats(); stats.Breaker != BreakerOpen {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestBreakerSkipsFallbacksAlreadySent(t *testing.T) {
+	primary := &flakyMessenger{errs: []error{context.DeadlineExceeded}}
+	fallback := &flakyMessenger{}
+
+	options, _ := recordChanges(breakerOptions{failureThreshold: 1, openTimeout: time.Minute, successThreshold: 1})
+	queueOptions := queueOptions{size: 1, workers: 1, overflow: OverflowBlock, timeout: time.Second, retry: retryPolicy{maxAttempts: 1}, breaker: options}
+
+	var inflight sync.WaitGroup
+	primaryQueue := newDeliveryQueue("Telegram", primary, queueOptions, nil, nil, &inflight)
+	fallbackQueue := newDeliveryQueue("Element", fallback, queueOptions, nil, nil, &inflight)
+	primaryQueue.fallbacks = []*deliveryQueue{fallbackQueue}
+
+	for _, title := range []string{"first", "second"} {
+		primaryQueue.enqueue(delivery{ctx: context.Background(), notification: messenger.Notification{Title: title}, targets: []string{"Telegram", "Element"}})
+		inflight.Wait()
+	}
+	primaryQueue.close()
+	fallbackQueue.close()
+
+	if fallback.attempts != 0 {
+		t.Fatalf("expected no failover to a messenger that already has the notification, got %d attempts", fallback.attempts)
+	}
+}
+
+func TestBreakerStateChangesArePublished(t *testing.T) {
+	eventBus := eventbus.NewEventBus()
+	failing := &flakyMessenger{errs: []error{&messenger.HTTPError{StatusCode: 503, Err: errors.New("service unavailable")}}}
+	mockMsg := newMockMessenger()
+
+	cfg := testConfig()
+	cfg.RetryMaxAttempts = 1
+	cfg.BreakerEnabled = true
+	cfg.BreakerFailureThreshold = 1
+	cfg.BreakerOpenTimeout = time.Minute
+	cfg.NotifyBreakerStateChange = true
+
+	changes := make(chan types.BreakerStateChange, 10)
+	eventBus.Subscribe(eventbus.EventBreakerStateChanged, "test", func(event eventbus.Event) {
+		changes <- event.Data.(types.BreakerStateChange)
+	})
+
+	service, err := NewNotificationServiceWithMessengers(cfg, eventBus, []messenger.Messenger{failing, mockMsg})
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+	if err := service.Start(); err != nil {
+		t.Fatalf("failed to start service: %v", err)
+	}
+	defer service.Stop(context.Background())
+	mockMsg.waitForMessage(t, "Notification service started")
+
+	select {
+	case change := <-changes:
+		if change.Messenger != "Flaky" || change.From != BreakerClosed || change.To != BreakerOpen || change.Error != "service unavailable" {
+			t.Fatalf("unexpected state change: %+v", change)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for breaker state change")
+	}
+	mockMsg.waitForMessage(t, "Circuit Opened for Flaky")
+}
+
+func TestFailoverRejectsUnknownMessenger(t *testing.T) {
+	cfg := testConfig()
+	cfg.Failover = map[string][]string{"Mock": {"Telegram"}}
+
+	_, err := NewNotificationServiceWithMessengers(cfg, eventbus.NewEventBus(), []messenger.Messenger{newMockMessenger()})
+	if err == nil {
+		t.Fatal("expected error for unknown failover messenger")
+	}
+}
@@ -0,0 +1,216 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/evdnx/gonotify/config"
+	"github.com/evdnx/gonotify/messenger"
+	"github.com/evdnx/gonotify/messenger/discord"
+	"github.com/evdnx/gonotify/messenger/element"
+	"github.com/evdnx/gonotify/messenger/email"
+	"github.com/evdnx/gonotify/messenger/gotify"
+	"github.com/evdnx/gonotify/messenger/ntfy"
+	"github.com/evdnx/gonotify/messenger/opsgenie"
+	"github.com/evdnx/gonotify/messenger/pagerduty"
+	"github.com/evdnx/gonotify/messenger/slack"
+	"github.com/evdnx/gonotify/messenger/telegram"
+	"github.com/evdnx/gonotify/messenger/webhook"
+)
+
+// configuredMessengers creates the messengers enabled in the config along
+// with their instance names, which are empty for unnamed instances
+func configuredMessengers(cfg *config.NotificationConfig) ([]messenger.Messenger, []string, error) {
+	var messengers []messenger.Messenger
+	var names []string
+	for _, instance := range cfg.MessengerConfigs() {
+		if instance.Type() == "" {
+			return nil, nil, fmt.Errorf("messenger %s must have settings for exactly one messenger type", instance.Name)
+		}
+		if !instance.Enabled() {
+			continue
+		}
+
+		m, err := newMessenger(instance, cfg)
+		if err != nil {
+			if instance.Name != "" {
+				return nil, nil, fmt.Errorf("messenger %s: %w", instance.Name, err)
+			}
+			return nil, nil, err
+		}
+		messengers = append(messengers, m)
+		names = append(names, instance.Name)
+	}
+
+	if len(messengers) == 0 {
+		return nil, nil, fmt.Errorf("at least one messenger must be enabled in configuration")
+	}
+	return messengers, names, nil
+}
+
+// newMessenger creates the messenger of an instance, validating its settings.
+// cfg supplies the hooks shared by all instances.
+func newMessenger(instance config.MessengerConfig, cfg *config.NotificationConfig) (messenger.Messenger, error) {
+	switch instance.Type() {
+	case "element":
+		var onRefreshToken func(string)
+		if hook := cfg.OnElementRefreshToken; hook != nil {
+			onRefreshToken = func(refreshToken string) { hook(instance.Name, refreshToken) }
+		}
+		cfg := instance.Element
+		if cfg.HomeserverURL == "" {
+			return nil, fmt.Errorf("element homeserver URL is required when element is enabled")
+		}
+		if cfg.AccessToken == "" && cfg.RefreshToken == "" && (cfg.User == "" || cfg.Password == "") {
+			return nil, fmt.Errorf("element access token, refresh token or user and password are required when element is enabled")
+		}
+		if cfg.RoomID == "" {
+			return nil, fmt.Errorf("element room ID is required when element is enabled")
+		}
+		switch cfg.MsgType {
+		case "", element.MsgTypeText, element.MsgTypeNotice:
+		default:
+			return nil, fmt.Errorf("unknown element msgtype: %s", cfg.MsgType)
+		}
+		if cfg.MentionSeverity != "" && !element.ValidSeverity(messenger.Severity(cfg.MentionSeverity)) {
+			return nil, fmt.Errorf("unknown element mention severity: %s", cfg.MentionSeverity)
+		}
+		for _, mention := range cfg.Mentions {
+			if !strings.HasPrefix(mention, "@") {
+				return nil, fmt.Errorf("element mention must be a user ID or @room: %s", mention)
+			}
+		}
+		return element.NewClientWithOptions(cfg.HomeserverURL, cfg.AccessToken, cfg.RoomID, element.Options{
+			MsgType:         cfg.MsgType,
+			Mentions:        cfg.Mentions,
+			MentionSeverity: messenger.Severity(cfg.MentionSeverity),
+			User:            cfg.User,
+			Password:        cfg.Password,
+			DeviceID:        cfg.DeviceID,
+			RefreshToken:    cfg.RefreshToken,
+			OnRefreshToken:  onRefreshToken,
+			AutoJoin:        cfg.AutoJoin,
+		}), nil
+
+	case "telegram":
+		cfg := instance.Telegram
+		if cfg.BotToken == "" {
+			return nil, fmt.Errorf("telegram bot token is required when telegram is enabled")
+		}
+		if cfg.ChatID == "" {
+			return nil, fmt.Errorf("telegram chat ID is required when telegram is enabled")
+		}
+		switch cfg.ParseMode {
+		case "", telegram.ParseModeMarkdownV2, telegram.ParseModeHTML:
+		default:
+			return nil, fmt.Errorf("unknown telegram parse mode: %s", cfg.ParseMode)
+		}
+		return telegram.NewClientWithOptions(cfg.BotToken, cfg.ChatID, telegram.Options{
+			ParseMode:             cfg.ParseMode,
+			DisableWebPagePreview: cfg.DisableWebPagePreview,
+			DisableNotification:   cfg.DisableNotification,
+			OrderURL:              cfg.OrderURL,
+		}), nil
+
+	case "slack":
+		cfg := instance.Slack
+		if cfg.WebhookURL != "" {
+			return slack.NewWebhookClient(cfg.WebhookURL), nil
+		}
+		if cfg.BotToken == "" {
+			return nil, fmt.Errorf("slack webhook URL or bot token is required when slack is enabled")
+		}
+		if cfg.Channel == "" {
+			return nil, fmt.Errorf("slack channel is required when slack is enabled with a bot token")
+		}
+		return slack.NewClient(cfg.BotToken, cfg.Channel), nil
+
+	case "discord":
+		cfg := instance.Discord
+		if cfg.WebhookURL == "" {
+			return nil, fmt.Errorf("discord webhook URL is required when discord is enabled")
+		}
+		return discord.NewClient(cfg.WebhookURL, cfg.Username), nil
+
+	case "email":
+		cfg := instance.Email
+		if cfg.Host == "" {
+			return nil, fmt.Errorf("email SMTP host is required when email is enabled")
+		}
+		if cfg.From == "" {
+			return nil, fmt.Errorf("email sender address is required when email is enabled")
+		}
+		if len(cfg.To) == 0 {
+			return nil, fmt.Errorf("at least one email recipient is required when email is enabled")
+		}
+		switch cfg.Security {
+		case "", email.SecuritySTARTTLS, email.SecurityTLS, email.SecurityNone:
+		default:
+			return nil, fmt.Errorf("unknown email security mode: %s", cfg.Security)
+		}
+		switch cfg.Auth {
+		case "", email.AuthPlain, email.AuthLogin:
+		default:
+			return nil, fmt.Errorf("unknown email auth mechanism: %s", cfg.Auth)
+		}
+		return email.NewClient(email.Config{
+			Host:          cfg.Host,
+			Port:          cfg.Port,
+			Username:      cfg.Username,
+			Password:      cfg.Password,
+			From:          cfg.From,
+			To:            cfg.To,
+			Security:      cfg.Security,
+			Auth:          cfg.Auth,
+			SubjectPrefix: cfg.SubjectPrefix,
+		}), nil
+
+	case "webhook":
+		cfg := instance.Webhook
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("webhook URL is required when webhook is enabled")
+		}
+		return webhook.NewClient(webhook.Config{
+			URL:                cfg.URL,
+			Method:             cfg.Method,
+			Secret:             cfg.Secret,
+			SignatureHeader:    cfg.SignatureHeader,
+			Headers:            cfg.Headers,
+			Timeout:            time.Duration(cfg.TimeoutSeconds) * time.Second,
+			SuccessStatusCodes: cfg.SuccessStatusCodes,
+		}), nil
+
+	case "ntfy":
+		cfg := instance.Ntfy
+		if cfg.Topic == "" {
+			return nil, fmt.Errorf("ntfy topic is required when ntfy is enabled")
+		}
+		return ntfy.NewClient(cfg.ServerURL, cfg.Topic, cfg.Token), nil
+
+	case "gotify":
+		cfg := instance.Gotify
+		if cfg.ServerURL == "" {
+			return nil, fmt.Errorf("gotify server URL is required when gotify is enabled")
+		}
+		if cfg.AppToken == "" {
+			return nil, fmt.Errorf("gotify app token is required when gotify is enabled")
+		}
+		return gotify.NewClient(cfg.ServerURL, cfg.AppToken), nil
+
+	case "pagerduty":
+		cfg := instance.PagerDuty
+		if cfg.RoutingKey == "" {
+			return nil, fmt.Errorf("pagerduty routing key is required when pagerduty is enabled")
+		}
+		return pagerduty.NewClient(cfg.RoutingKey, cfg.Source, eventTypes(cfg.Events)), nil
+
+	case "opsgenie":
+		cfg := instance.Opsgenie
+		if cfg.APIKey == "" {
+			return nil, fmt.Errorf("opsgenie API key is required when opsgenie is enabled")
+		}
+		return opsgenie.NewClient(cfg.APIKey, cfg.APIURL, eventTypes(cfg.Events)), nil
+	}
+	return nil, fmt.Errorf("unknown messenger type")
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/evdnx/gonotify/config"
+	"github.com/evdnx/gonotify/eventbus"
+)
+
+func TestNamedMessengerInstances(t *testing.T) {
+	cfg := testConfig()
+	cfg.TelegramEnabled = true
+	cfg.TelegramBotToken = "token"
+	cfg.TelegramChatID = "main"
+	cfg.Messengers = []config.MessengerConfig{
+		{Name: "Account A", Telegram: &config.TelegramConfig{BotToken: "token-a", ChatID: "chat-a", Enabled: true}},
+		{Name: "Account B", Telegram: &config.TelegramConfig{BotToken: "token-b", ChatID: "chat-b", Enabled: true}},
+		{Name: "Paused", Telegram: &config.TelegramConfig{BotToken: "token-c", ChatID: "chat-c"}},
+		{Element: &config.ElementConfig{HomeserverURL: "https://matrix.org", AccessToken: "token", RoomID: "!dev:matrix.org", Enabled: true}},
+	}
+	cfg.Routes = []config.RouteConfig{{Events: []string{"order_filled"}, Messengers: []string{"Account B"}}}
+
+	service, err := NewNotificationService(cfg, eventbus.NewEventBus())
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+
+	var names []string
+	for _, stats := range service.QueueStats() {
+		names = append(names, stats.Messenger)
+	}
+	if got := strings.Join(names, ","); got != "Telegram,Account A,Account B,Element" {
+		t.Fatalf("unexpected messengers: %s", got)
+	}
+}
+
+func TestNamedMessengerInstanceErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		instances []config.MessengerConfig
+		want      string
+	}{
+		{
+			name:      "missing settings",
+			instances: []config.MessengerConfig{{Name: "Account A", Telegram: &config.TelegramConfig{ChatID: "chat", Enabled: true}}},
+			want:      "messenger Account A: telegram bot token is required",
+		},
+		{
+			name:      "no type",
+			instances: []config.MessengerConfig{{Name: "Account A"}},
+			want:      "exactly one messenger type",
+		},
+		{
+			name: "two types",
+			instances: []config.MessengerConfig{{
+				Name:     "Account A",
+				Telegram: &config.TelegramConfig{BotToken: "token", ChatID: "chat", Enabled: true},
+				Discord:  &config.DiscordConfig{WebhookURL: "https://discord.com/api/webhooks/1/abc", Enabled: true},
+			}},
+			want: "exactly one messenger type",
+		},
+		{
+			name: "duplicate name",
+			instances: []config.MessengerConfig{
+				{Name: "Trading", Telegram: &config.TelegramConfig{BotToken: "token-a", ChatID: "chat-a", Enabled: true}},
+				{Name: "Trading", Telegram: &config.TelegramConfig{BotToken: "token-b", ChatID: "chat-b", Enabled: true}},
+			},
+			want: "duplicate messenger name: Trading",
+		},
+		{
+			name: "unknown email security",
+			instances: []config.MessengerConfig{{
+				Name:  "Desk",
+				Email: &config.EmailConfig{Host: "smtp.example.com", From: "bot@example.com", To: []string{"desk@example.com"}, Security: "ssl", Enabled: true},
+			}},
+			want: "messenger Desk: unknown email security mode: ssl",
+		},
+		{
+			name: "unknown email auth",
+			instances: []config.MessengerConfig{{
+				Name:  "Desk",
+				Email: &config.EmailConfig{Host: "smtp.example.com", From: "bot@example.com", To: []string{"desk@example.com"}, Auth: "cram-md5", Enabled: true},
+			}},
+			want: "messenger Desk: unknown email auth mechanism: cram-md5",
+		},
+	}
+
+	for _, tt := range tests {
+		cfg := testConfig()
+		cfg.Messengers = tt.instances
+
+		_, err := NewNotificationService(cfg, eventbus.NewEventBus())
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.want, err)
+		}
+	}
+}
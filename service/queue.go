@@ -0,0 +1,328 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/evdnx/gonotify/messenger"
+	"github.com/evdnx/gonotify/outbox"
+)
+
+// Overflow policies applied when a messenger's delivery queue is full
+const (
+	OverflowBlock      = "block"       // wait for room, holding up the publisher
+	OverflowDropOldest = "drop_oldest" // discard the oldest queued notification
+	OverflowDropNewest = "drop_newest" // discard the incoming notification
+)
+
+// Queue defaults used when the config leaves a setting empty
+const (
+	defaultQueueSize = 100
+	defaultWorkers   = 1
+	defaultOverflow  = OverflowDropOldest
+)
+
+// QueueStats reports the state of one messenger's delivery queue. Breaker
+// is the state of its circuit breaker, or empty when breakers are disabled.
+type QueueStats struct {
+	Messenger string
+	Queued    int
+	Dropped   uint64
+	Breaker   string
+}
+
+// errQueueFull is recorded for deliveries dropped on overflow
+var errQueueFull = errors.New("delivery queue is full")
+
+// queueOptions configures a delivery queue
+type queueOptions struct {
+	size     int
+	workers  int
+	overflow string
+	timeout  time.Duration
+	retry    retryPolicy
+	breaker  breakerOptions
+}
+
+// delivery is a notification waiting in a queue together with the context
+// it was sent under, its outbox ID, which is empty for notifications that
+// are not persisted, and the names of every messenger it was sent to
+type delivery struct {
+	ctx          context.Context
+	notification messenger.Notification
+	id           string
+	targets      []string
+}
+
+// deliveryQueue feeds one messenger from a bounded queue drained by a fixed
+// pool of workers
+type deliveryQueue struct {
+	name        string // unique among the service's messengers
+	messenger   messenger.NotificationMessenger
+	items       chan delivery
+	options     queueOptions
+	outbox      *outbox.Outbox
+	deadLetters *outbox.DeadLetterStore
+	inflight    *sync.WaitGroup
+	mu          sync.Mutex // serializes enqueue
+	dropped     atomic.Uint64
+	breaker     *circuitBreaker  // nil unless breakers are enabled
+	fallbacks   []*deliveryQueue // take over while the breaker is open
+}
+
+// newDeliveryQueue creates a queue for the messenger and starts its workers.
+// Every enqueued delivery is counted in inflight until it is sent or dropped.
+// Its outcome is recorded in box when one is given, and deliveries that fail
+// for good are kept in deadLetters when one is given.
+func newDeliveryQueue(name string, m messenger.NotificationMessenger, options queueOptions, box *outbox.Outbox, deadLetters *outbox.DeadLetterStore, inflight *sync.WaitGroup) *deliveryQueue {
+	q := &deliveryQueue{
+		name:        name,
+		messenger:   m,
+		items:       make(chan delivery, options.size),
+		options:     options,
+		outbox:      box,
+		deadLetters: deadLetters,
+		inflight:    inflight,
+	}
+	if options.breaker.enabled {
+		q.breaker = newCircuitBreaker(name, options.breaker)
+	}
+	for i := 0; i < options.workers; i++ {
+		go q.run()
+	}
+	return q
+}
+
+// enqueue adds a delivery, applying the overflow policy when the queue is
+// full. Under the block policy it gives up once the delivery's context is
+// done, leaving the notification in the outbox for the next run.
+func (q *deliveryQueue) enqueue(d delivery) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.inflight.Add(1)
+
+	switch q.options.overflow {
+	case OverflowBlock:
+		select {
+		case q.items <- d:
+		case <-d.ctx.Done():
+			q.inflight.Done()
+		}
+		return
+	case OverflowDropNewest:
+		select {
+		case q.items <- d:
+		default:
+			q.drop(d)
+		}
+		return
+	}
+
+	// Drop the oldest deliveries until there is room
+	for {
+		select {
+		case q.items <- d:
+			return
+		default:
+		}
+		select {
+		case oldest := <-q.items:
+			q.drop(oldest)
+		default:
+		}
+	}
+}
+
+// enqueueWait adds a delivery, waiting for room whatever the overflow
+// policy, so replayed and redelivered notifications are never dropped. It
+// gives up when ctx is done, leaving the delivery to the caller.
+func (q *deliveryQueue) enqueueWait(ctx context.Context, d delivery) error {
+	q.inflight.Add(1)
+	select {
+	case q.items <- d:
+		return nil
+	case <-ctx.Done():
+		q.inflight.Done()
+		return ctx.Err()
+	}
+}
+
+// drop records a discarded delivery
+func (q *deliveryQueue) drop(d delivery) {
+	q.dropped.Add(1)
+	q.resolve(d, 0, errQueueFull)
+	q.inflight.Done()
+}
+
+// run sends queued deliveries until the queue is closed
+func (q *deliveryQueue) run() {
+	for d := range q.items {
+		q.send(d)
+	}
+}
+
+// send delivers one notification, retrying retryable failures with
+// exponential backoff or after the delay a rate-limited messenger asked for.
+// Once the circuit breaker is open the delivery fails over instead.
+func (q *deliveryQueue) send(d delivery) {
+	defer q.inflight.Done()
+
+	for attempt := 1; ; attempt++ {
+		if q.breaker != nil && !q.breaker.allow() {
+			q.failover(d, attempt-1)
+			return
+		}
+
+		err := q.attempt(d)
+		if err == nil {
+			q.resolve(d, attempt, nil)
+			return
+		}
+
+		// Deliveries cut short by shutdown stay in the outbox for replay
+		if d.ctx.Err() != nil {
+			fmt.Printf("Failed to send notification via %s: %v\n", q.name, err)
+			return
+		}
+		if attempt >= q.options.retry.maxAttempts || !messenger.Retryable(err) {
+			// Log the error but don't propagate it
+			fmt.Printf("Failed to send notification via %s after %d attempt(s): %v\n", q.name, attempt, err)
+			q.resolve(d, attempt, err)
+			return
+		}
+
+		// Rate-limited messengers say exactly how long to wait
+		delay, ok := messenger.RetryAfter(err)
+		if !ok {
+			delay = q.options.retry.backoff(attempt)
+		}
+		if !wait(d.ctx, delay) {
+			fmt.Printf("Failed to send notification via %s: %v\n", q.name, d.ctx.Err())
+			return
+		}
+	}
+}
+
+// attempt makes a single delivery under its own timeout and feeds the
+// outcome to the circuit breaker. Only failures worth retrying count against
+// the messenger; a rejected notification says nothing about its health.
+func (q *deliveryQueue) attempt(d delivery) error {
+	ctx, cancel := context.WithTimeout(d.ctx, q.options.timeout)
+	defer cancel()
+
+	err := q.messenger.SendNotification(ctx, d.notification)
+	if q.breaker != nil {
+		switch {
+		case d.ctx.Err() != nil:
+			q.breaker.abandon()
+		case err != nil && messenger.Retryable(err):
+			q.breaker.fail(err)
+		default:
+			q.breaker.succeed()
+		}
+	}
+	return err
+}
+
+// failover hands a delivery rejected by the open breaker to the fallback
+// messengers that were not sent the notification already. Each fallback
+// delivers it right away on this worker, failing over in turn if its own
+// breaker is open. Without any fallback left the delivery fails.
+func (q *deliveryQueue) failover(d delivery, attempts int) {
+	var fallbacks []*deliveryQueue
+	targets := slices.Clone(d.targets)
+	for _, f := range q.fallbacks {
+		if !slices.Contains(targets, f.name) {
+			fallbacks = append(fallbacks, f)
+			targets = append(targets, f.name)
+		}
+	}
+	if len(fallbacks) == 0 {
+		fmt.Printf("Failed to send notification via %s: %v\n", q.name, errCircuitOpen)
+		q.resolve(d, attempts, errCircuitOpen)
+		return
+	}
+
+	// Persist the notification for the fallbacks before this messenger lets
+	// go of it
+	next := make([]delivery, 0, len(fallbacks))
+	for _, f := range fallbacks {
+		fallback := delivery{ctx: d.ctx, notification: d.notification, targets: targets}
+		if q.outbox != nil && d.id != "" {
+			id, err := q.outbox.Add(d.notification, []string{f.name})
+			if err != nil && !errors.Is(err, outbox.ErrClosed) {
+				fmt.Printf("Failed to persist notification: %v\n", err)
+			}
+			fallback.id = id
+		}
+		next = append(next, fallback)
+	}
+	q.mark(d, errCircuitOpen)
+
+	for i, f := range fallbacks {
+		fmt.Printf("Circuit breaker for %s is open, failing over to %s\n", q.name, f.name)
+		q.inflight.Add(1)
+		f.send(next[i])
+	}
+}
+
+// resolve records the final outcome of a delivery. Failures are kept as dead
+// letters before the outbox lets go of them.
+func (q *deliveryQueue) resolve(d delivery, attempts int, cause error) {
+	if cause != nil && q.deadLetters != nil {
+		_, err := q.deadLetters.Add(outbox.DeadLetter{
+			Messenger:    q.name,
+			Error:        cause.Error(),
+			Attempts:     attempts,
+			FailedAt:     time.Now(),
+			Notification: d.notification,
+		})
+		if err != nil && !errors.Is(err, outbox.ErrClosed) {
+			fmt.Printf("Failed to store dead letter for %s: %v\n", q.name, err)
+		}
+	}
+	q.mark(d, cause)
+}
+
+// mark records in the outbox whether the messenger delivered the
+// notification or gave up on it
+func (q *deliveryQueue) mark(d delivery, cause error) {
+	if q.outbox == nil || d.id == "" {
+		return
+	}
+
+	var err error
+	if cause == nil {
+		err = q.outbox.MarkDelivered(d.id, q.name)
+	} else {
+		err = q.outbox.MarkFailed(d.id, q.name, cause)
+	}
+	if err != nil && !errors.Is(err, outbox.ErrClosed) {
+		fmt.Printf("Failed to update outbox for %s: %v\n", q.name, err)
+	}
+}
+
+// close stops the workers once the queue is drained. No deliveries may be
+// enqueued afterwards.
+func (q *deliveryQueue) close() {
+	close(q.items)
+}
+
+// stats returns a snapshot of the queue
+func (q *deliveryQueue) stats() QueueStats {
+	stats := QueueStats{
+		Messenger: q.name,
+		Queued:    len(q.items),
+		Dropped:   q.dropped.Load(),
+	}
+	if q.breaker != nil {
+		stats.Breaker = q.breaker.currentState()
+	}
+	return stats
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/evdnx/gonotify/messenger"
+)
+
+// gatedMessenger records notifications but holds each delivery until the
+// gate is opened
+type gatedMessenger struct {
+	gate chan struct{}
+	mu   sync.Mutex
+	sent []string
+}
+
+func (m *gatedMessenger) SendMessage(message string) error {
+	return nil
+}
+
+func (m *gatedMessenger) SendNotification(ctx context.Context, notification messenger.Notification) error {
+	<-m.gate
+	m.mu.Lock()
+	m.sent = append(m.sent, notification.Title)
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *gatedMessenger) Name() string {
+	return "Gated"
+}
+
+func fillQueue(t *testing.T, overflow string) (*deliveryQueue, *gatedMessenger, *sync.WaitGroup) {
+	t.Helper()
+
+	m := &gatedMessenger{gate: make(chan struct{})}
+	var inflight sync.WaitGroup
+	q := newDeliveryQueue(m.Name(), m, queueOptions{size: 2, workers: 1, overflow: overflow, timeout: time.Second, retry: retryPolicy{maxAttempts: 1}}, nil, nil, &inflight)
+
+	// The worker takes the first delivery and waits at the gate, leaving
+	// room for two more in the queue
+	q.enqueue(delivery{ctx: context.Background(), notification: messenger.Notification{Title: "1"}})
+	deadline := time.Now().Add(2 * time.Second)
+	for len(q.items) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("worker did not pick up the first delivery")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	q.enqueue(delivery{ctx: context.Background(), notification: messenger.Notification{Title: "2"}})
+	q.enqueue(delivery{ctx: context.Background(), notification: messenger.Notification{Title: "3"}})
+	return q, m, &inflight
+}
+
+func drain(q *deliveryQueue, m *gatedMessenger, inflight *sync.WaitGroup) []string {
+	close(m.gate)
+	inflight.Wait()
+	q.close()
+	return m.sent
+}
+
+func TestQueueDropNewest(t *testing.T) {
+	q, m, inflight := fillQueue(t, OverflowDropNewest)
+	q.enqueue(delivery{ctx: context.Background(), notification: messenger.Notification{Title: "4"}})
+
+	if stats := q.stats(); stats.Dropped != 1 || stats.Queued != 2 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	if sent := drain(q, m, inflight); len(sent) != 3 || sent[2] != "3" {
+		t.Fatalf("expected 1, 2, 3 to be sent, got %v", sent)
+	}
+}
+
+func TestQueueDropOldest(t *testing.T) {
+	q, m, inflight := fillQueue(t, OverflowDropOldest)
+	q.enqueue(delivery{ctx: context.Background(), notification: messenger.Notification{Title: "4"}})
+
+	if stats := q.stats(); stats.Dropped != 1 || stats.Queued != 2 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	if sent := drain(q, m, inflight); len(sent) != 3 || sent[1] != "3" || sent[2] != "4" {
+		t.Fatalf("expected 1, 3, 4 to be sent, got %v", sent)
+	}
+}
+
+func TestQueueBlock(t *testing.T) {
+	q, m, inflight := fillQueue(t, OverflowBlock)
+
+	enqueued := make(chan struct{})
+	go func() {
+		q.enqueue(delivery{ctx: context.Background(), notification: messenger.Notification{Title: "4"}})
+		close(enqueued)
+	}()
+
+	select {
+	case <-enqueued:
+		t.Fatal("enqueue did not block on a full queue")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(m.gate)
+	<-enqueued
+	inflight.Wait()
+	q.close()
+
+	if stats := q.stats(); stats.Dropped != 0 {
+		t.Fatalf("unexpected drops: %+v", stats)
+	}
+	if len(m.sent) != 4 {
+		t.Fatalf("expected 4 notifications to be sent, got %v", m.sent)
+	}
+}
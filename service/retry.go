@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+// Retry defaults used when the config leaves a setting empty
+const (
+	defaultRetryMaxAttempts = 3
+	defaultRetryBaseDelay   = time.Second
+	defaultRetryMaxDelay    = 30 * time.Second
+	defaultRetryJitter      = 0.2
+)
+
+// retryPolicy decides how often and how long apart failed deliveries to a
+// messenger are attempted
+type retryPolicy struct {
+	maxAttempts int           // total attempts including the first
+	baseDelay   time.Duration // delay before the second attempt
+	maxDelay    time.Duration // cap on the delay between attempts
+	jitter      float64       // fraction of the delay randomised either way
+}
+
+// backoff returns the delay before the given retry, counting the first retry
+// as 1. The delay doubles with every retry up to maxDelay and is then spread
+// by up to ±jitter of its length.
+func (p retryPolicy) backoff(retry int) time.Duration {
+	delay := p.baseDelay
+	for i := 1; i < retry && delay < p.maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.maxDelay {
+		delay = p.maxDelay
+	}
+	if p.jitter > 0 {
+		delay += time.Duration((rand.Float64()*2 - 1) * p.jitter * float64(delay))
+	}
+	return delay
+}
+
+// wait sleeps for d, returning false if ctx is done first
+func wait(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
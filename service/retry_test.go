@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/evdnx/gonotify/config"
+	"github.com/evdnx/gonotify/messenger"
+)
+
+// flakyMessenger fails with the given errors before succeeding
+type flakyMessenger struct {
+	mu       sync.Mutex
+	errs     []error
+	attempts int
+}
+
+func (m *flakyMessenger) SendMessage(message string) error {
+	return nil
+}
+
+func (m *flakyMessenger) SendNotification(ctx context.Context, notification messenger.Notification) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.attempts++
+	if len(m.errs) == 0 {
+		return nil
+	}
+	err := m.errs[0]
+	m.errs = m.errs[1:]
+	return err
+}
+
+func (m *flakyMessenger) Name() string {
+	return "Flaky"
+}
+
+func sendWithRetry(m *flakyMessenger, policy retryPolicy) {
+	var inflight sync.WaitGroup
+	q := newDeliveryQueue(m.Name(), m, queueOptions{size: 1, workers: 1, overflow: OverflowBlock, timeout: time.Second, retry: policy}, nil, nil, &inflight)
+	q.enqueue(delivery{ctx: context.Background(), notification: messenger.Notification{Title: "alert"}})
+	inflight.Wait()
+	q.close()
+}
+
+func TestBackoffDoublesUpToMax(t *testing.T) {
+	policy := retryPolicy{baseDelay: 100 * time.Millisecond, maxDelay: 350 * time.Millisecond}
+
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 350 * time.Millisecond, 350 * time.Millisecond}
+	for i, expected := range want {
+		if got := policy.backoff(i + 1); got != expected {
+			t.Fatalf("retry %d: expected %v, got %v", i+1, expected, got)
+		}
+	}
+}
+
+func TestBackoffJitter(t *testing.T) {
+	policy := retryPolicy{baseDelay: 100 * time.Millisecond, maxDelay: time.Second, jitter: 0.5}
+
+	for i := 0; i < 100; i++ {
+		if got := policy.backoff(1); got < 50*time.Millisecond || got > 150*time.Millisecond {
+			t.Fatalf("jittered delay %v outside ±50%%", got)
+		}
+	}
+}
+
+func TestRetriesRetryableErrors(t *testing.T) {
+	m := &flakyMessenger{errs: []error{
+		&messenger.HTTPError{StatusCode: 503, Err: errors.New("unavailable")},
+		&messenger.HTTPError{StatusCode: 502, Err: errors.New("bad gateway")},
+	}}
+	sendWithRetry(m, retryPolicy{maxAttempts: 3, baseDelay: time.Millisecond, maxDelay: time.Millisecond})
+
+	if m.attempts != 3 {
+		t.Fatalf("expected 3 attempts, got %d", m.attempts)
+	}
+}
+
+func TestRetryStopsAtMaxAttempts(t *testing.T) {
+	unavailable := &messenger.HTTPError{StatusCode: 503, Err: errors.New("unavailable")}
+	m := &flakyMessenger{errs: []error{unavailable, unavailable, unavailable}}
+	sendWithRetry(m, retryPolicy{maxAttempts: 2, baseDelay: time.Millisecond, maxDelay: time.Millisecond})
+
+	if m.attempts != 2 {
+		t.Fatalf("expected 2 attempts, got %d", m.attempts)
+	}
+}
+
+func TestNoRetryForClientErrors(t *testing.T) {
+	m := &flakyMessenger{errs: []error{&messenger.HTTPError{StatusCode: 400, Err: errors.New("bad request")}}}
+	sendWithRetry(m, retryPolicy{maxAttempts: 3, baseDelay: time.Millisecond, maxDelay: time.Millisecond})
+
+	if m.attempts != 1 {
+		t.Fatalf("expected 1 attempt, got %d", m.attempts)
+	}
+}
+
+func TestRetryHonoursRateLimit(t *testing.T) {
+	m := &flakyMessenger{errs: []error{&messenger.RateLimitError{
+		RetryAfter: 50 * time.Millisecond,
+		Err:        &messenger.HTTPError{StatusCode: 429, Err: errors.New("too many requests")},
+	}}}
+
+	// The backoff alone would wait an hour
+	start := time.Now()
+	sendWithRetry(m, retryPolicy{maxAttempts: 2, baseDelay: time.Hour, maxDelay: time.Hour})
+	elapsed := time.Since(start)
+
+	if m.attempts != 2 {
+		t.Fatalf("expected 2 attempts, got %d", m.attempts)
+	}
+	if elapsed < 50*time.Millisecond || elapsed > time.Second {
+		t.Fatalf("expected to wait the requested 50ms, waited %v", elapsed)
+	}
+}
+
+func TestRetryJitterDefaultsWhenOmitted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notification.json")
+	data := `{"retry": {"max_attempts": 5}, "events": {}}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	cfg, err := config.LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	svc, err := NewNotificationServiceWithMessengers(cfg, nil, []messenger.Messenger{newMockMessenger()})
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+	if got := svc.queues[0].options.retry.jitter; got != defaultRetryJitter {
+		t.Fatalf("expected default jitter %v, got %v", defaultRetryJitter, got)
+	}
+
+	cfg.RetryJitter = -1
+	svc, err = NewNotificationServiceWithMessengers(cfg, nil, []messenger.Messenger{newMockMessenger()})
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+	if got := svc.queues[0].options.retry.jitter; got != 0 {
+		t.Fatalf("expected negative jitter to turn jitter off, got %v", got)
+	}
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/evdnx/gonotify/config"
+	"github.com/evdnx/gonotify/eventbus"
+	"github.com/evdnx/gonotify/types"
+)
+
+// route sends the events it matches to a fixed set of messengers. Empty
+// criteria match every event.
+type route struct {
+	events     []eventbus.EventType
+	symbols    []string
+	strategies []string
+	queues     []*deliveryQueue
+	stop       bool // skip the routes after this one when it matches
+}
+
+// newRoutes resolves the configured routes against the service's messengers
+func newRoutes(configs []config.RouteConfig, queue func(name string) *deliveryQueue) ([]route, error) {
+	routes := make([]route, 0, len(configs))
+	for i, cfg := range configs {
+		if len(cfg.Messengers) == 0 {
+			return nil, fmt.Errorf("route %d has no messengers", i+1)
+		}
+
+		r := route{
+			events:     eventTypes(cfg.Events),
+			symbols:    cfg.Symbols,
+			strategies: cfg.Strategies,
+			stop:       cfg.Stop,
+		}
+		for _, name := range cfg.Messengers {
+			q := queue(name)
+			if q == nil {
+				return nil, fmt.Errorf("route %d: messenger %s is not configured", i+1, name)
+			}
+			r.queues = append(r.queues, q)
+		}
+		routes = append(routes, r)
+	}
+	return routes, nil
+}
+
+// matches reports whether the route applies to the event. Symbol and
+// strategy criteria only match events whose payload carries one.
+func (r route) matches(event eventbus.Event) bool {
+	if len(r.events) > 0 && !slices.Contains(r.events, event.Type) {
+		return false
+	}
+	if len(r.symbols) > 0 && !containsFold(r.symbols, eventSymbol(event)) {
+		return false
+	}
+	if len(r.strategies) > 0 && !containsFold(r.strategies, eventStrategy(event)) {
+		return false
+	}
+	return true
+}
+
+// routeQueues returns the queues an event is delivered to: those of every
+// matching route, in configuration order, up to the first matching route
+// that stops. Service messages, events no route matches and services
+// without routes deliver to every messenger.
+func (s *NotificationService) routeQueues(event eventbus.Event) []*deliveryQueue {
+	if len(s.routes) == 0 || event.Type == "" {
+		return s.queues
+	}
+
+	var queues []*deliveryQueue
+	for _, r := range s.routes {
+		if !r.matches(event) {
+			continue
+		}
+		for _, q := range r.queues {
+			if !slices.Contains(queues, q) {
+				queues = append(queues, q)
+			}
+		}
+		if r.stop {
+			break
+		}
+	}
+	if len(queues) == 0 {
+		return s.queues
+	}
+	return queues
+}
+
+// eventSymbol returns the trading symbol of an event's typed payload
+func eventSymbol(event eventbus.Event) string {
+	switch data := event.Data.(type) {
+	case types.Trade:
+		return data.Symbol
+	case types.Order:
+		return data.Symbol
+	case types.Position:
+		return data.Symbol
+	case types.PnLUpdate:
+		return data.Symbol
+	}
+	return ""
+}
+
+// eventStrategy returns the strategy of an event's typed payload
+func eventStrategy(event eventbus.Event) string {
+	switch data := event.Data.(type) {
+	case types.StrategyError:
+		return data.Strategy
+	case types.StrategyRecovery:
+		return data.Strategy
+	}
+	return ""
+}
+
+// containsFold reports whether list holds value, ignoring case
+func containsFold(list []string, value string) bool {
+	if value == "" {
+		return false
+	}
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/evdnx/gonotify/config"
+	"github.com/evdnx/gonotify/eventbus"
+	"github.com/evdnx/gonotify/messenger"
+	"github.com/evdnx/gonotify/types"
+)
+
+// namedMessenger is a mock messenger with a configurable name
+type namedMessenger struct {
+	*mockMessenger
+	name string
+}
+
+func (m *namedMessenger) Name() string {
+	return m.name
+}
+
+func TestRouteMatches(t *testing.T) {
+	r := route{
+		events:  []eventbus.EventType{eventbus.EventOrderFilled},
+		symbols: []string{"BTCUSDT"},
+	}
+
+	tests := []struct {
+		name  string
+		event eventbus.Event
+		want  bool
+	}{
+		{"matching symbol", eventbus.Event{Type: eventbus.EventOrderFilled, Data: types.Order{Symbol: "BTCUSDT"}}, true},
+		{"symbol ignores case", eventbus.Event{Type: eventbus.EventOrderFilled, Data: types.Order{Symbol: "btcusdt"}}, true},
+		{"other symbol", eventbus.Event{Type: eventbus.EventOrderFilled, Data: types.Order{Symbol: "ETHUSDT"}}, false},
+		{"other event", eventbus.Event{Type: eventbus.EventTradeExecuted, Data: types.Trade{Symbol: "BTCUSDT"}}, false},
+		{"no symbol", eventbus.Event{Type: eventbus.EventOrderFilled, Data: "BTCUSDT"}, false},
+	}
+	for _, tt := range tests {
+		if got := r.matches(tt.event); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+
+	strategyRoute := route{strategies: []string{"grid"}}
+	if !strategyRoute.matches(eventbus.Event{Type: eventbus.EventStrategyError, Data: types.StrategyError{Strategy: "grid"}}) {
+		t.Error("expected strategy route to match its strategy")
+	}
+	if strategyRoute.matches(eventbus.Event{Type: eventbus.EventPnLUpdate, Data: types.PnLUpdate{Symbol: "grid"}}) {
+		t.Error("expected strategy route to skip events without a strategy")
+	}
+}
+
+func TestRoutingSendsEventsToNamedMessengers(t *testing.T) {
+	eventBus := eventbus.NewEventBus()
+	trading := &namedMessenger{newMockMessenger(), "Trading"}
+	dev := &namedMessenger{newMockMessenger(), "Dev"}
+	mail := &namedMessenger{newMockMessenger(), "Email"}
+
+	cfg := testConfig()
+	cfg.ProfitThreshold = 0
+	cfg.Routes = []config.RouteConfig{
+		{Events: []string{"order_filled"}, Symbols: []string{"BTCUSDT"}, Messengers: []string{"Trading"}},
+		{Events: []string{"strategy_error"}, Messengers: []string{"Dev"}},
+		{Events: []string{"pnl_update"}, Messengers: []string{"Email"}},
+	}
+
+	service, err := NewNotificationServiceWithMessengers(cfg, eventBus, []messenger.Messenger{trading, dev, mail})
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+	if err := service.Start(); err != nil {
+		t.Fatalf("failed to start service: %v", err)
+	}
+
+	// Service messages are broadcast
+	for _, m := range []*namedMessenger{trading, dev, mail} {
+		m.waitForMessage(t, "Notification service started")
+	}
+
+	eventBus.PublishData(eventbus.EventOrderFilled, types.Order{Symbol: "BTCUSDT", Side: "buy", Type: "limit", Quantity: 1, ExecutedPrice: 100})
+	trading.waitForMessage(t, "Order Filled")
+
+	eventBus.PublishData(eventbus.EventStrategyError, types.StrategyError{Strategy: "grid", Error: "boom"})
+	dev.waitForMessage(t, "Strategy Error in grid")
+
+	eventBus.PublishData(eventbus.EventPnLUpdate, types.PnLUpdate{Symbol: "BTCUSDT", PnL: 10, PnLPercentage: 2})
+	mail.waitForMessage(t, "P&L Update")
+
+	trading.expectNoMessage(t, 50*time.Millisecond)
+	dev.expectNoMessage(t, 10*time.Millisecond)
+	mail.expectNoMessage(t, 10*time.Millisecond)
+
+	// Events no route matches are broadcast
+	eventBus.PublishData(eventbus.EventSystemError, "connection lost")
+	for _, m := range []*namedMessenger{trading, dev, mail} {
+		m.waitForMessage(t, "System Error")
+	}
+}
+
+func TestRoutingStopsAtRouteAndFallsBackToCatchAll(t *testing.T) {
+	eventBus := eventbus.NewEventBus()
+	trading := &namedMessenger{newMockMessenger(), "Trading"}
+	dev := &namedMessenger{newMockMessenger(), "Dev"}
+	mail := &namedMessenger{newMockMessenger(), "Email"}
+
+	cfg := testConfig()
+	cfg.Routes = []config.RouteConfig{
+		{Events: []string{"order_filled"}, Messengers: []string{"Trading"}, Stop: true},
+		{Events: []string{"strategy_error"}, Messengers: []string{"Dev"}},
+		{Messengers: []string{"Email"}},
+	}
+
+	service, err := NewNotificationServiceWithMessengers(cfg, eventBus, []messenger.Messenger{trading, dev, mail})
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+	if err := service.Start(); err != nil {
+		t.Fatalf("failed to start service: %v", err)
+	}
+	for _, m := range []*namedMessenger{trading, dev, mail} {
+		m.waitForMessage(t, "Notification service started")
+	}
+
+	// The stopping route keeps the event from the catch-all
+	eventBus.PublishData(eventbus.EventOrderFilled, types.Order{Symbol: "BTCUSDT", Side: "buy", Type: "limit", Quantity: 1, ExecutedPrice: 100})
+	trading.waitForMessage(t, "Order Filled")
+
+	// Routes without stop add up
+	eventBus.PublishData(eventbus.EventStrategyError, types.StrategyError{Strategy: "grid", Error: "boom"})
+	dev.waitForMessage(t, "Strategy Error in grid")
+	mail.waitForMessage(t, "Strategy Error in grid")
+
+	// The catch-all takes everything else instead of a broadcast
+	eventBus.PublishData(eventbus.EventSystemError, "connection lost")
+	mail.waitForMessage(t, "System Error")
+
+	trading.expectNoMessage(t, 50*time.Millisecond)
+	dev.expectNoMessage(t, 10*time.Millisecond)
+	mail.expectNoMessage(t, 10*time.Millisecond)
+}
+
+func TestRoutingRejectsUnknownMessenger(t *testing.T) {
+	cfg := testConfig()
+	cfg.Routes = []config.RouteConfig{{Events: []string{"order_filled"}, Messengers: []string{"Telegram"}}}
+
+	_, err := NewNotificationServiceWithMessengers(cfg, eventbus.NewEventBus(), []messenger.Messenger{newMockMessenger()})
+	if err == nil {
+		t.Fatal("expected error for unknown route messenger")
+	}
+}
@@ -1,22 +1,57 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/evdnx/gonotify/config"
 	"github.com/evdnx/gonotify/eventbus"
+	"github.com/evdnx/gonotify/format"
 	"github.com/evdnx/gonotify/messenger"
-	"github.com/evdnx/gonotify/messenger/element"
-	"github.com/evdnx/gonotify/messenger/telegram"
+	"github.com/evdnx/gonotify/outbox"
+	"github.com/evdnx/gonotify/templates"
 	"github.com/evdnx/gonotify/types"
 )
 
+// defaultDeliveryTimeout bounds a single delivery when the config sets none
+const defaultDeliveryTimeout = 10 * time.Second
+
+// defaultMaxDeadLetters bounds the dead-letter store when the config sets no
+// limit, so a messenger that keeps dropping notifications can't exhaust memory
+const defaultMaxDeadLetters = 1000
+
+// subscriberID identifies the service's handlers on the event bus
+const subscriberID = "notification_service"
+
 // NotificationService handles sending notifications for important events
 type NotificationService struct {
-	messengers []messenger.Messenger
-	eventBus   *eventbus.EventBus
-	config     *config.NotificationConfig
+	queues      []*deliveryQueue // one per messenger
+	routes      []route          // empty to deliver every notification to every messenger
+	outbox      *outbox.Outbox   // nil unless the outbox is enabled
+	deadLetters *outbox.DeadLetterStore
+	templates   *templates.Set
+	formatter   *format.Formatter
+	// timestampSource is TimestampEvent, TimestampExchange or TimestampBoth
+	timestampSource string
+	eventBus        *eventbus.EventBus
+	config          *config.NotificationConfig
+
+	// mu guards the fields below and orders deliveries against Stop
+	mu            sync.Mutex
+	ctx           context.Context // parent of every delivery
+	cancel        context.CancelFunc
+	subscriptions []eventbus.EventType
+	replayed      bool
+	stopped       bool
+	inflight      sync.WaitGroup
+
+	// feeders counts the goroutines that queue deliveries without holding
+	// mu, such as the outbox replay; Stop waits for them before closing the
+	// queues
+	feeders sync.WaitGroup
 }
 
 // NewNotificationService creates a new notification service with messengers based on config.
@@ -42,114 +77,413 @@ func newNotificationService(cfg *config.NotificationConfig, bus *eventbus.EventB
 	}
 
 	// Create messengers if not provided
+	var names []string
 	if messengers == nil {
-		messengers = []messenger.Messenger{}
+		var err error
+		if messengers, names, err = configuredMessengers(cfg); err != nil {
+			return nil, err
+		}
+	}
 
-		// Create Element messenger if enabled
-		if cfg.ElementEnabled {
-			if cfg.ElementHomeserverURL == "" {
-				return nil, fmt.Errorf("element homeserver URL is required when element is enabled")
-			}
-			if cfg.ElementAccessToken == "" {
-				return nil, fmt.Errorf("element access token is required when element is enabled")
-			}
-			if cfg.ElementRoomID == "" {
-				return nil, fmt.Errorf("element room ID is required when element is enabled")
+	// Resolve delivery settings
+	options := queueOptions{
+		size:     cfg.DeliveryQueueSize,
+		workers:  cfg.DeliveryWorkers,
+		overflow: cfg.DeliveryOverflow,
+		timeout:  cfg.DeliveryTimeout,
+	}
+	if options.timeout <= 0 {
+		options.timeout = defaultDeliveryTimeout
+	}
+	if options.size <= 0 {
+		options.size = defaultQueueSize
+	}
+	if options.workers <= 0 {
+		options.workers = defaultWorkers
+	}
+	if options.overflow == "" {
+		options.overflow = defaultOverflow
+	}
+	switch options.overflow {
+	case OverflowBlock, OverflowDropOldest, OverflowDropNewest:
+	default:
+		return nil, fmt.Errorf("unknown delivery overflow policy: %s", options.overflow)
+	}
+
+	// Resolve the retry policy
+	options.retry = retryPolicy{
+		maxAttempts: cfg.RetryMaxAttempts,
+		baseDelay:   cfg.RetryBaseDelay,
+		maxDelay:    cfg.RetryMaxDelay,
+		jitter:      cfg.RetryJitter,
+	}
+	if options.retry.maxAttempts <= 0 {
+		options.retry.maxAttempts = defaultRetryMaxAttempts
+	}
+	if options.retry.baseDelay <= 0 {
+		options.retry.baseDelay = defaultRetryBaseDelay
+	}
+	if options.retry.maxDelay <= 0 {
+		options.retry.maxDelay = defaultRetryMaxDelay
+	}
+	// Zero jitter means the default, so a negative one turns jitter off
+	switch {
+	case options.retry.jitter == 0:
+		options.retry.jitter = defaultRetryJitter
+	case options.retry.jitter < 0:
+		options.retry.jitter = 0
+	case options.retry.jitter > 1:
+		return nil, fmt.Errorf("retry jitter must not exceed 1")
+	}
+
+	// Resolve the timestamp source
+	timestampSource := cfg.TimestampSource
+	if timestampSource == "" {
+		timestampSource = defaultTimestampSource
+	}
+	switch timestampSource {
+	case TimestampEvent, TimestampExchange, TimestampBoth:
+	default:
+		return nil, fmt.Errorf("unknown timestamp source: %s", timestampSource)
+	}
+
+	// Parse the message templates
+	formatter, err := cfg.Formatter()
+	if err != nil {
+		return nil, err
+	}
+	set, err := templates.New(cfg.Templates, formatter)
+	if err != nil {
+		return nil, err
+	}
+
+	service := &NotificationService{
+		templates:       set,
+		formatter:       formatter,
+		timestampSource: timestampSource,
+		eventBus:        bus,
+		config:          cfg,
+	}
+
+	// Resolve the circuit breaker settings
+	if cfg.BreakerEnabled {
+		options.breaker = breakerOptions{
+			enabled:          true,
+			failureThreshold: cfg.BreakerFailureThreshold,
+			openTimeout:      cfg.BreakerOpenTimeout,
+			successThreshold: cfg.BreakerSuccessThreshold,
+			onChange:         service.publishBreakerChange,
+		}
+		if options.breaker.failureThreshold <= 0 {
+			options.breaker.failureThreshold = defaultBreakerFailureThreshold
+		}
+		if options.breaker.openTimeout <= 0 {
+			options.breaker.openTimeout = defaultBreakerOpenTimeout
+		}
+		if options.breaker.successThreshold <= 0 {
+			options.breaker.successThreshold = defaultBreakerSuccessThreshold
+		}
+	}
+
+	// Open the outbox, which holds undelivered notifications across restarts
+	if cfg.OutboxEnabled {
+		if cfg.OutboxPath == "" {
+			return nil, fmt.Errorf("outbox path is required when outbox is enabled")
+		}
+		box, err := outbox.Open(cfg.OutboxPath)
+		if err != nil {
+			return nil, err
+		}
+		service.outbox = box
+	}
+
+	// Open the dead-letter store, which is kept in memory unless enabled
+	deadLetterPath := ""
+	if cfg.DeadLetterEnabled {
+		if cfg.DeadLetterPath == "" {
+			return nil, fmt.Errorf("dead-letter path is required when dead letters are enabled")
+		}
+		deadLetterPath = cfg.DeadLetterPath
+	}
+	maxDeadLetters := cfg.DeadLetterMaxLetters
+	if maxDeadLetters <= 0 {
+		maxDeadLetters = defaultMaxDeadLetters
+	}
+	deadLetters, err := outbox.OpenDeadLettersWithLimit(deadLetterPath, maxDeadLetters)
+	if err != nil {
+		return nil, err
+	}
+	service.deadLetters = deadLetters
+
+	// Give each messenger its own queue, wrapping string-only messengers so
+	// every messenger accepts notifications
+	for i, m := range messengers {
+		var name string
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+			if service.queue(name) != nil {
+				return nil, fmt.Errorf("duplicate messenger name: %s", name)
 			}
-			messengers = append(messengers, element.NewClient(
-				cfg.ElementHomeserverURL,
-				cfg.ElementAccessToken,
-				cfg.ElementRoomID,
-			))
+		} else {
+			name = service.queueName(m.Name())
 		}
+		service.queues = append(service.queues, newDeliveryQueue(name, messenger.Adapt(m), options, service.outbox, service.deadLetters, &service.inflight))
+	}
 
-		// Create Telegram messenger if enabled
-		if cfg.TelegramEnabled {
-			if cfg.TelegramBotToken == "" {
-				return nil, fmt.Errorf("telegram bot token is required when telegram is enabled")
+	// Link each messenger to the messengers it fails over to
+	for name, fallbacks := range cfg.Failover {
+		q := service.queue(name)
+		if q == nil {
+			return nil, fmt.Errorf("failover messenger %s is not configured", name)
+		}
+		for _, fallback := range fallbacks {
+			f := service.queue(fallback)
+			if f == nil {
+				return nil, fmt.Errorf("failover messenger %s is not configured", fallback)
 			}
-			if cfg.TelegramChatID == "" {
-				return nil, fmt.Errorf("telegram chat ID is required when telegram is enabled")
+			if f == q {
+				return nil, fmt.Errorf("messenger %s cannot fail over to itself", name)
 			}
-			messengers = append(messengers, telegram.NewClient(
-				cfg.TelegramBotToken,
-				cfg.TelegramChatID,
-			))
+			q.fallbacks = append(q.fallbacks, f)
 		}
+	}
 
-		if len(messengers) == 0 {
-			return nil, fmt.Errorf("at least one messenger must be enabled in configuration")
+	// Resolve the routing table
+	routes, err := newRoutes(cfg.Routes, service.queue)
+	if err != nil {
+		return nil, err
+	}
+	service.routes = routes
+
+	return service, nil
+}
+
+// queue returns the queue of the named messenger, or nil if there is none
+func (s *NotificationService) queue(name string) *deliveryQueue {
+	for _, q := range s.queues {
+		if q.name == name {
+			return q
 		}
 	}
+	return nil
+}
 
-	return &NotificationService{
-		messengers: messengers,
-		eventBus:   bus,
-		config:     cfg,
-	}, nil
+// queueName returns the messenger's name, numbered when an earlier queue
+// has taken it so that every queue can be told apart in the outbox
+func (s *NotificationService) queueName(name string) string {
+	if s.queue(name) == nil {
+		return name
+	}
+	for count := 2; ; count++ {
+		numbered := fmt.Sprintf("%s#%d", name, count)
+		if s.queue(numbered) == nil {
+			return numbered
+		}
+	}
+}
+
+// eventTypes converts configured event type names to event types
+func eventTypes(names []string) []eventbus.EventType {
+	result := make([]eventbus.EventType, 0, len(names))
+	for _, name := range names {
+		result = append(result, eventbus.EventType(name))
+	}
+	return result
 }
 
 // Start registers event handlers and starts the notification service
 func (s *NotificationService) Start() error {
+	return s.StartContext(context.Background())
+}
+
+// StartContext starts the notification service with deliveries bound to ctx.
+// Cancelling ctx aborts deliveries in flight and drops later ones.
+func (s *NotificationService) StartContext(ctx context.Context) error {
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		return fmt.Errorf("notification service is stopped")
+	}
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.ctx, s.cancel = context.WithCancel(ctx)
+
 	// Send a startup notification
-	startupMsg := "🤖 Notification service started"
-	s.sendNotification(startupMsg)
+	queues, startup := s.prepare(eventbus.Event{}, messenger.Notification{
+		Title:    "🤖 Notification service started",
+		Severity: messenger.SeverityInfo,
+	})
+	done := s.track()
+
+	// Deliver what a previous run left in the outbox. The backlog may be
+	// larger than the queues, so it is fed in the background as they drain.
+	if !s.replayed {
+		s.replayed = true
+		s.inflight.Add(1)
+		s.feeders.Add(1)
+		go func(ctx context.Context) {
+			defer s.feeders.Done()
+			defer s.inflight.Done()
+			s.replay(ctx)
+		}(s.ctx)
+	}
 
 	// Register event handlers
 	s.registerEventHandlers()
+	s.mu.Unlock()
 
+	defer done()
+	enqueue(queues, startup)
 	return nil
 }
 
-// registerEventHandlers registers handlers for events that should trigger notifications
+// Stop unsubscribes all event handlers, sends a stopping notification when
+// configured and waits for deliveries in flight to finish. Events published
+// afterwards are ignored. If ctx is done first, the remaining deliveries are
+// cancelled and ctx's error is returned. A stopped service cannot be
+// restarted.
+func (s *NotificationService) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		return nil
+	}
+
+	// Unsubscribe every handler registered by registerEventHandlers
+	for _, eventType := range s.subscriptions {
+		s.eventBus.Unsubscribe(eventType, subscriberID)
+	}
+	s.subscriptions = nil
+
+	// Send a stopping notification. Queueing it may block, so it happens in
+	// the background while Stop waits on ctx.
+	if s.config.NotifyServiceStop {
+		queues, notice := s.prepare(eventbus.Event{}, messenger.Notification{
+			Title:    "🛑 Notification service stopping",
+			Severity: messenger.SeverityInfo,
+		})
+		done := s.track()
+		go func() {
+			defer done()
+			enqueue(queues, notice)
+		}()
+	}
+
+	s.stopped = true
+	cancel := s.cancel
+	s.mu.Unlock()
+
+	// Wait for deliveries in flight
+	done := make(chan struct{})
+	go func() {
+		s.inflight.Wait()
+		close(done)
+	}()
+
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	// Cancel whatever is left and let the workers drain
+	if cancel != nil {
+		cancel()
+	}
+	s.feeders.Wait()
+	for _, q := range s.queues {
+		q.close()
+	}
+	if s.outbox != nil {
+		if closeErr := s.outbox.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	if closeErr := s.deadLetters.Close(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// registerEventHandlers registers handlers for events that should trigger
+// notifications. The caller must hold s.mu.
 func (s *NotificationService) registerEventHandlers() {
 	// Create a filtered handler for trade events
 	if s.config.NotifyTradeExecution {
-		s.eventBus.Subscribe(eventbus.EventTradeExecuted, "notification_service", s.handleTradeExecuted)
+		s.subscribe(eventbus.EventTradeExecuted, s.handleTradeExecuted)
 	}
 
 	// Create a filtered handler for order events
 	if s.config.NotifyOrderFilled {
-		s.eventBus.Subscribe(eventbus.EventOrderFilled, "notification_service", s.handleOrderFilled)
+		s.subscribe(eventbus.EventOrderFilled, s.handleOrderFilled)
 	}
 
 	// Create a filtered handler for position events
 	if s.config.NotifyPositionChange {
-		s.eventBus.Subscribe(eventbus.EventPositionOpened, "notification_service", s.handlePositionOpened)
-		s.eventBus.Subscribe(eventbus.EventPositionClosed, "notification_service", s.handlePositionClosed)
+		s.subscribe(eventbus.EventPositionOpened, s.handlePositionOpened)
+		s.subscribe(eventbus.EventPositionClosed, s.handlePositionClosed)
 	}
 
 	// Create a filtered handler for PnL events
 	if s.config.NotifyPnLUpdate {
-		s.eventBus.Subscribe(eventbus.EventPnLUpdate, "notification_service", s.handlePnLUpdate)
+		s.subscribe(eventbus.EventPnLUpdate, s.handlePnLUpdate)
 	}
 
 	// Create a filtered handler for system errors
 	if s.config.NotifySystemErrors {
-		s.eventBus.Subscribe(eventbus.EventSystemError, "notification_service", s.handleSystemError)
+		s.subscribe(eventbus.EventSystemError, s.handleSystemError)
+		s.subscribe(eventbus.EventSystemRecovered, s.handleSystemRecovered)
 	}
 
 	// Create a filtered handler for strategy errors
 	if s.config.NotifyStrategyErrors {
-		s.eventBus.Subscribe(eventbus.EventStrategyError, "notification_service", s.handleStrategyError)
+		s.subscribe(eventbus.EventStrategyError, s.handleStrategyError)
+		s.subscribe(eventbus.EventStrategyRecovered, s.handleStrategyRecovered)
+	}
+
+	// Create a handler for circuit breaker state changes
+	if s.config.BreakerEnabled && s.config.NotifyBreakerStateChange {
+		s.subscribe(eventbus.EventBreakerStateChanged, s.handleBreakerStateChanged)
 	}
 }
 
+// subscribe registers a handler and records it for Stop
+func (s *NotificationService) subscribe(eventType eventbus.EventType, handler eventbus.EventHandler) {
+	s.eventBus.Subscribe(eventType, subscriberID, handler)
+	s.subscriptions = append(s.subscriptions, eventType)
+}
+
 // handleTradeExecuted handles trade executed events
 func (s *NotificationService) handleTradeExecuted(event eventbus.Event) {
 	// Try to extract trade data
 	var trade types.Trade
 	if err := s.extractTrade(event.Data, &trade); err != nil {
-		s.sendNotification(fmt.Sprintf("⚠️ Received malformed trade execution event: %v", err))
+		s.sendMalformed(event, "trade execution", err)
 		return
 	}
 
-	// Format the notification message
-	message := fmt.Sprintf("💰 Trade Executed: %s %s %.6f %s at price %.2f %s",
-		trade.Side, trade.Symbol, trade.Quantity, trade.BaseAsset, trade.Price, trade.QuoteAsset)
+	// Build the notification
+	fields := []messenger.Field{
+		{Name: "Symbol", Value: trade.Symbol},
+		{Name: "Side", Value: trade.Side},
+		{Name: "Quantity", Value: s.formatter.Quantity(trade.Symbol, trade.Quantity, trade.BaseAsset)},
+		{Name: "Price", Value: s.formatter.Price(trade.Symbol, trade.Price, trade.QuoteAsset)},
+	}
+	if trade.Fee != 0 {
+		fields = append(fields, messenger.Field{Name: "Fee", Value: s.formatter.Amount(trade.Fee, trade.FeeCoin)})
+	}
+
+	notification := messenger.Notification{
+		Severity: messenger.SeverityInfo,
+		Fields:   fields,
+		Tags:     []string{trade.Symbol, trade.Side},
+	}
+	s.render(event, templates.Data{Emoji: templates.Emoji("trade"), Data: trade}, &notification)
 
 	// Send the notification
-	s.sendNotification(message)
+	s.sendNotification(withData(event, trade), notification)
 }
 
 // handleOrderFilled handles order filled events
@@ -157,7 +491,7 @@ func (s *NotificationService) handleOrderFilled(event eventbus.Event) {
 	// Try to extract order data
 	var order types.Order
 	if err := s.extractOrder(event.Data, &order); err != nil {
-		s.sendNotification(fmt.Sprintf("⚠️ Received malformed order filled event: %v", err))
+		s.sendMalformed(event, "order filled", err)
 		return
 	}
 
@@ -173,21 +507,37 @@ func (s *NotificationService) handleOrderFilled(event eventbus.Event) {
 		return
 	}
 
-	// Format the notification message
+	// Build the notification
 	var emoji string
+	severity := messenger.SeverityInfo
+	tags := []string{order.Symbol, order.Side}
 	if isStopLoss {
-		emoji = "🛑"
+		emoji = "stop_loss"
+		severity = messenger.SeverityWarning
+		tags = append(tags, "stop_loss")
 	} else if isTakeProfit {
-		emoji = "🎯"
+		emoji = "take_profit"
+		severity = messenger.SeveritySuccess
+		tags = append(tags, "take_profit")
 	} else {
-		emoji = "📝"
+		emoji = "order"
 	}
 
-	message := fmt.Sprintf("%s Order Filled: %s %s %.6f at price %.2f",
-		emoji, order.Side, order.Symbol, order.Quantity, order.ExecutedPrice)
+	notification := messenger.Notification{
+		Severity: severity,
+		Fields: []messenger.Field{
+			{Name: "Symbol", Value: order.Symbol},
+			{Name: "Side", Value: order.Side},
+			{Name: "Type", Value: order.Type},
+			{Name: "Quantity", Value: s.formatter.Quantity(order.Symbol, order.Quantity)},
+			{Name: "Price", Value: s.formatter.Price(order.Symbol, order.ExecutedPrice)},
+		},
+		Tags: tags,
+	}
+	s.render(event, templates.Data{Emoji: templates.Emoji(emoji), Data: order}, &notification)
 
 	// Send the notification
-	s.sendNotification(message)
+	s.sendNotification(withData(event, order), notification)
 }
 
 // handlePositionOpened handles position opened events
@@ -195,16 +545,25 @@ func (s *NotificationService) handlePositionOpened(event eventbus.Event) {
 	// Try to extract position data
 	var position types.Position
 	if err := s.extractPosition(event.Data, &position); err != nil {
-		s.sendNotification(fmt.Sprintf("⚠️ Received malformed position opened event: %v", err))
+		s.sendMalformed(event, "position opened", err)
 		return
 	}
 
-	// Format the notification message
-	message := fmt.Sprintf("🔓 Position Opened: %s %s %.6f at entry price %.2f",
-		position.Side, position.Symbol, position.Quantity, position.EntryPrice)
+	// Build the notification
+	notification := messenger.Notification{
+		Severity: messenger.SeverityInfo,
+		Fields: []messenger.Field{
+			{Name: "Symbol", Value: position.Symbol},
+			{Name: "Side", Value: position.Side},
+			{Name: "Quantity", Value: s.formatter.Quantity(position.Symbol, position.Quantity)},
+			{Name: "Entry Price", Value: s.formatter.Price(position.Symbol, position.EntryPrice)},
+		},
+		Tags: []string{position.Symbol, position.Side},
+	}
+	s.render(event, templates.Data{Emoji: templates.Emoji("position_opened"), Data: position}, &notification)
 
 	// Send the notification
-	s.sendNotification(message)
+	s.sendNotification(withData(event, position), notification)
 }
 
 // handlePositionClosed handles position closed events
@@ -212,7 +571,7 @@ func (s *NotificationService) handlePositionClosed(event eventbus.Event) {
 	// Try to extract position data
 	var position types.Position
 	if err := s.extractPosition(event.Data, &position); err != nil {
-		s.sendNotification(fmt.Sprintf("⚠️ Received malformed position closed event: %v", err))
+		s.sendMalformed(event, "position closed", err)
 		return
 	}
 
@@ -223,19 +582,33 @@ func (s *NotificationService) handlePositionClosed(event eventbus.Event) {
 		pnlPercentage = -pnlPercentage
 	}
 
-	// Format the notification message
+	// Build the notification
 	var emoji string
+	var severity messenger.Severity
 	if pnl > 0 {
-		emoji = "🔒💰"
+		emoji = "position_profit"
+		severity = messenger.SeveritySuccess
 	} else {
-		emoji = "🔒📉"
+		emoji = "position_loss"
+		severity = messenger.SeverityWarning
 	}
 
-	message := fmt.Sprintf("%s Position Closed: %s %s %.6f at exit price %.2f (P&L: %.2f / %.2f%%)",
-		emoji, position.Side, position.Symbol, position.Quantity, position.ExitPrice, pnl, pnlPercentage)
+	notification := messenger.Notification{
+		Severity: severity,
+		Fields: []messenger.Field{
+			{Name: "Symbol", Value: position.Symbol},
+			{Name: "Side", Value: position.Side},
+			{Name: "Quantity", Value: s.formatter.Quantity(position.Symbol, position.Quantity)},
+			{Name: "Entry Price", Value: s.formatter.Price(position.Symbol, position.EntryPrice)},
+			{Name: "Exit Price", Value: s.formatter.Price(position.Symbol, position.ExitPrice)},
+			{Name: "P&L", Value: fmt.Sprintf("%s (%.2f%%)", s.formatter.Amount(pnl), pnlPercentage)},
+		},
+		Tags: []string{position.Symbol, position.Side},
+	}
+	s.render(event, templates.Data{Emoji: templates.Emoji(emoji), Data: position, PnLPercentage: pnlPercentage}, &notification)
 
 	// Send the notification
-	s.sendNotification(message)
+	s.sendNotification(withData(event, position), notification)
 }
 
 // handlePnLUpdate handles PnL update events
@@ -243,7 +616,7 @@ func (s *NotificationService) handlePnLUpdate(event eventbus.Event) {
 	// Try to extract PnL data
 	var pnlUpdate types.PnLUpdate
 	if err := s.extractPnLUpdate(event.Data, &pnlUpdate); err != nil {
-		s.sendNotification(fmt.Sprintf("⚠️ Received malformed PnL update event: %v", err))
+		s.sendMalformed(event, "PnL update", err)
 		return
 	}
 
@@ -252,19 +625,30 @@ func (s *NotificationService) handlePnLUpdate(event eventbus.Event) {
 		return
 	}
 
-	// Format the notification message
+	// Build the notification
 	var emoji string
+	var severity messenger.Severity
 	if pnlUpdate.PnL > 0 {
-		emoji = "📈"
+		emoji = "pnl_up"
+		severity = messenger.SeveritySuccess
 	} else {
-		emoji = "📉"
+		emoji = "pnl_down"
+		severity = messenger.SeverityWarning
 	}
 
-	message := fmt.Sprintf("%s P&L Update for %s: %.2f (%.2f%%)",
-		emoji, pnlUpdate.Symbol, pnlUpdate.PnL, pnlUpdate.PnLPercentage)
+	notification := messenger.Notification{
+		Severity: severity,
+		Fields: []messenger.Field{
+			{Name: "Symbol", Value: pnlUpdate.Symbol},
+			{Name: "P&L", Value: s.formatter.Amount(pnlUpdate.PnL)},
+			{Name: "P&L %", Value: fmt.Sprintf("%.2f%%", pnlUpdate.PnLPercentage)},
+		},
+		Tags: []string{pnlUpdate.Symbol},
+	}
+	s.render(event, templates.Data{Emoji: templates.Emoji(emoji), Data: pnlUpdate}, &notification)
 
 	// Send the notification
-	s.sendNotification(message)
+	s.sendNotification(withData(event, pnlUpdate), notification)
 }
 
 // handleSystemError handles system error events
@@ -272,15 +656,18 @@ func (s *NotificationService) handleSystemError(event eventbus.Event) {
 	// Extract error data from the event
 	errorMsg, ok := event.Data.(string)
 	if !ok {
-		s.sendNotification("⚠️ Received malformed system error event")
+		s.sendMalformed(event, "system error", nil)
 		return
 	}
 
-	// Format the notification message
-	message := fmt.Sprintf("🚨 System Error: %s", errorMsg)
+	// Build the notification
+	notification := messenger.Notification{
+		Severity: messenger.SeverityCritical,
+	}
+	s.render(event, templates.Data{Emoji: templates.Emoji("error"), Data: errorMsg}, &notification)
 
 	// Send the notification
-	s.sendNotification(message)
+	s.sendNotification(event, notification)
 }
 
 // handleStrategyError handles strategy error events
@@ -288,34 +675,358 @@ func (s *NotificationService) handleStrategyError(event eventbus.Event) {
 	// Try to extract strategy error data
 	var strategyError types.StrategyError
 	if err := s.extractStrategyError(event.Data, &strategyError); err != nil {
-		s.sendNotification(fmt.Sprintf("⚠️ Received malformed strategy error event: %v", err))
+		s.sendMalformed(event, "strategy error", err)
 		return
 	}
 
-	// Format the notification message
-	message := fmt.Sprintf("🚨 Strategy Error in %s: %s", strategyError.Strategy, strategyError.Error)
+	// Build the notification
+	notification := messenger.Notification{
+		Severity: messenger.SeverityError,
+		Fields: []messenger.Field{
+			{Name: "Strategy", Value: strategyError.Strategy},
+		},
+		Tags: []string{strategyError.Strategy},
+	}
+	s.render(event, templates.Data{Emoji: templates.Emoji("error"), Data: strategyError}, &notification)
 
 	// Send the notification
-	s.sendNotification(message)
+	s.sendNotification(withData(event, strategyError), notification)
 }
 
-// sendNotification sends a notification message to all configured messengers
-func (s *NotificationService) sendNotification(message string) {
-	// Add timestamp to the message
-	timestamp := time.Now().Format("2006-01-02 15:04:05")
-	fullMessage := fmt.Sprintf("[%s] %s", timestamp, message)
+// handleSystemRecovered handles system recovered events
+func (s *NotificationService) handleSystemRecovered(event eventbus.Event) {
+	// The recovery description is optional
+	description, _ := event.Data.(string)
+
+	// Build the notification
+	notification := messenger.Notification{
+		Severity: messenger.SeveritySuccess,
+	}
+	s.render(event, templates.Data{Emoji: templates.Emoji("recovered"), Data: description}, &notification)
+
+	// Send the notification
+	s.sendNotification(event, notification)
+}
+
+// handleStrategyRecovered handles strategy recovered events
+func (s *NotificationService) handleStrategyRecovered(event eventbus.Event) {
+	// Try to extract strategy recovery data
+	var recovery types.StrategyRecovery
+	if err := s.extractStrategyRecovery(event.Data, &recovery); err != nil {
+		s.sendMalformed(event, "strategy recovered", err)
+		return
+	}
 
-	// Send the message asynchronously to all messengers
-	for _, msg := range s.messengers {
-		go func(m messenger.Messenger) {
-			if err := m.SendMessage(fullMessage); err != nil {
-				// Log the error but don't propagate it
-				fmt.Printf("Failed to send notification via %s: %v\n", m.Name(), err)
+	// Build the notification
+	notification := messenger.Notification{
+		Severity: messenger.SeveritySuccess,
+		Fields: []messenger.Field{
+			{Name: "Strategy", Value: recovery.Strategy},
+		},
+		Tags: []string{recovery.Strategy},
+	}
+	s.render(event, templates.Data{Emoji: templates.Emoji("recovered"), Data: recovery}, &notification)
+
+	// Send the notification
+	s.sendNotification(withData(event, recovery), notification)
+}
+
+// handleBreakerStateChanged handles circuit breaker state change events.
+// Only opening and closing are notified; probes are not worth a message.
+func (s *NotificationService) handleBreakerStateChanged(event eventbus.Event) {
+	// Extract the state change from the event
+	var change types.BreakerStateChange
+	switch data := event.Data.(type) {
+	case types.BreakerStateChange:
+		change = data
+	case *types.BreakerStateChange:
+		change = *data
+	default:
+		s.sendMalformed(event, "breaker state change", nil)
+		return
+	}
+
+	// Build the notification
+	var emoji string
+	var severity messenger.Severity
+	switch change.To {
+	case BreakerOpen:
+		emoji = "circuit_open"
+		severity = messenger.SeverityWarning
+	case BreakerClosed:
+		emoji = "circuit_closed"
+		severity = messenger.SeveritySuccess
+	default:
+		return
+	}
+
+	notification := messenger.Notification{
+		Severity: severity,
+		Fields: []messenger.Field{
+			{Name: "Messenger", Value: change.Messenger},
+			{Name: "State", Value: change.To},
+		},
+		Tags: []string{change.Messenger},
+	}
+	s.render(event, templates.Data{Emoji: templates.Emoji(emoji), Data: change}, &notification)
+
+	// Send the notification
+	s.sendNotification(withData(event, change), notification)
+}
+
+// publishBreakerChange publishes a circuit breaker state change on the event
+// bus. Breakers change state on delivery workers, so the event is published
+// from its own goroutine to keep handlers that queue notifications from
+// holding up the workers.
+func (s *NotificationService) publishBreakerChange(change types.BreakerStateChange) {
+	go s.eventBus.Publish(eventbus.Event{
+		Type:      eventbus.EventBreakerStateChanged,
+		Data:      change,
+		Timestamp: change.Timestamp,
+	})
+}
+
+// render sets the title and body of a notification from the template of
+// the event's type. Templates that fail on the payload are logged and the
+// default wording is used instead.
+func (s *NotificationService) render(event eventbus.Event, data templates.Data, notification *messenger.Notification) {
+	data.Event = event.Type
+	data.Time = event.Timestamp
+	data.ExchangeTime = exchangeTimestamp(withData(event, data.Data))
+
+	title, body, err := s.templates.Render(data)
+	if err != nil {
+		fmt.Printf("Failed to render %s notification: %v\n", event.Type, err)
+	}
+	if title == "" && body == "" {
+		title = string(event.Type)
+	}
+	notification.Title = title
+	notification.Body = body
+}
+
+// sendMalformed notifies about an event whose payload could not be extracted
+func (s *NotificationService) sendMalformed(event eventbus.Event, kind string, err error) {
+	notification := messenger.Notification{
+		Title:    fmt.Sprintf("⚠️ Received malformed %s event", kind),
+		Severity: messenger.SeverityWarning,
+	}
+	if err != nil {
+		notification.Body = err.Error()
+	}
+	s.sendNotification(eventbus.Event{}, notification)
+}
+
+// sendNotification sends a notification to all configured messengers. The
+// event is attached so rich messengers can render its typed payload; it is
+// zero for service messages. Notifications are dropped once the service is
+// stopped. The notification is queued after releasing s.mu, so a full queue
+// under the block policy holds up only this caller.
+func (s *NotificationService) sendNotification(event eventbus.Event, notification messenger.Notification) {
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		return
+	}
+	queues, d := s.prepare(event, notification)
+	done := s.track()
+	s.mu.Unlock()
+
+	defer done()
+	enqueue(queues, d)
+}
+
+// prepare stamps the notification and returns the queues of the messengers
+// the event is routed to along with the delivery to queue. Notifications
+// about events are written to the outbox first; service messages are not
+// persisted. The caller must hold s.mu.
+func (s *NotificationService) prepare(event eventbus.Event, notification messenger.Notification) ([]*deliveryQueue, delivery) {
+	// Stamp the notification
+	notification.Event = event
+	notification.EventType = event.Type
+	s.stamp(&notification, event)
+
+	ctx := s.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	queues := s.routeQueues(event)
+	names := make([]string, 0, len(queues))
+	for _, q := range queues {
+		names = append(names, q.name)
+	}
+
+	var id string
+	if s.outbox != nil && event.Type != "" {
+		var err error
+		if id, err = s.outbox.Add(notification, names); err != nil {
+			// Deliver anyway; the notification just won't survive a crash
+			fmt.Printf("Failed to persist notification: %v\n", err)
+		}
+	}
+
+	return queues, delivery{ctx: ctx, notification: notification, id: id, targets: names}
+}
+
+// enqueue queues a delivery for every routed messenger
+func enqueue(queues []*deliveryQueue, d delivery) {
+	for _, q := range queues {
+		q.enqueue(d)
+	}
+}
+
+// replay queues the outbox entries left undelivered by a previous run for
+// the messengers that still owe them. It waits for room in the queues rather
+// than applying the overflow policy, and stops when ctx is done, leaving the
+// rest in the outbox for the next run.
+func (s *NotificationService) replay(ctx context.Context) {
+	if s.outbox == nil {
+		return
+	}
+
+	queues := make(map[string]*deliveryQueue, len(s.queues))
+	for _, q := range s.queues {
+		queues[q.name] = q
+	}
+
+	for _, entry := range s.outbox.Pending() {
+		for _, name := range entry.Pending {
+			q, ok := queues[name]
+			if !ok {
+				s.outbox.MarkFailed(entry.ID, name, fmt.Errorf("messenger %s is not configured", name))
+				continue
+			}
+			if err := q.enqueueWait(ctx, delivery{ctx: ctx, notification: entry.Notification, id: entry.ID, targets: entry.Pending}); err != nil {
+				return
 			}
-		}(msg)
+		}
 	}
 }
 
+// DeadLetters returns the notifications messengers gave up on, oldest first
+func (s *NotificationService) DeadLetters() []outbox.DeadLetter {
+	return s.deadLetters.List()
+}
+
+// Redeliver removes a dead letter and queues its notification again for the
+// messenger that failed to deliver it. If that delivery fails too, it comes
+// back as a new dead letter.
+func (s *NotificationService) Redeliver(id string) error {
+	ctx, done, err := s.feed()
+	if err != nil {
+		return err
+	}
+	defer done()
+	return s.redeliver(ctx, id)
+}
+
+// RedeliverAll redelivers every dead letter, returning the errors of those
+// that could not be queued. It waits for room in the queues as they drain,
+// so redelivered notifications neither push out live ones nor get dropped
+// themselves.
+func (s *NotificationService) RedeliverAll() error {
+	ctx, done, err := s.feed()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	var errs []error
+	for _, letter := range s.deadLetters.List() {
+		if err := s.redeliver(ctx, letter.ID); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// feed registers a caller that queues deliveries without holding s.mu, so
+// Stop waits for it before closing the queues. It returns the context to
+// deliver under and a function to call once the caller is done queueing.
+func (s *NotificationService) feed() (context.Context, func(), error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopped {
+		return nil, nil, fmt.Errorf("notification service is stopped")
+	}
+	ctx := s.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return ctx, s.track(), nil
+}
+
+// track registers a caller that is about to queue deliveries after
+// releasing s.mu, so Stop waits for it before closing the queues. It returns
+// the function to call once the caller is done queueing. The caller must
+// hold s.mu and check that the service is not stopped.
+func (s *NotificationService) track() func() {
+	s.inflight.Add(1)
+	s.feeders.Add(1)
+	return func() {
+		s.feeders.Done()
+		s.inflight.Done()
+	}
+}
+
+// redeliver queues a dead letter for its messenger, waiting for room in the
+// queue whatever the overflow policy. If ctx is done first the dead letter
+// is kept.
+func (s *NotificationService) redeliver(ctx context.Context, id string) error {
+	letter, ok := s.deadLetters.Get(id)
+	if !ok {
+		return fmt.Errorf("dead letter %s not found", id)
+	}
+
+	queue := s.queue(letter.Messenger)
+	if queue == nil {
+		return fmt.Errorf("messenger %s is not configured", letter.Messenger)
+	}
+
+	// Persist the notification again before letting go of the dead letter
+	var outboxID string
+	if s.outbox != nil && letter.Notification.Event.Type != "" {
+		var err error
+		if outboxID, err = s.outbox.Add(letter.Notification, []string{queue.name}); err != nil {
+			return err
+		}
+	}
+	if err := s.deadLetters.Remove(id); err != nil {
+		return err
+	}
+
+	d := delivery{ctx: ctx, notification: letter.Notification, id: outboxID, targets: []string{queue.name}}
+	if err := queue.enqueueWait(ctx, d); err != nil {
+		// Keep the dead letter rather than the outbox entry, which would be
+		// replayed on the next start
+		if outboxID != "" {
+			s.outbox.MarkFailed(outboxID, queue.name, err)
+		}
+		if _, addErr := s.deadLetters.Add(letter); addErr != nil {
+			return errors.Join(err, addErr)
+		}
+		return fmt.Errorf("failed to redeliver dead letter %s: %w", id, err)
+	}
+	return nil
+}
+
+// QueueStats returns a snapshot of every messenger's delivery queue
+func (s *NotificationService) QueueStats() []QueueStats {
+	stats := make([]QueueStats, 0, len(s.queues))
+	for _, q := range s.queues {
+		stats = append(stats, q.stats())
+	}
+	return stats
+}
+
+// withData returns a copy of the event carrying the extracted typed payload
+func withData(event eventbus.Event, data interface{}) eventbus.Event {
+	event.Data = data
+	return event
+}
+
 // Helper functions to extract typed data from interface{}
 func (s *NotificationService) extractTrade(data interface{}, trade *types.Trade) error {
 	if tradeData, ok := data.(map[string]interface{}); ok {
@@ -340,6 +1051,9 @@ func (s *NotificationService) extractTrade(data interface{}, trade *types.Trade)
 		if quoteAsset, ok := tradeData["quote_asset"].(string); ok {
 			trade.QuoteAsset = quoteAsset
 		}
+		if timestamp, ok := int64Value(tradeData["timestamp"]); ok {
+			trade.Timestamp = timestamp
+		}
 		return nil
 	}
 	// Try direct type assertion
@@ -377,6 +1091,9 @@ func (s *NotificationService) extractOrder(data interface{}, order *types.Order)
 		if executedPrice, ok := orderData["executed_price"].(float64); ok {
 			order.ExecutedPrice = executedPrice
 		}
+		if timestamp, ok := int64Value(orderData["timestamp"]); ok {
+			order.Timestamp = timestamp
+		}
 		return nil
 	}
 	// Try direct type assertion
@@ -414,6 +1131,12 @@ func (s *NotificationService) extractPosition(data interface{}, position *types.
 		if realizedPnL, ok := posData["realized_pnl"].(float64); ok {
 			position.RealizedPnL = realizedPnL
 		}
+		if openTime, ok := int64Value(posData["open_time"]); ok {
+			position.OpenTime = openTime
+		}
+		if closeTime, ok := int64Value(posData["close_time"]); ok {
+			position.CloseTime = closeTime
+		}
 		return nil
 	}
 	// Try direct type assertion
@@ -475,3 +1198,24 @@ func (s *NotificationService) extractStrategyError(data interface{}, strategyErr
 	return fmt.Errorf("cannot extract strategy error from data")
 }
 
+func (s *NotificationService) extractStrategyRecovery(data interface{}, recovery *types.StrategyRecovery) error {
+	if recoveryData, ok := data.(map[string]interface{}); ok {
+		if strategy, ok := recoveryData["strategy"].(string); ok {
+			recovery.Strategy = strategy
+		}
+		if message, ok := recoveryData["message"].(string); ok {
+			recovery.Message = message
+		}
+		return nil
+	}
+	// Try direct type assertion
+	if sr, ok := data.(*types.StrategyRecovery); ok {
+		*recovery = *sr
+		return nil
+	}
+	if sr, ok := data.(types.StrategyRecovery); ok {
+		*recovery = sr
+		return nil
+	}
+	return fmt.Errorf("cannot extract strategy recovery from data")
+}
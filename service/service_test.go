@@ -1,13 +1,21 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/evdnx/gonotify/config"
 	"github.com/evdnx/gonotify/eventbus"
 	"github.com/evdnx/gonotify/messenger"
+	"github.com/evdnx/gonotify/outbox"
+	"github.com/evdnx/gonotify/templates"
+	"github.com/evdnx/gonotify/types"
 )
 
 type mockMessenger struct {
@@ -248,3 +256,570 @@ func TestSystemErrorWithNonStringData(t *testing.T) {
 	messenger.waitForMessage(t, "malformed system error event")
 }
 
+type mockNotificationMessenger struct {
+	*mockMessenger
+	notifications chan messenger.Notification
+}
+
+func (m *mockNotificationMessenger) SendNotification(ctx context.Context, notification messenger.Notification) error {
+	m.notifications <- notification
+	return m.SendMessage(notification.Text())
+}
+
+func TestNotificationMessengerReceivesStructuredNotification(t *testing.T) {
+	eventBus := eventbus.NewEventBus()
+	mockMsg := &mockNotificationMessenger{mockMessenger: newMockMessenger(), notifications: make(chan messenger.Notification, 10)}
+
+	service, err := NewNotificationServiceWithMessengers(testConfig(), eventBus, []messenger.Messenger{mockMsg})
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+	if err := service.Start(); err != nil {
+		t.Fatalf("failed to start service: %v", err)
+	}
+	mockMsg.waitForMessage(t, "Notification service started")
+	<-mockMsg.notifications
+
+	eventBus.PublishData(eventbus.EventTradeExecuted, map[string]interface{}{
+		"symbol": "BTCUSDT",
+		"side":   "buy",
+		"price":  68000.0,
+	})
+	mockMsg.waitForMessage(t, "Trade Executed")
+
+	select {
+	case notification := <-mockMsg.notifications:
+		if notification.Title != "💰 Trade Executed" || notification.EventType != eventbus.EventTradeExecuted {
+			t.Fatalf("unexpected notification: %+v", notification)
+		}
+		if symbol, ok := notification.Field("Symbol"); !ok || symbol != "BTCUSDT" {
+			t.Fatalf("expected Symbol field, got %+v", notification.Fields)
+		}
+		trade, ok := notification.Event.Data.(types.Trade)
+		if !ok || trade.Symbol != "BTCUSDT" {
+			t.Fatalf("expected typed trade payload, got %#v", notification.Event.Data)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for notification")
+	}
+}
+
+func TestRecoveryNotifications(t *testing.T) {
+	config := testConfig()
+	eventBus, messenger := startTestService(t, config)
+
+	eventBus.PublishData(eventbus.EventSystemRecovered, "reconnected")
+	messenger.waitForMessage(t, "System Recovered: reconnected")
+
+	eventBus.PublishData(eventbus.EventStrategyRecovered, map[string]interface{}{
+		"strategy": "mean-revert",
+	})
+	messenger.waitForMessage(t, "Strategy Recovered: mean-revert")
+}
+
+type blockingMessenger struct {
+	*mockMessenger
+	done chan error
+}
+
+func (m *blockingMessenger) SendNotification(ctx context.Context, notification messenger.Notification) error {
+	<-ctx.Done()
+	m.done <- ctx.Err()
+	return ctx.Err()
+}
+
+func TestDeliveryTimeoutAbortsSend(t *testing.T) {
+	cfg := testConfig()
+	cfg.DeliveryTimeout = 50 * time.Millisecond
+	cfg.RetryMaxAttempts = 1
+
+	mockMsg := &blockingMessenger{mockMessenger: newMockMessenger(), done: make(chan error, 1)}
+	service, err := NewNotificationServiceWithMessengers(cfg, eventbus.NewEventBus(), []messenger.Messenger{mockMsg})
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+	if err := service.Start(); err != nil {
+		t.Fatalf("failed to start service: %v", err)
+	}
+
+	select {
+	case err := <-mockMsg.done:
+		if err != context.DeadlineExceeded {
+			t.Fatalf("expected deadline exceeded, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("delivery was not aborted by the timeout")
+	}
+}
+
+func TestCancelledContextAbortsSend(t *testing.T) {
+	mockMsg := &blockingMessenger{mockMessenger: newMockMessenger(), done: make(chan error, 1)}
+	service, err := NewNotificationServiceWithMessengers(testConfig(), eventbus.NewEventBus(), []messenger.Messenger{mockMsg})
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := service.StartContext(ctx); err != nil {
+		t.Fatalf("failed to start service: %v", err)
+	}
+	cancel()
+
+	select {
+	case err := <-mockMsg.done:
+		if err != context.Canceled {
+			t.Fatalf("expected context canceled, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("delivery was not aborted by cancellation")
+	}
+}
+
+func TestStopHonoursContextWithFullBlockingQueue(t *testing.T) {
+	cfg := testConfig()
+	cfg.DeliveryQueueSize = 1
+	cfg.DeliveryWorkers = 1
+	cfg.DeliveryOverflow = OverflowBlock
+	cfg.NotifyServiceStop = true
+
+	eventBus := eventbus.NewEventBus()
+	mockMsg := &blockingMessenger{mockMessenger: newMockMessenger(), done: make(chan error, 10)}
+	service, err := NewNotificationServiceWithMessengers(cfg, eventBus, []messenger.Messenger{mockMsg})
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+	if err := service.Start(); err != nil {
+		t.Fatalf("failed to start service: %v", err)
+	}
+
+	// The worker is stuck on the startup message and the queue is full, so
+	// the next publisher blocks
+	eventBus.PublishData(eventbus.EventSystemError, "first")
+	published := make(chan struct{})
+	go func() {
+		eventBus.PublishData(eventbus.EventSystemError, "second")
+		close(published)
+	}()
+
+	stopped := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		stopped <- service.Stop(ctx)
+	}()
+
+	select {
+	case err := <-stopped:
+		if err != context.DeadlineExceeded {
+			t.Fatalf("expected deadline exceeded, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Stop did not return when its context expired")
+	}
+	select {
+	case <-published:
+	case <-time.After(2 * time.Second):
+		t.Fatal("publisher is still blocked after Stop")
+	}
+}
+
+type slowMessenger struct {
+	*mockMessenger
+	delay time.Duration
+}
+
+func (m *slowMessenger) SendNotification(ctx context.Context, notification messenger.Notification) error {
+	select {
+	case <-time.After(m.delay):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return m.SendMessage(notification.Text())
+}
+
+func TestStopFlushesInFlightDeliveries(t *testing.T) {
+	cfg := testConfig()
+	cfg.NotifyServiceStop = true
+
+	eventBus := eventbus.NewEventBus()
+	mockMsg := &slowMessenger{mockMessenger: newMockMessenger(), delay: 100 * time.Millisecond}
+	service, err := NewNotificationServiceWithMessengers(cfg, eventBus, []messenger.Messenger{mockMsg})
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+	if err := service.Start(); err != nil {
+		t.Fatalf("failed to start service: %v", err)
+	}
+
+	eventBus.PublishData(eventbus.EventSystemError, "connection lost")
+
+	if err := service.Stop(context.Background()); err != nil {
+		t.Fatalf("failed to stop service: %v", err)
+	}
+
+	// Everything in flight was delivered before Stop returned
+	if len(mockMsg.ch) != 3 {
+		t.Fatalf("expected 3 delivered messages, got %d", len(mockMsg.ch))
+	}
+	for i := 0; i < 3; i++ {
+		<-mockMsg.ch
+	}
+
+	// Handlers are unsubscribed and later notifications are dropped
+	eventBus.PublishData(eventbus.EventSystemError, "after stop")
+	mockMsg.expectNoMessage(t, 300*time.Millisecond)
+
+	if err := service.Start(); err == nil {
+		t.Fatal("expected error when restarting a stopped service")
+	}
+}
+
+func TestStopSendsStoppingMessage(t *testing.T) {
+	cfg := testConfig()
+	cfg.NotifyServiceStop = true
+
+	eventBus := eventbus.NewEventBus()
+	mockMsg := newMockMessenger()
+	service, err := NewNotificationServiceWithMessengers(cfg, eventBus, []messenger.Messenger{mockMsg})
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+	if err := service.Start(); err != nil {
+		t.Fatalf("failed to start service: %v", err)
+	}
+	mockMsg.waitForMessage(t, "Notification service started")
+
+	if err := service.Stop(context.Background()); err != nil {
+		t.Fatalf("failed to stop service: %v", err)
+	}
+	mockMsg.waitForMessage(t, "Notification service stopping")
+}
+
+func TestStopReturnsWhenContextExpires(t *testing.T) {
+	mockMsg := &blockingMessenger{mockMessenger: newMockMessenger(), done: make(chan error, 1)}
+	service, err := NewNotificationServiceWithMessengers(testConfig(), eventbus.NewEventBus(), []messenger.Messenger{mockMsg})
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+	if err := service.Start(); err != nil {
+		t.Fatalf("failed to start service: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := service.Stop(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+
+	// Deliveries still in flight are cancelled
+	select {
+	case err := <-mockMsg.done:
+		if err != context.Canceled {
+			t.Fatalf("expected context canceled, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("delivery was not cancelled")
+	}
+}
+
+func TestOutboxReplaysUndeliveredNotifications(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.jsonl")
+
+	// A previous run persisted a notification but crashed before delivery
+	box, err := outbox.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open outbox: %v", err)
+	}
+	if _, err := box.Add(messenger.Notification{
+		Title:     "🛑 Stop Loss Triggered",
+		EventType: eventbus.EventOrderFilled,
+		Event:     eventbus.Event{Type: eventbus.EventOrderFilled, Data: types.Order{ID: "order-1", Symbol: "BTCUSD"}},
+	}, []string{"Mock"}); err != nil {
+		t.Fatalf("failed to add notification: %v", err)
+	}
+
+	cfg := testConfig()
+	cfg.OutboxEnabled = true
+	cfg.OutboxPath = path
+
+	eventBus := eventbus.NewEventBus()
+	mockMsg := newMockMessenger()
+	service, err := NewNotificationServiceWithMessengers(cfg, eventBus, []messenger.Messenger{mockMsg})
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+	if err := service.Start(); err != nil {
+		t.Fatalf("failed to start service: %v", err)
+	}
+	mockMsg.waitForMessage(t, "Notification service started")
+	mockMsg.waitForMessage(t, "Stop Loss Triggered")
+
+	// New notifications are persisted and resolved once delivered
+	eventBus.PublishData(eventbus.EventSystemError, "connection lost")
+	mockMsg.waitForMessage(t, "System Error")
+
+	if err := service.Stop(context.Background()); err != nil {
+		t.Fatalf("failed to stop service: %v", err)
+	}
+
+	reopened, err := outbox.Open(path)
+	if err != nil {
+		t.Fatalf("failed to reopen outbox: %v", err)
+	}
+	defer reopened.Close()
+	if pending := reopened.Pending(); len(pending) != 0 {
+		t.Fatalf("expected outbox to be drained, got %+v", pending)
+	}
+}
+
+func TestOutboxReplaysBacklogLargerThanQueue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.jsonl")
+
+	// A previous run left more notifications than the queue holds
+	box, err := outbox.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open outbox: %v", err)
+	}
+	const pending = 20
+	for i := 0; i < pending; i++ {
+		if _, err := box.Add(messenger.Notification{
+			Title:     fmt.Sprintf("Order %d Filled", i),
+			EventType: eventbus.EventOrderFilled,
+			Event:     eventbus.Event{Type: eventbus.EventOrderFilled},
+		}, []string{"Mock"}); err != nil {
+			t.Fatalf("failed to add notification: %v", err)
+		}
+	}
+	box.Close()
+
+	cfg := testConfig()
+	cfg.OutboxEnabled = true
+	cfg.OutboxPath = path
+	cfg.DeliveryQueueSize = 2
+	cfg.DeliveryWorkers = 1
+	cfg.DeliveryOverflow = OverflowDropOldest
+
+	mockMsg := newMockMessenger()
+	service, err := NewNotificationServiceWithMessengers(cfg, eventbus.NewEventBus(), []messenger.Messenger{mockMsg})
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+	if err := service.Start(); err != nil {
+		t.Fatalf("failed to start service: %v", err)
+	}
+
+	// The messenger blocks once its channel is full, so the replay has to
+	// wait for the queue to drain
+	mockMsg.waitForMessage(t, "Notification service started")
+	for i := 0; i < pending; i++ {
+		mockMsg.waitForMessage(t, fmt.Sprintf("Order %d Filled", i))
+	}
+
+	if err := service.Stop(context.Background()); err != nil {
+		t.Fatalf("failed to stop service: %v", err)
+	}
+	if letters := service.DeadLetters(); len(letters) != 0 {
+		t.Fatalf("expected no dead letters, got %d", len(letters))
+	}
+}
+
+// failingMessenger rejects every notification until it is fixed
+type failingMessenger struct {
+	*mockMessenger
+	mu    sync.Mutex
+	fixed bool
+}
+
+func (m *failingMessenger) SendNotification(ctx context.Context, notification messenger.Notification) error {
+	m.mu.Lock()
+	fixed := m.fixed
+	m.mu.Unlock()
+
+	if !fixed {
+		return &messenger.HTTPError{StatusCode: 400, Err: errors.New("chat not found")}
+	}
+	return m.SendMessage(notification.Text())
+}
+
+func (m *failingMessenger) fix() {
+	m.mu.Lock()
+	m.fixed = true
+	m.mu.Unlock()
+}
+
+func waitForDeadLetters(t *testing.T, service *NotificationService, count int) []outbox.DeadLetter {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		letters := service.DeadLetters()
+		if len(letters) == count {
+			return letters
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d dead letters, got %+v", count, letters)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestDeadLettersAndRedelivery(t *testing.T) {
+	eventBus := eventbus.NewEventBus()
+	mockMsg := &failingMessenger{mockMessenger: newMockMessenger()}
+
+	service, err := NewNotificationServiceWithMessengers(testConfig(), eventBus, []messenger.Messenger{mockMsg})
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+	if err := service.Start(); err != nil {
+		t.Fatalf("failed to start service: %v", err)
+	}
+
+	eventBus.PublishData(eventbus.EventSystemError, "connection lost")
+
+	// The startup message and the error both end up as dead letters
+	letters := waitForDeadLetters(t, service, 2)
+	letter := letters[1]
+	if letter.Messenger != "Mock" || letter.Attempts != 1 || !strings.Contains(letter.Error, "chat not found") {
+		t.Fatalf("unexpected dead letter: %+v", letter)
+	}
+	if letter.Notification.EventType != eventbus.EventSystemError {
+		t.Fatalf("unexpected notification: %+v", letter.Notification)
+	}
+
+	mockMsg.fix()
+
+	if err := service.Redeliver(letter.ID); err != nil {
+		t.Fatalf("failed to redeliver: %v", err)
+	}
+	mockMsg.waitForMessage(t, "System Error: connection lost")
+
+	if err := service.Redeliver(letter.ID); err == nil {
+		t.Fatal("expected error when redelivering an unknown dead letter")
+	}
+
+	if err := service.RedeliverAll(); err != nil {
+		t.Fatalf("failed to redeliver all: %v", err)
+	}
+	mockMsg.waitForMessage(t, "Notification service started")
+	waitForDeadLetters(t, service, 0)
+}
+
+func TestRedeliverAllWaitsForRoomInQueue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deadletters.jsonl")
+
+	// A previous run left more dead letters than the queue holds
+	store, err := outbox.OpenDeadLetters(path)
+	if err != nil {
+		t.Fatalf("failed to open dead letters: %v", err)
+	}
+	const count = 8
+	for i := 0; i < count; i++ {
+		if _, err := store.Add(outbox.DeadLetter{
+			Messenger:    "Mock",
+			Error:        "chat not found",
+			Attempts:     1,
+			FailedAt:     time.Now(),
+			Notification: messenger.Notification{Title: fmt.Sprintf("Order %d Filled", i), EventType: eventbus.EventOrderFilled},
+		}); err != nil {
+			t.Fatalf("failed to add dead letter: %v", err)
+		}
+	}
+	store.Close()
+
+	cfg := testConfig()
+	cfg.DeadLetterEnabled = true
+	cfg.DeadLetterPath = path
+	cfg.DeliveryQueueSize = 2
+	cfg.DeliveryWorkers = 1
+	cfg.DeliveryOverflow = OverflowDropNewest
+
+	mockMsg := newMockMessenger()
+	service, err := NewNotificationServiceWithMessengers(cfg, eventbus.NewEventBus(), []messenger.Messenger{mockMsg})
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+	if err := service.Start(); err != nil {
+		t.Fatalf("failed to start service: %v", err)
+	}
+	mockMsg.waitForMessage(t, "Notification service started")
+
+	// The messenger blocks once its channel is full, so redelivery has to
+	// wait for the queue to drain
+	errs := make(chan error, 1)
+	go func() { errs <- service.RedeliverAll() }()
+	for i := 0; i < count; i++ {
+		mockMsg.waitForMessage(t, fmt.Sprintf("Order %d Filled", i))
+	}
+	if err := <-errs; err != nil {
+		t.Fatalf("failed to redeliver all: %v", err)
+	}
+	waitForDeadLetters(t, service, 0)
+
+	if err := service.Stop(context.Background()); err != nil {
+		t.Fatalf("failed to stop service: %v", err)
+	}
+}
+
+func TestTemplatesOverrideMessageWording(t *testing.T) {
+	cfg := testConfig()
+	cfg.Templates = map[string]templates.Template{
+		"order_filled": {
+			Title: "{{.Emoji}} {{.Data.Symbol}} filled",
+			Body:  "{{.Data.Side}} {{fixed 2 .Data.Quantity}} @ {{fixed 0 .Data.ExecutedPrice}}",
+		},
+	}
+	eventBus, messenger := startTestService(t, cfg)
+
+	eventBus.PublishData(eventbus.EventOrderFilled, map[string]interface{}{
+		"symbol":         "ETHUSD",
+		"side":           "sell",
+		"type":           "take_profit",
+		"quantity":       1.5,
+		"executed_price": 2000.0,
+	})
+
+	messenger.waitForMessage(t, "🎯 ETHUSD filled: sell 1.50 @ 2000")
+}
+
+func TestNewNotificationServiceRejectsInvalidTemplate(t *testing.T) {
+	cfg := testConfig()
+	cfg.Templates = map[string]templates.Template{"pnl_update": {Title: "{{.Data.Strategy}}"}}
+
+	_, err := NewNotificationServiceWithMessengers(cfg, eventbus.NewEventBus(), []messenger.Messenger{newMockMessenger()})
+	if err == nil || !strings.Contains(err.Error(), "invalid title template for pnl_update") {
+		t.Fatalf("expected invalid template error, got %v", err)
+	}
+}
+
+func TestSymbolPrecisionFormatsNotifications(t *testing.T) {
+	cfg := testConfig()
+	cfg.CurrencySymbols = map[string]string{"USDT": "$"}
+	cfg.Symbols = map[string]config.SymbolConfig{"SHIBUSDT": {TickSize: 0.00000001, StepSize: 1, BaseAsset: "SHIB", QuoteAsset: "USDT"}}
+
+	eventBus := eventbus.NewEventBus()
+	mockMsg := &mockNotificationMessenger{mockMessenger: newMockMessenger(), notifications: make(chan messenger.Notification, 10)}
+	service, err := NewNotificationServiceWithMessengers(cfg, eventBus, []messenger.Messenger{mockMsg})
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+	if err := service.Start(); err != nil {
+		t.Fatalf("failed to start service: %v", err)
+	}
+	mockMsg.waitForMessage(t, "Notification service started")
+	<-mockMsg.notifications
+
+	eventBus.PublishData(eventbus.EventOrderFilled, map[string]interface{}{
+		"symbol":         "SHIBUSDT",
+		"side":           "buy",
+		"type":           "market",
+		"quantity":       2500000.0,
+		"executed_price": 0.00001234,
+	})
+	mockMsg.waitForMessage(t, "buy SHIBUSDT 2500000 SHIB at price $0.00001234")
+
+	notification := <-mockMsg.notifications
+	if price, _ := notification.Field("Price"); price != "$0.00001234" {
+		t.Fatalf("unexpected Price field %q", price)
+	}
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/evdnx/gonotify/eventbus"
+	"github.com/evdnx/gonotify/messenger"
+	"github.com/evdnx/gonotify/types"
+)
+
+// Timestamp sources shown in notifications
+const (
+	TimestampEvent    = "event"    // when the event was published
+	TimestampExchange = "exchange" // when the exchange says it happened, if known
+	TimestampBoth     = "both"     // both, with the delay between them
+)
+
+// defaultTimestampSource is used when the config sets no timestamp source
+const defaultTimestampSource = TimestampEvent
+
+// stamp sets the notification's timestamps from the event and renders the
+// time shown to readers. Service messages are stamped with the current time.
+func (s *NotificationService) stamp(notification *messenger.Notification, event eventbus.Event) {
+	eventTime := event.Timestamp
+	if eventTime.IsZero() {
+		eventTime = time.Now()
+	}
+	exchangeTime := exchangeTimestamp(event)
+
+	notification.Timestamp = eventTime
+	notification.ExchangeTimestamp = exchangeTime
+	if s.timestampSource == TimestampExchange && !exchangeTime.IsZero() {
+		notification.Timestamp = exchangeTime
+	}
+
+	notification.DisplayTime = s.formatter.Time(notification.Timestamp)
+	if s.timestampSource == TimestampBoth && !exchangeTime.IsZero() {
+		notification.DisplayTime = fmt.Sprintf("%s (exchange %s, %s)",
+			s.formatter.Time(eventTime), s.formatter.Time(exchangeTime), latency(eventTime.Sub(exchangeTime)))
+	}
+}
+
+// exchangeTimestamp returns when the exchange says the event's trade, order
+// or position change took place, or zero when the payload carries no time
+func exchangeTimestamp(event eventbus.Event) time.Time {
+	switch data := event.Data.(type) {
+	case types.Trade:
+		return unixTime(data.Timestamp)
+	case types.Order:
+		return unixTime(data.Timestamp)
+	case types.Position:
+		if event.Type == eventbus.EventPositionClosed {
+			return unixTime(data.CloseTime)
+		}
+		return unixTime(data.OpenTime)
+	}
+	return time.Time{}
+}
+
+// unixTime converts an exchange timestamp to a time. Exchanges report
+// seconds, milliseconds, microseconds or nanoseconds since the epoch; the
+// unit is told apart by magnitude.
+func unixTime(value int64) time.Time {
+	switch {
+	case value <= 0:
+		return time.Time{}
+	case value < 1e11:
+		return time.Unix(value, 0)
+	case value < 1e14:
+		return time.UnixMilli(value)
+	case value < 1e17:
+		return time.UnixMicro(value)
+	default:
+		return time.Unix(0, value)
+	}
+}
+
+// latency formats the delay between the exchange and the event, e.g. +1.2s
+func latency(d time.Duration) string {
+	d = d.Round(time.Millisecond)
+	if d < 0 {
+		return "-" + (-d).String()
+	}
+	return "+" + d.String()
+}
+
+// int64Value converts a numeric payload value to an int64
+func int64Value(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return v, true
+	case int:
+		return int64(v), true
+	case float64:
+		return int64(v), true
+	}
+	return 0, false
+}
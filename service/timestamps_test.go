@@ -0,0 +1,75 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/evdnx/gonotify/eventbus"
+	"github.com/evdnx/gonotify/messenger"
+	"github.com/evdnx/gonotify/types"
+)
+
+func TestUnixTime(t *testing.T) {
+	want := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	values := []int64{want.Unix(), want.UnixMilli(), want.UnixMicro(), want.UnixNano()}
+	for _, value := range values {
+		if got := unixTime(value); !got.Equal(want) {
+			t.Errorf("unixTime(%d): expected %v, got %v", value, want, got)
+		}
+	}
+	if !unixTime(0).IsZero() {
+		t.Error("expected zero time for a missing timestamp")
+	}
+}
+
+func TestStampShowsEventAndExchangeTime(t *testing.T) {
+	if _, err := time.LoadLocation("Europe/Berlin"); err != nil {
+		t.Skipf("time zone database unavailable: %v", err)
+	}
+	exchangeTime := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	event := eventbus.Event{
+		Type:      eventbus.EventOrderFilled,
+		Data:      types.Order{Symbol: "BTCUSDT", Timestamp: exchangeTime.UnixMilli()},
+		Timestamp: exchangeTime.Add(1500 * time.Millisecond),
+	}
+
+	tests := []struct {
+		source    string
+		timestamp time.Time
+		display   string
+	}{
+		{TimestampEvent, event.Timestamp, "13:00:01 CET"},
+		{TimestampExchange, exchangeTime, "13:00:00 CET"},
+		{TimestampBoth, event.Timestamp, "13:00:01 CET (exchange 13:00:00 CET, +1.5s)"},
+	}
+	for _, tt := range tests {
+		cfg := testConfig()
+		cfg.Timezone = "Europe/Berlin"
+		cfg.TimestampFormat = "15:04:05 MST"
+		cfg.TimestampSource = tt.source
+		service, err := NewNotificationServiceWithMessengers(cfg, eventbus.NewEventBus(), []messenger.Messenger{newMockMessenger()})
+		if err != nil {
+			t.Fatalf("failed to create service: %v", err)
+		}
+
+		var notification messenger.Notification
+		service.stamp(&notification, event)
+		if !notification.Timestamp.Equal(tt.timestamp) {
+			t.Errorf("%s: unexpected timestamp %v", tt.source, notification.Timestamp)
+		}
+		if notification.DisplayTime != tt.display {
+			t.Errorf("%s: expected %q, got %q", tt.source, tt.display, notification.DisplayTime)
+		}
+		if !notification.ExchangeTimestamp.Equal(exchangeTime) {
+			t.Errorf("%s: unexpected exchange timestamp %v", tt.source, notification.ExchangeTimestamp)
+		}
+	}
+}
+
+func TestUnknownTimestampSourceIsRejected(t *testing.T) {
+	cfg := testConfig()
+	cfg.TimestampSource = "server"
+	if _, err := NewNotificationServiceWithMessengers(cfg, eventbus.NewEventBus(), []messenger.Messenger{newMockMessenger()}); err == nil {
+		t.Fatal("expected unknown timestamp source to be rejected")
+	}
+}
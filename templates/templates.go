@@ -0,0 +1,276 @@
+// Package templates renders the titles and bodies of notifications from
+// text/template sources, so the wording of each event can be configured.
+package templates
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/evdnx/gonotify/eventbus"
+	"github.com/evdnx/gonotify/format"
+	"github.com/evdnx/gonotify/types"
+)
+
+// Template is the text/template source of an event's notification title and
+// body. An empty part keeps the default.
+type Template struct {
+	Title string `json:"title,omitempty"`
+	Body  string `json:"body,omitempty"`
+}
+
+// Data is the value templates are executed with
+type Data struct {
+	Event eventbus.EventType
+	Time  time.Time   // when the event was published
+	Emoji string      // the emoji picked for the event, e.g. 🛑 for stop losses
+	Data  interface{} // the typed payload, e.g. types.Trade, or the message of system events
+
+	// ExchangeTime is when the exchange says the trade or order took place,
+	// or zero when the payload carries no time
+	ExchangeTime time.Time
+
+	// PnLPercentage is the price move of a closed position, signed by side
+	PnLPercentage float64
+}
+
+// emojis maps the names accepted by the emoji template function to emoji
+var emojis = map[string]string{
+	"trade":           "💰",
+	"order":           "📝",
+	"stop_loss":       "🛑",
+	"take_profit":     "🎯",
+	"position_opened": "🔓",
+	"position_profit": "🔒💰",
+	"position_loss":   "🔒📉",
+	"pnl_up":          "📈",
+	"pnl_down":        "📉",
+	"error":           "🚨",
+	"warning":         "⚠️",
+	"recovered":       "✅",
+	"circuit_open":    "🔌",
+	"circuit_closed":  "✅",
+}
+
+// defaults are the templates of every event the service notifies about
+var defaults = map[eventbus.EventType]Template{
+	eventbus.EventTradeExecuted: {
+		Title: "{{.Emoji}} Trade Executed",
+		Body:  "{{.Data.Side}} {{.Data.Symbol}} {{quantity .Data.Symbol .Data.Quantity .Data.BaseAsset}} at price {{price .Data.Symbol .Data.Price .Data.QuoteAsset}}",
+	},
+	eventbus.EventOrderFilled: {
+		Title: "{{.Emoji}} Order Filled",
+		Body:  "{{.Data.Side}} {{.Data.Symbol}} {{quantity .Data.Symbol .Data.Quantity}} at price {{price .Data.Symbol .Data.ExecutedPrice}}",
+	},
+	eventbus.EventPositionOpened: {
+		Title: "{{.Emoji}} Position Opened",
+		Body:  "{{.Data.Side}} {{.Data.Symbol}} {{quantity .Data.Symbol .Data.Quantity}} at entry price {{price .Data.Symbol .Data.EntryPrice}}",
+	},
+	eventbus.EventPositionClosed: {
+		Title: "{{.Emoji}} Position Closed",
+		Body:  "{{.Data.Side}} {{.Data.Symbol}} {{quantity .Data.Symbol .Data.Quantity}} at exit price {{price .Data.Symbol .Data.ExitPrice}} (P&L: {{amount .Data.RealizedPnL}} / {{percent .PnLPercentage}})",
+	},
+	eventbus.EventPnLUpdate: {
+		Title: "{{.Emoji}} P&L Update for {{.Data.Symbol}}",
+		Body:  "{{amount .Data.PnL}} ({{percent .Data.PnLPercentage}})",
+	},
+	eventbus.EventSystemError: {
+		Title: "{{.Emoji}} System Error",
+		Body:  "{{.Data}}",
+	},
+	eventbus.EventSystemRecovered: {
+		Title: "{{.Emoji}} System Recovered",
+		Body:  "{{.Data}}",
+	},
+	eventbus.EventStrategyError: {
+		Title: "{{.Emoji}} Strategy Error in {{.Data.Strategy}}",
+		Body:  "{{.Data.Error}}",
+	},
+	eventbus.EventStrategyRecovered: {
+		Title: "{{.Emoji}} Strategy Recovered{{if .Data.Message}} in {{.Data.Strategy}}{{end}}",
+		Body:  "{{if .Data.Message}}{{.Data.Message}}{{else}}{{.Data.Strategy}}{{end}}",
+	},
+	eventbus.EventBreakerStateChanged: {
+		Title: `{{.Emoji}} Circuit {{if eq .Data.To "open"}}Opened{{else}}Closed{{end}} for {{.Data.Messenger}}`,
+		Body:  `Deliveries via {{.Data.Messenger}} {{if eq .Data.To "open"}}are paused: {{.Data.Error}}{{else}}resumed{{end}}`,
+	},
+}
+
+// samples are the payloads templates are tried on when they are parsed, so
+// that references to fields the payload lacks are caught up front
+var samples = map[eventbus.EventType]interface{}{
+	eventbus.EventTradeExecuted:       types.Trade{},
+	eventbus.EventOrderFilled:         types.Order{},
+	eventbus.EventPositionOpened:      types.Position{},
+	eventbus.EventPositionClosed:      types.Position{},
+	eventbus.EventPnLUpdate:           types.PnLUpdate{},
+	eventbus.EventSystemError:         "",
+	eventbus.EventSystemRecovered:     "",
+	eventbus.EventStrategyError:       types.StrategyError{},
+	eventbus.EventStrategyRecovered:   types.StrategyRecovery{},
+	eventbus.EventBreakerStateChanged: types.BreakerStateChange{},
+}
+
+// funcs returns the helper functions available to templates, formatting
+// numbers with f
+func funcs(f *format.Formatter) template.FuncMap {
+	return template.FuncMap{
+		// price, quantity and amount format numbers with the symbol's precision
+		"price":    f.Price,
+		"quantity": f.Quantity,
+		"amount":   f.Amount,
+		// fixed formats a number with the given number of decimals
+		"fixed": func(decimals int, value float64) string {
+			return strconv.FormatFloat(value, 'f', decimals, 64)
+		},
+		// percent formats a percentage with two decimals
+		"percent": func(value float64) string {
+			return strconv.FormatFloat(value, 'f', 2, 64) + "%"
+		},
+		// emoji returns the named emoji
+		"emoji": func(name string) (string, error) {
+			e, ok := emojis[name]
+			if !ok {
+				return "", fmt.Errorf("unknown emoji %q", name)
+			}
+			return e, nil
+		},
+		// time formats a time in the configured zone with a Go reference
+		// layout, and timestamp with the configured layout
+		"time": func(layout string, t time.Time) string {
+			return f.Time(t, layout)
+		},
+		"timestamp": func(t time.Time) string {
+			return f.Time(t)
+		},
+		"upper": strings.ToUpper,
+		"lower": strings.ToLower,
+	}
+}
+
+// Emoji returns the named emoji, or an empty string for unknown names. The
+// service picks the emoji of its notifications by these names.
+func Emoji(name string) string {
+	return emojis[name]
+}
+
+// Set holds the parsed templates of every event type
+type Set struct {
+	titles   map[eventbus.EventType]*template.Template
+	bodies   map[eventbus.EventType]*template.Template
+	fallback *Set // the default templates; nil without overrides
+}
+
+// New parses the default templates with the given overrides, keyed by event
+// type, formatting numbers with f; a nil f uses the default formatting.
+// Every template is tried on an empty payload of its event, so syntax
+// errors, unknown functions and unknown fields are reported here rather
+// than when an event arrives.
+func New(overrides map[string]Template, f *format.Formatter) (*Set, error) {
+	for name := range overrides {
+		if _, ok := defaults[eventbus.EventType(name)]; !ok {
+			return nil, fmt.Errorf("template for unknown event type %q", name)
+		}
+	}
+
+	helpers := funcs(f)
+	s := &Set{
+		titles: make(map[eventbus.EventType]*template.Template, len(defaults)),
+		bodies: make(map[eventbus.EventType]*template.Template, len(defaults)),
+	}
+	for _, eventType := range eventTypes() {
+		source := defaults[eventType]
+		override := overrides[string(eventType)]
+		if override.Title != "" {
+			source.Title = override.Title
+		}
+		if override.Body != "" {
+			source.Body = override.Body
+		}
+
+		title, err := parse(eventType, "title", source.Title, helpers)
+		if err != nil {
+			return nil, err
+		}
+		body, err := parse(eventType, "body", source.Body, helpers)
+		if err != nil {
+			return nil, err
+		}
+		s.titles[eventType] = title
+		s.bodies[eventType] = body
+	}
+
+	if len(overrides) > 0 {
+		fallback, err := New(nil, f)
+		if err != nil {
+			return nil, err
+		}
+		s.fallback = fallback
+	}
+	return s, nil
+}
+
+// Render executes the title and body templates of data's event type. If a
+// configured template fails on the payload, the notification is rendered
+// with the default templates and the error is returned alongside.
+func (s *Set) Render(data Data) (title string, body string, err error) {
+	title, body, err = s.render(data)
+	if err != nil && s.fallback != nil {
+		if title, body, fallbackErr := s.fallback.render(data); fallbackErr == nil {
+			return title, body, err
+		}
+	}
+	return title, body, err
+}
+
+// render executes the templates of the set itself
+func (s *Set) render(data Data) (title string, body string, err error) {
+	titleTemplate, ok := s.titles[data.Event]
+	if !ok {
+		return "", "", fmt.Errorf("no template for event type %q", data.Event)
+	}
+	if title, err = execute(titleTemplate, data); err != nil {
+		return "", "", err
+	}
+	if body, err = execute(s.bodies[data.Event], data); err != nil {
+		return "", "", err
+	}
+	return title, body, nil
+}
+
+// parse parses one part of an event's template and tries it on the event's
+// sample payload
+func parse(eventType eventbus.EventType, part, source string, helpers template.FuncMap) (*template.Template, error) {
+	name := fmt.Sprintf("%s.%s", eventType, part)
+	t, err := template.New(name).Funcs(helpers).Option("missingkey=error").Parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s template for %s: %w", part, eventType, err)
+	}
+	if _, err := execute(t, Data{Event: eventType, Data: samples[eventType]}); err != nil {
+		return nil, fmt.Errorf("invalid %s template for %s: %w", part, eventType, err)
+	}
+	return t, nil
+}
+
+// execute runs a template into a string
+func execute(t *template.Template, data Data) (string, error) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// eventTypes returns the event types with templates in a stable order
+func eventTypes() []eventbus.EventType {
+	result := make([]eventbus.EventType, 0, len(defaults))
+	for eventType := range defaults {
+		result = append(result, eventType)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+	return result
+}
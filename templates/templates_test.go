@@ -0,0 +1,100 @@
+package templates
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/evdnx/gonotify/eventbus"
+	"github.com/evdnx/gonotify/format"
+	"github.com/evdnx/gonotify/types"
+)
+
+func TestDefaultTemplates(t *testing.T) {
+	set, err := New(nil, nil)
+	if err != nil {
+		t.Fatalf("failed to parse default templates: %v", err)
+	}
+
+	title, body, err := set.Render(Data{
+		Event: eventbus.EventTradeExecuted,
+		Emoji: Emoji("trade"),
+		Data:  types.Trade{Symbol: "BTCUSDT", Side: "buy", Quantity: 0.5, Price: 68000, BaseAsset: "BTC", QuoteAsset: "USDT"},
+	})
+	if err != nil {
+		t.Fatalf("failed to render: %v", err)
+	}
+	if title != "💰 Trade Executed" {
+		t.Errorf("unexpected title %q", title)
+	}
+	if body != "buy BTCUSDT 0.5 BTC at price 68000 USDT" {
+		t.Errorf("unexpected body %q", body)
+	}
+}
+
+func TestOverridesUseHelpers(t *testing.T) {
+	set, err := New(map[string]Template{
+		"order_filled": {Title: `{{emoji "rocket"}}`},
+	}, nil)
+	if err == nil || !strings.Contains(err.Error(), `unknown emoji "rocket"`) {
+		t.Fatalf("expected unknown emoji error, got %v", err)
+	}
+
+	set, err = New(map[string]Template{
+		"order_filled": {Title: `{{emoji "take_profit"}} {{upper .Data.Symbol}} filled at {{time "15:04" .Time}}`},
+	}, format.New(format.Options{Location: time.UTC}))
+	if err != nil {
+		t.Fatalf("failed to parse templates: %v", err)
+	}
+
+	title, body, err := set.Render(Data{
+		Event: eventbus.EventOrderFilled,
+		Time:  time.Date(2024, 1, 1, 9, 30, 0, 0, time.UTC),
+		Data:  types.Order{Symbol: "ethusdt", Side: "sell", Quantity: 1, ExecutedPrice: 2000},
+	})
+	if err != nil {
+		t.Fatalf("failed to render: %v", err)
+	}
+	if title != "🎯 ETHUSDT filled at 09:30" {
+		t.Errorf("unexpected title %q", title)
+	}
+	if body != "sell ethusdt 1 at price 2000" {
+		t.Errorf("expected default body, got %q", body)
+	}
+}
+
+func TestNewRejectsInvalidTemplates(t *testing.T) {
+	tests := []struct {
+		name      string
+		overrides map[string]Template
+		want      string
+	}{
+		{"unknown event", map[string]Template{"trade": {Title: "x"}}, `unknown event type "trade"`},
+		{"syntax error", map[string]Template{"trade_executed": {Body: "{{.Data.Symbol"}}, "invalid body template for trade_executed"},
+		{"unknown function", map[string]Template{"pnl_update": {Title: "{{round .Data.PnL}}"}}, `function "round" not defined`},
+		{"unknown field", map[string]Template{"strategy_error": {Body: "{{.Data.Message}}"}}, "invalid body template for strategy_error"},
+	}
+	for _, tt := range tests {
+		_, err := New(tt.overrides, nil)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.want, err)
+		}
+	}
+}
+
+func TestRenderFallsBackToDefaults(t *testing.T) {
+	set, err := New(map[string]Template{
+		"system_error": {Body: "{{if .Data}}{{index .Data 20}}{{end}}"},
+	}, nil)
+	if err != nil {
+		t.Fatalf("failed to parse templates: %v", err)
+	}
+
+	_, body, err := set.Render(Data{Event: eventbus.EventSystemError, Data: "short"})
+	if err == nil {
+		t.Fatal("expected the failing template to be reported")
+	}
+	if body != "short" {
+		t.Errorf("expected default body, got %q", body)
+	}
+}
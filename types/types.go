@@ -1,5 +1,7 @@
 package types
 
+import "time"
+
 // Trade represents a trade executed on an exchange
 type Trade struct {
 	ID         string  `json:"id"`
@@ -53,3 +55,19 @@ type StrategyError struct {
 	Strategy string `json:"strategy"`
 	Error    string `json:"error"`
 }
+
+// StrategyRecovery represents a strategy recovering from an earlier error
+type StrategyRecovery struct {
+	Strategy string `json:"strategy"`
+	Message  string `json:"message"`
+}
+
+// BreakerStateChange reports a messenger's circuit breaker moving from one
+// state to another: "closed", "open" or "half_open"
+type BreakerStateChange struct {
+	Messenger string    `json:"messenger"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Error     string    `json:"error,omitempty"` // the failure that opened the breaker
+	Timestamp time.Time `json:"timestamp"`
+}